@@ -1,6 +1,9 @@
 package algorithm
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/manosriram/wingman/internal/graph"
 	"github.com/manosriram/wingman/internal/types"
 )
@@ -8,5 +11,34 @@ import (
 type ContextAlgorithm interface {
 	CalculateScore(*graph.Graph)
 	GetScoreForNode(string) float64
+	GetNodeScores() map[string]float64
 	GetAlgorithmType() types.ContextAlgorithmType
 }
+
+// The algorithms GetContextAlgorithm knows, in the order the flag lists them
+var ContextAlgorithmTypes = []types.ContextAlgorithmType{
+	types.PAGERANK_CONTEXT_ALGORITHM,
+	types.HITS_CONTEXT_ALGORITHM,
+	types.INDEGREE_CONTEXT_ALGORITHM,
+	types.RECENCY_CONTEXT_ALGORITHM,
+}
+
+type ContextAlgorithmArgs struct {
+	AlgorithmType   types.ContextAlgorithmType
+	Personalization map[string]float64     // PageRank only
+	ChangeTimes     map[string][]time.Time // Recency only, commit times by file path
+}
+
+func GetContextAlgorithm(args ContextAlgorithmArgs) (ContextAlgorithm, error) {
+	switch args.AlgorithmType {
+	case types.PAGERANK_CONTEXT_ALGORITHM, "":
+		return NewPersonalizedPageRankAlgorithm(args.Personalization), nil
+	case types.HITS_CONTEXT_ALGORITHM:
+		return NewHITSAlgorithm(), nil
+	case types.INDEGREE_CONTEXT_ALGORITHM:
+		return NewInDegreeAlgorithm(), nil
+	case types.RECENCY_CONTEXT_ALGORITHM:
+		return NewRecencyAlgorithm(args.ChangeTimes), nil
+	}
+	return nil, fmt.Errorf("unknown context algorithm %q, want one of %v", args.AlgorithmType, ContextAlgorithmTypes)
+}
@@ -0,0 +1,126 @@
+package algorithm
+
+import (
+	"math"
+
+	"github.com/manosriram/wingman/internal/graph"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+/*
+	until converged or MaxIterations {
+		A(u) = (v = for each file that imports file u) H(v)*W(v,u)
+		H(v) = (u = for each file imported by file v) A(u)*W(v,u)
+
+		normalized so that the authorities and the hubs each sum to 1
+	}
+
+Authorities are the files imported by good hubs, hubs the files importing
+good authorities. A file is as important as it is an authority, which is
+the score of the node; hub scores are kept in HubScores.
+*/
+
+type HITSAlgorithm struct {
+	NodeScores map[string]float64 // Authority scores
+	HubScores  map[string]float64
+
+	// Zero values use the defaults of PageRank
+	Tolerance     float64
+	MaxIterations int
+
+	Diagnostics ConvergenceDiagnostics // Of the last CalculateScore
+}
+
+func NewHITSAlgorithm() *HITSAlgorithm {
+	return &HITSAlgorithm{
+		NodeScores:    make(map[string]float64),
+		HubScores:     make(map[string]float64),
+		Tolerance:     DEFAULT_TOLERANCE,
+		MaxIterations: DEFAULT_MAX_ITERATIONS,
+	}
+}
+
+func (h *HITSAlgorithm) GetScoreForNode(node string) float64 {
+	return h.NodeScores[node]
+}
+
+func (h *HITSAlgorithm) GetNodeScores() map[string]float64 {
+	return h.NodeScores
+}
+
+// Scales the scores to sum to 1, they stay 0 when they all are
+func normalizeScores(scores map[string]float64, nodes []string) {
+	var total float64
+	for _, node := range nodes {
+		total += scores[node]
+	}
+	if total == 0 {
+		return
+	}
+	for node := range scores {
+		scores[node] /= total
+	}
+}
+
+func (h *HITSAlgorithm) CalculateScore(graph *graph.Graph) {
+	tolerance, maxIterations := h.Tolerance, h.MaxIterations
+	if tolerance <= 0 {
+		tolerance = DEFAULT_TOLERANCE
+	}
+	if maxIterations <= 0 {
+		maxIterations = DEFAULT_MAX_ITERATIONS
+	}
+	h.Diagnostics = ConvergenceDiagnostics{}
+
+	N := len(graph.G)
+	nodes := graph.Nodes()
+	authorities := make(map[string]float64, N)
+	hubs := make(map[string]float64, N)
+	for _, node := range nodes {
+		authorities[node] = 1.0 / float64(N)
+		hubs[node] = 1.0 / float64(N)
+	}
+
+	for h.Diagnostics.Iterations < maxIterations {
+		nextAuthorities := make(map[string]float64, N)
+		nextHubs := make(map[string]float64, N)
+		for _, node := range nodes {
+			nextAuthorities[node] = 0
+			nextHubs[node] = 0
+		}
+
+		for _, v := range nodes {
+			for _, u := range graph.G[v] {
+				nextAuthorities[u.NodeValue] += hubs[v] * u.EdgeWeight()
+			}
+		}
+		normalizeScores(nextAuthorities, nodes)
+
+		for _, v := range nodes {
+			for _, u := range graph.G[v] {
+				nextHubs[v] += nextAuthorities[u.NodeValue] * u.EdgeWeight()
+			}
+		}
+		normalizeScores(nextHubs, nodes)
+
+		var delta float64
+		for _, node := range nodes {
+			delta += math.Abs(nextAuthorities[node]-authorities[node]) + math.Abs(nextHubs[node]-hubs[node])
+		}
+
+		authorities, hubs = nextAuthorities, nextHubs
+		h.Diagnostics.Iterations++
+		h.Diagnostics.Delta = delta
+		if delta < tolerance {
+			h.Diagnostics.Converged = true
+			break
+		}
+	}
+
+	h.NodeScores = authorities
+	h.HubScores = hubs
+}
+
+func (h *HITSAlgorithm) GetAlgorithmType() types.ContextAlgorithmType {
+	return types.HITS_CONTEXT_ALGORITHM
+}
@@ -0,0 +1,56 @@
+package algorithm
+
+import (
+	"github.com/manosriram/wingman/internal/graph"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+/*
+	C(u) = (v = for each file that imports file u) W(v,u) / (N-1)
+
+The weighted in-degree centrality: how many files import u, counting each
+import by the weight of its edge, relative to the number of files which
+could import it.
+*/
+
+type InDegreeAlgorithm struct {
+	NodeScores map[string]float64
+}
+
+func NewInDegreeAlgorithm() *InDegreeAlgorithm {
+	return &InDegreeAlgorithm{
+		NodeScores: make(map[string]float64),
+	}
+}
+
+func (i *InDegreeAlgorithm) GetScoreForNode(node string) float64 {
+	return i.NodeScores[node]
+}
+
+func (i *InDegreeAlgorithm) GetNodeScores() map[string]float64 {
+	return i.NodeScores
+}
+
+func (i *InDegreeAlgorithm) CalculateScore(graph *graph.Graph) {
+	scores := make(map[string]float64, len(graph.G))
+	for node := range graph.G {
+		scores[node] = 0
+	}
+
+	for _, v := range graph.Nodes() {
+		for _, u := range graph.G[v] {
+			scores[u.NodeValue] += u.EdgeWeight()
+		}
+	}
+
+	if N := len(graph.G); N > 1 {
+		for node := range scores {
+			scores[node] /= float64(N - 1)
+		}
+	}
+	i.NodeScores = scores
+}
+
+func (i *InDegreeAlgorithm) GetAlgorithmType() types.ContextAlgorithmType {
+	return types.INDEGREE_CONTEXT_ALGORITHM
+}
@@ -1,27 +1,105 @@
 package algorithm
 
 import (
+	"math"
+
 	"github.com/manosriram/wingman/internal/graph"
 	"github.com/manosriram/wingman/internal/types"
 )
 
 /*
-	for N iterations {
-		PR(u) = (1-d)/N + d * (v = for each file that imports file u) PR(v)/L(v)
+	until converged or MaxIterations {
+		PR(u) = (1-d)*T(u) + d * (v = for each file that imports file u) PR(v)*W(v,u)/L(v)
 
-		d = damping factor 0.85
-		L(v) = The number of imports inside file v
+		d = damping factor, 0.85 by default
+		W(v,u) = The weight of the edge from v to u, 1 per link
+		L(v) = The total weight of the edges leaving v
+		T(u) = The teleport probability of u, 1/N unless personalized
 	}
+
+The iterations stop once the L1 distance between two of them, the sum over
+all nodes of |PR'(u) - PR(u)|, drops below Tolerance.
+
+With a personalization the random surfer teleports to the weighted nodes
+only, in proportion to their weights, and so do the surfers stuck on nodes
+without out edges. Scores then measure the importance of nodes as seen from
+the personalized ones.
 */
 
+const (
+	DEFAULT_DAMPING_FACTOR = 0.85
+	DEFAULT_TOLERANCE      = 1e-6
+	DEFAULT_MAX_ITERATIONS = 100
+)
+
+// How a PageRank computation ended
+type ConvergenceDiagnostics struct {
+	Iterations int
+	Delta      float64 // L1 distance between the last two iterations
+	Converged  bool    // Delta dropped below the tolerance before the iteration cap
+}
+
 type PageRankAlgorithm struct {
-	NodeScores map[string]float64
+	NodeScores      map[string]float64
+	Personalization map[string]float64 // Node vs teleport weight, empty for a uniform teleport
+
+	// Zero values, and a damping factor outside of (0, 1), use the defaults
+	Damping       float64
+	Tolerance     float64
+	MaxIterations int
+
+	Diagnostics ConvergenceDiagnostics // Of the last CalculateScore
 }
 
 func NewPageRankAlgorithm() *PageRankAlgorithm {
 	return &PageRankAlgorithm{
-		NodeScores: make(map[string]float64),
+		NodeScores:    make(map[string]float64),
+		Damping:       DEFAULT_DAMPING_FACTOR,
+		Tolerance:     DEFAULT_TOLERANCE,
+		MaxIterations: DEFAULT_MAX_ITERATIONS,
+	}
+}
+
+func NewPersonalizedPageRankAlgorithm(personalization map[string]float64) *PageRankAlgorithm {
+	p := NewPageRankAlgorithm()
+	p.Personalization = personalization
+	return p
+}
+
+func (p *PageRankAlgorithm) parameters() (damping float64, tolerance float64, maxIterations int) {
+	damping, tolerance, maxIterations = p.Damping, p.Tolerance, p.MaxIterations
+	if damping <= 0 || damping >= 1 {
+		damping = DEFAULT_DAMPING_FACTOR
+	}
+	if tolerance <= 0 {
+		tolerance = DEFAULT_TOLERANCE
+	}
+	if maxIterations <= 0 {
+		maxIterations = DEFAULT_MAX_ITERATIONS
+	}
+	return damping, tolerance, maxIterations
+}
+
+// Teleport probability of every node, uniform when no node of the graph is personalized
+func (p *PageRankAlgorithm) teleportVector(graph *graph.Graph) map[string]float64 {
+	teleport := make(map[string]float64, len(graph.G))
+	nodes := graph.Nodes()
+
+	var total float64
+	for _, node := range nodes {
+		if w := p.Personalization[node]; w > 0 {
+			total += w
+		}
 	}
+
+	for _, node := range nodes {
+		if total == 0 {
+			teleport[node] = 1.0 / float64(len(graph.G))
+		} else if w := p.Personalization[node]; w > 0 {
+			teleport[node] = w / total
+		}
+	}
+	return teleport
 }
 
 func (p *PageRankAlgorithm) GetScoreForNode(node string) float64 {
@@ -29,57 +107,83 @@ func (p *PageRankAlgorithm) GetScoreForNode(node string) float64 {
 }
 
 func (p *PageRankAlgorithm) CalculateScore(graph *graph.Graph) {
-	d := 0.85
-	iters := 10
+	p.CalculateScoreWithDiagnostics(graph)
+}
+
+// CalculateScore, returning how the iterations ended
+func (p *PageRankAlgorithm) CalculateScoreWithDiagnostics(graph *graph.Graph) ConvergenceDiagnostics {
+	d, tolerance, maxIterations := p.parameters()
+	p.Diagnostics = ConvergenceDiagnostics{}
+
 	N := len(graph.G)
 	if N == 0 {
-		return
+		p.Diagnostics.Converged = true
+		return p.Diagnostics
 	}
 	n := float64(N)
 
+	nodes := graph.Nodes()
+	teleport := p.teleportVector(graph)
+	outWeights := make(map[string]float64, N)
+	for _, v := range nodes {
+		outWeights[v] = graph.GetOutWeightOfNode(v)
+	}
+
 	prev := make(map[string]float64, N)
 	next := make(map[string]float64, N)
 
-	for node := range graph.G {
+	for _, node := range nodes {
 		prev[node] = 1.0 / n
 	}
 
-	for range iters {
-		base := (1.0 - d) / n
-		for node := range graph.G {
-			next[node] = base
+	for p.Diagnostics.Iterations < maxIterations {
+		for _, node := range nodes {
+			next[node] = (1.0 - d) * teleport[node]
 		}
 
 		var dangling float64
-		for v := range graph.G {
-			out := len(graph.G[v])
-			if out == 0 {
+		for _, v := range nodes {
+			if outWeights[v] == 0 {
 				dangling += prev[v]
 			}
 		}
 
-		for v, outs := range graph.G {
-			outDegree := len(outs)
-			if outDegree == 0 {
+		for _, v := range nodes {
+			if outWeights[v] == 0 {
 				continue
 			}
-			share := d * prev[v] / float64(outDegree)
-			for _, u := range outs {
-				next[u.NodeValue] += share
+			share := d * prev[v] / outWeights[v]
+			for _, u := range graph.G[v] {
+				next[u.NodeValue] += share * u.EdgeWeight()
 			}
 		}
 
 		if dangling != 0 {
-			add := d * dangling / n
-			for node := range graph.G {
-				next[node] += add
+			for _, node := range nodes {
+				next[node] += d * dangling * teleport[node]
 			}
 		}
 
+		var delta float64
+		for _, node := range nodes {
+			delta += math.Abs(next[node] - prev[node])
+		}
+
 		prev, next = next, prev
+		p.Diagnostics.Iterations++
+		p.Diagnostics.Delta = delta
+		if delta < tolerance {
+			p.Diagnostics.Converged = true
+			break
+		}
 	}
 
 	p.NodeScores = prev
+	return p.Diagnostics
+}
+
+func (p *PageRankAlgorithm) GetNodeScores() map[string]float64 {
+	return p.NodeScores
 }
 
 func (p *PageRankAlgorithm) GetAlgorithmType() types.ContextAlgorithmType {
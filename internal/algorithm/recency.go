@@ -0,0 +1,71 @@
+package algorithm
+
+import (
+	"math"
+	"time"
+
+	"github.com/manosriram/wingman/internal/graph"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+const DEFAULT_RECENCY_HALF_LIFE = 30 * 24 * time.Hour
+
+/*
+	R(u) = (c = for each commit changing file u) 2^(-age(c)/HalfLife)
+
+Files changed often and lately are the ones being worked on. Every commit
+counts for 1 when it is made and half as much every HalfLife after. The
+edges of the graph are not used, nodes without history, which symbol nodes
+are, score 0.
+*/
+
+type RecencyAlgorithm struct {
+	NodeScores  map[string]float64
+	ChangeTimes map[string][]time.Time // Commit times by file path
+
+	HalfLife time.Duration // DEFAULT_RECENCY_HALF_LIFE when 0
+	Now      time.Time     // The time ages are measured at, the current time when zero
+}
+
+func NewRecencyAlgorithm(changeTimes map[string][]time.Time) *RecencyAlgorithm {
+	return &RecencyAlgorithm{
+		NodeScores:  make(map[string]float64),
+		ChangeTimes: changeTimes,
+		HalfLife:    DEFAULT_RECENCY_HALF_LIFE,
+	}
+}
+
+func (r *RecencyAlgorithm) GetScoreForNode(node string) float64 {
+	return r.NodeScores[node]
+}
+
+func (r *RecencyAlgorithm) GetNodeScores() map[string]float64 {
+	return r.NodeScores
+}
+
+func (r *RecencyAlgorithm) CalculateScore(graph *graph.Graph) {
+	halfLife := r.HalfLife
+	if halfLife <= 0 {
+		halfLife = DEFAULT_RECENCY_HALF_LIFE
+	}
+	now := r.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	scores := make(map[string]float64, len(graph.G))
+	for node := range graph.G {
+		var score float64
+		for _, changed := range r.ChangeTimes[node] {
+			// A commit dated in the future counts as made now
+			age := max(now.Sub(changed), 0)
+			score += math.Exp2(-float64(age) / float64(halfLife))
+		}
+		scores[node] = score
+	}
+	r.NodeScores = scores
+}
+
+func (r *RecencyAlgorithm) GetAlgorithmType() types.ContextAlgorithmType {
+	return types.RECENCY_CONTEXT_ALGORITHM
+}
@@ -17,6 +17,11 @@ func NewAST(nodePath string, PkgPaths map[string][]string, parser utils.TreeSitt
 	if err != nil {
 		log.Fatalf("Error initializing AST")
 	}
+	return NewASTFromData(nodePath, data, PkgPaths, parser)
+}
+
+// NewASTFromData is NewAST for a file whose contents were already read
+func NewASTFromData(nodePath string, data []byte, PkgPaths map[string][]string, parser utils.TreeSitterParserType) *AST {
 	return &AST{
 		NodeData:     data,
 		NodePath:     nodePath,
@@ -38,10 +43,9 @@ func (a *AST) GetNodeImports() ([]types.NodeImport, error) {
 }
 
 func (a *AST) CalculateASTNodesScore(g *graph.Graph) error {
-	if a.Algorithm.GetAlgorithmType() == types.PAGERANK_CONTEXT_ALGORITHM {
-		a.Algorithm.CalculateScore(g)
-	} else {
-		return errors.New("Algorithm not implemented")
+	if a.Algorithm == nil {
+		return errors.New("No context algorithm set")
 	}
+	a.Algorithm.CalculateScore(g)
 	return nil
 }
@@ -16,6 +16,6 @@ type AST struct {
 	NodeData         []byte
 	NodeLanguage     types.Language
 	PkgPaths         map[string][]string
-	Algorithm        *algorithm.PageRankAlgorithm
+	Algorithm        algorithm.ContextAlgorithm
 	LanguageStrategy *language.LangStrategy
 }
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/types"
+)
+
+// Directory of the cache, at the root of the repository
+const CACHE_DIR = ".wingman"
+
+const INDEX_FILE = "index.json"
+
+// Bump when what the language strategies extract from a file changes, so older caches are dropped
+const INDEX_VERSION = 1
+
+// Modules whose version changes what is parsed out of a file
+const GRAMMAR_MODULE_PREFIX = "github.com/tree-sitter/"
+
+// Entry is what indexing learned about one file, whose contents hash to Hash
+type Entry struct {
+	Hash     string
+	Packages []string
+	Imports  []types.NodeImport
+	Symbols  types.NodeSymbols
+}
+
+/*
+Index is the on-disk index cache of a repository. It is only used by the
+wingman build that wrote it, see Version, for the same RootDir and while the
+manifests the imports are resolved with (go.mod, Cargo.toml, tsconfig.json)
+hash to Manifests.
+
+Symbols only depend on the file. Packages also depend on the files around
+it, a python package needs an __init__.py, so they are valid for the same
+Tree only. Imports are resolved against every package, they are valid for
+the same Tree and Packages only.
+*/
+type Index struct {
+	Version   string
+	RootDir   string
+	Manifests string
+	Tree      string           // See TreeHash
+	Packages  string           // See PackagesHash
+	Files     map[string]Entry // File path vs what it holds
+}
+
+func NewIndex(rootDir string, manifests string) *Index {
+	return &Index{
+		Version:   Version(),
+		RootDir:   rootDir,
+		Manifests: manifests,
+		Files:     make(map[string]Entry),
+	}
+}
+
+/*
+Version identifies the extraction logic: INDEX_VERSION, the revision
+wingman was built from when known and the versions of the tree-sitter
+runtime and grammars.
+*/
+func Version() string {
+	parts := []string{fmt.Sprint(INDEX_VERSION)}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return parts[0]
+	}
+
+	for _, setting := range info.Settings {
+		if setting.Key == "vcs.revision" {
+			parts = append(parts, setting.Value)
+		}
+	}
+	for _, dep := range info.Deps {
+		if strings.HasPrefix(dep.Path, GRAMMAR_MODULE_PREFIX) {
+			parts = append(parts, dep.Path+"@"+dep.Version)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Hash of the path and contents of every file, a missing file hashes as empty
+func HashFiles(paths []string) string {
+	h := sha256.New()
+	for _, path := range paths {
+		data, _ := os.ReadFile(path)
+		fmt.Fprintf(h, "%s\x00%s\x00", path, Hash(data))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Fingerprint of the files of the repository
+func TreeHash(paths []string) string {
+	h := sha256.New()
+	for _, path := range paths {
+		fmt.Fprintf(h, "%s\x00", path)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Fingerprint of the packages of the repository and of the files making them up
+func PackagesHash(pkgPaths map[string][]string) string {
+	pkgs := make([]string, 0, len(pkgPaths))
+	for pkg := range pkgPaths {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+
+	h := sha256.New()
+	for _, pkg := range pkgs {
+		fmt.Fprintf(h, "%s\x00%s\x01", pkg, strings.Join(pkgPaths[pkg], "\x00"))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+/*
+Load reads the index cache of dir. A missing or unreadable cache, or one
+written by another build, for another RootDir or other manifests, gives an
+empty index: everything is parsed again.
+*/
+func Load(dir string, rootDir string, manifests string) *Index {
+	empty := NewIndex(rootDir, manifests)
+
+	data, err := os.ReadFile(filepath.Join(dir, INDEX_FILE))
+	if err != nil {
+		return empty
+	}
+
+	var index Index
+	if err := json.Unmarshal(data, &index); err != nil {
+		return empty
+	}
+	if index.Version != empty.Version || index.RootDir != rootDir || index.Manifests != manifests || index.Files == nil {
+		return empty
+	}
+	return &index
+}
+
+/*
+Save writes the index to dir, creating it with a .gitignore so the cache is
+never committed. The file is replaced at once so a concurrent Load never
+reads half of it.
+*/
+func (i *Index) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*\n"), 0o644); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, INDEX_FILE+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, INDEX_FILE))
+}
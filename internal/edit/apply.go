@@ -0,0 +1,201 @@
+package edit
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileChange is the result of applying the edits of one file
+type FileChange struct {
+	Path       string // Absolute path of the file
+	OldContent string
+	NewContent string
+	Existed    bool // False when the edits created the file
+}
+
+type Applier struct {
+	RootDir string
+}
+
+func NewApplier(rootDir string) *Applier {
+	return &Applier{
+		RootDir: rootDir,
+	}
+}
+
+/*
+ResolvePath returns the absolute path of an edit. Paths are relative to
+RootDir, absolute paths are accepted as long as they are inside it since the
+repo map shows the model absolute paths.
+*/
+func (a *Applier) ResolvePath(path string) (string, error) {
+	root, err := filepath.Abs(a.RootDir)
+	if err != nil {
+		return "", err
+	}
+
+	resolved := path
+	if !filepath.IsAbs(resolved) {
+		resolved = filepath.Join(root, resolved)
+	}
+	resolved = filepath.Clean(resolved)
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%s is outside of %s", path, root)
+	}
+	return resolved, nil
+}
+
+/*
+Prepare computes the new content of every file touched by edits without
+writing anything, so that one bad search block does not leave the tree half
+patched. Edits to the same file are applied in order.
+*/
+func (a *Applier) Prepare(edits []Edit) ([]FileChange, error) {
+	var changes []*FileChange
+	byPath := make(map[string]*FileChange)
+
+	for _, e := range edits {
+		path, err := a.ResolvePath(e.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		change, ok := byPath[path]
+		if !ok {
+			change = &FileChange{Path: path}
+			d, err := os.ReadFile(path)
+			if err == nil {
+				change.Existed = true
+				change.OldContent = string(d)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			} else if e.Search != "" {
+				return nil, fmt.Errorf("%w in %s: file does not exist", ErrSearchNotFound, e.Path)
+			}
+			change.NewContent = change.OldContent
+
+			byPath[path] = change
+			changes = append(changes, change)
+		}
+
+		content, err := replace(change.NewContent, e)
+		if err != nil {
+			return nil, err
+		}
+		change.NewContent = content
+	}
+
+	result := make([]FileChange, 0, len(changes))
+	for _, change := range changes {
+		result = append(result, *change)
+	}
+	return result, nil
+}
+
+// ErrFileModified is returned when a file no longer has the content a change expects
+var ErrFileModified = errors.New("file was modified")
+
+// Returns an error unless the file at path has content, or is missing when exists is false
+func checkContent(path string, content string, exists bool) error {
+	d, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !exists {
+		return nil
+	}
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s was removed", ErrFileModified, path)
+		}
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: %s was created", ErrFileModified, path)
+	}
+	if string(d) != content {
+		return fmt.Errorf("%w: %s changed on disk", ErrFileModified, path)
+	}
+	return nil
+}
+
+/*
+Write saves the new content of every change, creating missing directories.
+Changes are prepared before being confirmed, so nothing is written when a
+file was modified in the meantime. When a write fails the files written
+before it are restored, a change set is written whole or not at all.
+*/
+func (a *Applier) Write(changes []FileChange) error {
+	for _, change := range changes {
+		if err := checkContent(change.Path, change.OldContent, change.Existed); err != nil {
+			return err
+		}
+	}
+
+	for i, change := range changes {
+		if err := writeChange(change); err != nil {
+			for _, written := range changes[:i] {
+				err = errors.Join(err, restore(written))
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func writeChange(change FileChange) error {
+	if err := os.MkdirAll(filepath.Dir(change.Path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(change.Path, []byte(change.NewContent), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", change.Path, err)
+	}
+	return nil
+}
+
+// Puts back the content a file had before the change, removing it when the change created it
+func restore(change FileChange) error {
+	if !change.Existed {
+		if err := os.Remove(change.Path); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", change.Path, err)
+		}
+		return nil
+	}
+	if err := os.WriteFile(change.Path, []byte(change.OldContent), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", change.Path, err)
+	}
+	return nil
+}
+
+func (a *Applier) Apply(edits []Edit) ([]FileChange, error) {
+	changes, err := a.Prepare(edits)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.Write(changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+/*
+Revert restores the content the files had before changes were written and
+removes the files they created. Nothing is restored when a file was modified
+after the changes, so later work is not silently lost.
+*/
+func (a *Applier) Revert(changes []FileChange) error {
+	for _, change := range changes {
+		if err := checkContent(change.Path, change.NewContent, true); err != nil {
+			return err
+		}
+	}
+
+	for _, change := range changes {
+		if err := restore(change); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package edit
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DIFF_CONTEXT_LINES = 3
+
+// Size of the LCS table, in cells, above which the changed lines are shown as removed then added
+const DIFF_MAX_LCS_CELLS = 1 << 20
+
+type diffOp struct {
+	Kind byte // ' ', '-' or '+'
+	Text string
+}
+
+func splitLines(content string) []string {
+	lines := strings.SplitAfter(content, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+/*
+diffLines returns the line operations turning a into b. The common prefix and
+suffix are trimmed before running the LCS, which keeps the table small for
+the local edits we usually diff. What is left of a rewrite of a large file
+would need a table of hundreds of MB, past DIFF_MAX_LCS_CELLS it is shown as
+a plain removal of the old lines and insertion of the new ones.
+*/
+func diffLines(a, b []string) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{Kind: ' ', Text: line})
+	}
+
+	x, y := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(x)+1)*(len(y)+1) > DIFF_MAX_LCS_CELLS {
+		for _, line := range x {
+			ops = append(ops, diffOp{Kind: '-', Text: line})
+		}
+		for _, line := range y {
+			ops = append(ops, diffOp{Kind: '+', Text: line})
+		}
+	} else {
+		ops = append(ops, lcsLines(x, y)...)
+	}
+
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{Kind: ' ', Text: line})
+	}
+	return ops
+}
+
+// The line operations turning x into y along their longest common subsequence
+func lcsLines(x, y []string) []diffOp {
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			ops = append(ops, diffOp{Kind: ' ', Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{Kind: '-', Text: x[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{Kind: '+', Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		ops = append(ops, diffOp{Kind: '-', Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		ops = append(ops, diffOp{Kind: '+', Text: y[j]})
+	}
+	return ops
+}
+
+// Diff returns the unified diff of a change, "" when the content did not change
+func Diff(change FileChange) string {
+	ops := diffLines(splitLines(change.OldContent), splitLines(change.NewContent))
+
+	var out strings.Builder
+	oldName := "a" + change.Path
+	if !change.Existed {
+		oldName = "/dev/null"
+	}
+	header := fmt.Sprintf("--- %s\n+++ b%s\n", oldName, change.Path)
+
+	oldLine, newLine := 1, 1
+	for start := 0; start < len(ops); {
+		if ops[start].Kind == ' ' {
+			oldLine++
+			newLine++
+			start++
+			continue
+		}
+
+		// Extend the hunk while the next change is within twice the context
+		hunkStart := max(start-DIFF_CONTEXT_LINES, 0)
+		end := start
+		for end < len(ops) {
+			if ops[end].Kind != ' ' {
+				end++
+				continue
+			}
+			next := end
+			for next < len(ops) && ops[next].Kind == ' ' {
+				next++
+			}
+			if next == len(ops) || next-end > 2*DIFF_CONTEXT_LINES {
+				end = min(end+DIFF_CONTEXT_LINES, len(ops))
+				break
+			}
+			end = next
+		}
+
+		hunkOld := oldLine - (start - hunkStart)
+		hunkNew := newLine - (start - hunkStart)
+		var oldCount, newCount int
+		var body strings.Builder
+		for _, op := range ops[hunkStart:end] {
+			if op.Kind != '+' {
+				oldCount++
+			}
+			if op.Kind != '-' {
+				newCount++
+			}
+			body.WriteByte(op.Kind)
+			body.WriteString(op.Text)
+			if !strings.HasSuffix(op.Text, "\n") {
+				body.WriteString("\n")
+			}
+		}
+
+		if out.Len() == 0 {
+			out.WriteString(header)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(hunkOld, oldCount), hunkRange(hunkNew, newCount))
+		out.WriteString(body.String())
+
+		for _, op := range ops[start:end] {
+			if op.Kind != '+' {
+				oldLine++
+			}
+			if op.Kind != '-' {
+				newLine++
+			}
+		}
+		start = end
+	}
+
+	return out.String()
+}
+
+func hunkRange(line int, count int) string {
+	if count == 0 {
+		// Empty ranges point at the line before, like GNU diff
+		return fmt.Sprintf("%d,0", line-1)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", line)
+	}
+	return fmt.Sprintf("%d,%d", line, count)
+}
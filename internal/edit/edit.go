@@ -0,0 +1,160 @@
+package edit
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	SEARCH_MARKER  = "<<<<<<< SEARCH"
+	DIVIDER_MARKER = "======="
+	REPLACE_MARKER = ">>>>>>> REPLACE"
+)
+
+/*
+Edit is a single search/replace block proposed by the LLM, the format is
+described to the model in types.BASE_LLM_PROMPT:
+
+	path/to/file.go
+	<<<<<<< SEARCH
+	lines to find
+	=======
+	lines to put instead
+	>>>>>>> REPLACE
+
+An empty Search creates the file, or appends Replace to it when it exists.
+*/
+type Edit struct {
+	Path    string
+	Search  string
+	Replace string
+}
+
+/*
+ParseEdits extracts the search/replace blocks of an LLM response in the
+order they appear.
+
+The path is taken from the closest non empty line above the SEARCH marker,
+skipping code fences. A block without a path line uses the path of the
+previous block, which is how models usually write several edits to the same
+file.
+*/
+func ParseEdits(response string) ([]Edit, error) {
+	var lines []string
+	scanner := bufio.NewScanner(strings.NewReader(response))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var edits []Edit
+	var lastPath string
+	for i := 0; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != SEARCH_MARKER {
+			continue
+		}
+
+		path := findPath(lines[:i])
+		if path == "" {
+			path = lastPath
+		}
+		if path == "" {
+			return nil, fmt.Errorf("line %d: search block without a file path", i+1)
+		}
+
+		var search, replace []string
+		j := i + 1
+		for ; j < len(lines) && strings.TrimSpace(lines[j]) != DIVIDER_MARKER; j++ {
+			search = append(search, lines[j])
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("line %d: search block for %s is missing %s", i+1, path, DIVIDER_MARKER)
+		}
+
+		j++
+		for ; j < len(lines) && strings.TrimSpace(lines[j]) != REPLACE_MARKER; j++ {
+			replace = append(replace, lines[j])
+		}
+		if j == len(lines) {
+			return nil, fmt.Errorf("line %d: search block for %s is missing %s", i+1, path, REPLACE_MARKER)
+		}
+
+		edits = append(edits, Edit{
+			Path:    path,
+			Search:  joinLines(search),
+			Replace: joinLines(replace),
+		})
+		lastPath = path
+		i = j
+	}
+
+	return edits, nil
+}
+
+// The path line of a block, or "" when the line above is part of something else
+func findPath(lines []string) string {
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "```") {
+			continue
+		}
+		if line == REPLACE_MARKER || strings.ContainsAny(line, " \t") {
+			return ""
+		}
+		return strings.Trim(line, "`*:")
+	}
+	return ""
+}
+
+func joinLines(lines []string) string {
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+var ErrSearchNotFound = errors.New("search block did not match")
+
+/*
+replace applies a single edit to content. An exact match is tried first, then
+a match that ignores trailing whitespace on every line since models often
+drop or add it. Only the first occurrence is replaced.
+*/
+func replace(content string, e Edit) (string, error) {
+	if e.Search == "" {
+		if content != "" && !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		return content + e.Replace, nil
+	}
+
+	if idx := strings.Index(content, e.Search); idx >= 0 {
+		return content[:idx] + e.Replace + content[idx+len(e.Search):], nil
+	}
+
+	contentLines := strings.SplitAfter(content, "\n")
+	searchLines := strings.Split(strings.TrimSuffix(e.Search, "\n"), "\n")
+	for start := 0; start+len(searchLines) <= len(contentLines); start++ {
+		matched := true
+		for k, searchLine := range searchLines {
+			if strings.TrimRight(contentLines[start+k], " \t\r\n") != strings.TrimRight(searchLine, " \t\r") {
+				matched = false
+				break
+			}
+		}
+		if !matched {
+			continue
+		}
+
+		before := strings.Join(contentLines[:start], "")
+		after := strings.Join(contentLines[start+len(searchLines):], "")
+		return before + e.Replace + after, nil
+	}
+
+	return "", fmt.Errorf("%w in %s:\n%s", ErrSearchNotFound, e.Path, e.Search)
+}
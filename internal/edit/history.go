@@ -0,0 +1,48 @@
+package edit
+
+import "sync"
+
+// ChangeSet holds the changes written for one answer, undone together
+type ChangeSet struct {
+	Changes []FileChange
+	Commit  string // Hash of the commit holding the changes, "" when they were not committed
+}
+
+// History is the stack of change sets applied in a session, most recent last
+type History struct {
+	mu   sync.Mutex
+	Sets []ChangeSet
+}
+
+func NewHistory() *History {
+	return &History{
+		Sets: []ChangeSet{},
+	}
+}
+
+func (h *History) Push(set ChangeSet) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.Sets = append(h.Sets, set)
+}
+
+// Pop removes the most recent change set, ok is false when the history is empty
+func (h *History) Pop() (set ChangeSet, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if len(h.Sets) == 0 {
+		return ChangeSet{}, false
+	}
+	set = h.Sets[len(h.Sets)-1]
+	h.Sets = h.Sets[:len(h.Sets)-1]
+	return set, true
+}
+
+func (h *History) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.Sets)
+}
@@ -0,0 +1,191 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrNotARepository = errors.New("not a git repository")
+
+/*
+Repo runs the local git binary in the work tree containing Dir. Only the
+porcelain commands are used so the behavior matches what the user gets from
+running git by hand, hooks and config included.
+*/
+type Repo struct {
+	Dir  string // Root of the work tree
+	Path string // Path to the git binary
+}
+
+// Open finds the work tree containing dir, ErrNotARepository is returned when there is none
+func Open(dir string) (*Repo, error) {
+	path, err := exec.LookPath("git")
+	if err != nil {
+		return nil, fmt.Errorf("git not found: %w", err)
+	}
+
+	r := &Repo{Dir: dir, Path: path}
+	root, err := r.run("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotARepository, dir)
+	}
+	r.Dir = strings.TrimSpace(root)
+
+	return r, nil
+}
+
+func (r *Repo) run(args ...string) (string, error) {
+	cmd := exec.Command(r.Path, args...)
+	cmd.Dir = r.Dir
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return "", fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return stdout.String(), nil
+}
+
+// HasCommits is false in a freshly initialized repository
+func (r *Repo) HasCommits() bool {
+	_, err := r.run("rev-parse", "--verify", "--quiet", "HEAD")
+	return err == nil
+}
+
+/*
+ChangedFiles returns the paths, relative to Dir, of modified, removed and
+untracked files. Files named like one of exclude are left out wherever they
+are, which is how the files wingman writes itself are ignored.
+*/
+func (r *Repo) ChangedFiles(exclude ...string) ([]string, error) {
+	args := []string{"status", "--porcelain=v1", "-z", "--untracked-files=all", "--", "."}
+	for _, name := range exclude {
+		args = append(args, ":(exclude,glob)**/"+name)
+	}
+
+	status, err := r.run(args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	entries := strings.Split(status, "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		files = append(files, entry[3:])
+
+		// Renames and copies are followed by the original path
+		if entry[0] == 'R' || entry[0] == 'C' {
+			if i+1 < len(entries) && entries[i+1] != "" {
+				files = append(files, entries[i+1])
+			}
+			i++
+		}
+	}
+	return files, nil
+}
+
+// IsDirty reports uncommitted changes, see ChangedFiles for exclude
+func (r *Repo) IsDirty(exclude ...string) (bool, error) {
+	files, err := r.ChangedFiles(exclude...)
+	if err != nil {
+		return false, err
+	}
+	return len(files) > 0, nil
+}
+
+// Diff returns the changes of the work tree against the last commit, limited to paths when given
+func (r *Repo) Diff(paths ...string) (string, error) {
+	args := []string{"diff"}
+	if r.HasCommits() {
+		args = append(args, "HEAD")
+	}
+	return r.run(append(append(args, "--"), paths...)...)
+}
+
+// StagedDiff returns the changes staged for the next commit, limited to paths when given
+func (r *Repo) StagedDiff(paths ...string) (string, error) {
+	args := []string{"diff", "--cached", "--"}
+	return r.run(append(args, paths...)...)
+}
+
+// Stage adds the current content of paths to the index, including removals
+func (r *Repo) Stage(paths ...string) error {
+	args := []string{"add", "-A", "--"}
+	_, err := r.run(append(args, paths...)...)
+	return err
+}
+
+/*
+Commit stages and commits paths with message and returns the abbreviated
+hash of the new commit. Only paths are committed, whatever else is staged
+stays staged. Without paths every change of the work tree is committed.
+*/
+func (r *Repo) Commit(message string, paths ...string) (string, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	if err := r.Stage(paths...); err != nil {
+		return "", err
+	}
+
+	args := []string{"commit", "-m", message, "--"}
+	if _, err := r.run(append(args, paths...)...); err != nil {
+		return "", err
+	}
+
+	hash, err := r.run("rev-parse", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(hash), nil
+}
+
+/*
+FileChangeTimes returns the commit times of every file changed by the last
+maxCommits commits of HEAD, keyed by absolute path, most recent first. Files
+no longer in the work tree are included under their old path.
+*/
+func (r *Repo) FileChangeTimes(maxCommits int) (map[string][]time.Time, error) {
+	times := make(map[string][]time.Time)
+	if !r.HasCommits() {
+		return times, nil
+	}
+
+	// Every commit starts with \x01 and its timestamp, followed by the NUL separated paths it changed
+	log, err := r.run("log", "-z", "--name-only", "--format=%x01%ct", "-n", strconv.Itoa(maxCommits))
+	if err != nil {
+		return nil, err
+	}
+
+	var committed time.Time
+	for _, token := range strings.Split(log, "\x00") {
+		token = strings.TrimLeft(token, "\n")
+		switch {
+		case strings.HasPrefix(token, "\x01"):
+			seconds, err := strconv.ParseInt(token[1:], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("git log: bad commit time %q", token[1:])
+			}
+			committed = time.Unix(seconds, 0)
+		case token != "":
+			path := filepath.Join(r.Dir, filepath.FromSlash(token))
+			times[path] = append(times[path], committed)
+		}
+	}
+	return times, nil
+}
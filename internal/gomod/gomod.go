@@ -0,0 +1,320 @@
+package gomod
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrNoModule = errors.New("go.mod has no module directive")
+
+type Replace struct {
+	Old        string
+	OldVersion string
+	New        string
+	NewVersion string
+}
+
+// A replacement by a directory rather than another module version
+func (r Replace) IsLocal() bool {
+	return r.NewVersion == "" && (filepath.IsAbs(r.New) || strings.HasPrefix(r.New, "./") || strings.HasPrefix(r.New, "../") || r.New == "." || r.New == "..")
+}
+
+type Require struct {
+	Path    string
+	Version string
+}
+
+/*
+ModFile holds the directives of a go.mod file which matter for resolving
+imports. Local replacement directories are relative to the go.mod file.
+*/
+type ModFile struct {
+	Path    string
+	Module  string
+	Go      string
+	Require []Require
+	Replace []Replace
+}
+
+/*
+WorkFile holds the directives of a go.work file, the directories of its use
+directives are made absolute.
+*/
+type WorkFile struct {
+	Path    string
+	Go      string
+	Use     []string
+	Replace []Replace
+}
+
+type directive struct {
+	Verb string
+	Args []string
+}
+
+// Splits a line into its tokens, quoted strings are unquoted and // starts a comment
+func tokenize(line string) ([]string, error) {
+	var tokens []string
+	for {
+		line = strings.TrimLeft(line, " \t\r")
+		if line == "" || strings.HasPrefix(line, "//") {
+			return tokens, nil
+		}
+
+		switch line[0] {
+		case '"', '`':
+			end := 1
+			for end < len(line) && line[end] != line[0] {
+				if line[0] == '"' && line[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(line) {
+				return nil, errors.New("unterminated string: " + line)
+			}
+			token, err := strconv.Unquote(line[:end+1])
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			line = line[end+1:]
+		default:
+			end := strings.IndexAny(line, " \t\r\"`")
+			if comment := strings.Index(line, "//"); comment >= 0 && (end < 0 || comment < end) {
+				end = comment
+			}
+			if end < 0 {
+				end = len(line)
+			}
+			tokens = append(tokens, line[:end])
+			line = line[end:]
+		}
+	}
+}
+
+/*
+parseDirectives returns the directives of a go.mod or go.work file, with the
+lines of a block ("require ( ... )") each returned as a directive of the
+block's verb.
+*/
+func parseDirectives(data []byte) ([]directive, error) {
+	var directives []directive
+	block := ""
+
+	for _, line := range strings.Split(string(data), "\n") {
+		tokens, err := tokenize(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(tokens) == 0 {
+			continue
+		}
+
+		if block != "" {
+			if len(tokens) == 1 && tokens[0] == ")" {
+				block = ""
+				continue
+			}
+			directives = append(directives, directive{Verb: block, Args: tokens})
+			continue
+		}
+
+		if len(tokens) == 2 && tokens[1] == "(" {
+			block = tokens[0]
+			continue
+		}
+		directives = append(directives, directive{Verb: tokens[0], Args: tokens[1:]})
+	}
+
+	if block != "" {
+		return nil, errors.New("unterminated " + block + " block")
+	}
+	return directives, nil
+}
+
+// old [version] => new [version]
+func parseReplace(args []string) (Replace, bool) {
+	arrow := -1
+	for i, arg := range args {
+		if arg == "=>" {
+			arrow = i
+		}
+	}
+	if arrow < 1 || arrow > 2 || len(args)-arrow-1 < 1 || len(args)-arrow-1 > 2 {
+		return Replace{}, false
+	}
+
+	r := Replace{Old: args[0], New: args[arrow+1]}
+	if arrow == 2 {
+		r.OldVersion = args[1]
+	}
+	if len(args)-arrow-1 == 2 {
+		r.NewVersion = args[arrow+2]
+	}
+	return r, true
+}
+
+/*
+ParseModFile parses the go.mod file at path from data. Comments, blocks and
+quoted paths are allowed anywhere the go command allows them.
+*/
+func ParseModFile(path string, data []byte) (*ModFile, error) {
+	directives, err := parseDirectives(data)
+	if err != nil {
+		return nil, err
+	}
+
+	mod := &ModFile{Path: path}
+	for _, d := range directives {
+		switch {
+		case d.Verb == "module" && len(d.Args) == 1:
+			mod.Module = d.Args[0]
+		case d.Verb == "go" && len(d.Args) == 1:
+			mod.Go = d.Args[0]
+		case d.Verb == "require" && len(d.Args) >= 2:
+			mod.Require = append(mod.Require, Require{Path: d.Args[0], Version: d.Args[1]})
+		case d.Verb == "replace":
+			if r, ok := parseReplace(d.Args); ok {
+				mod.Replace = append(mod.Replace, r)
+			}
+		}
+	}
+
+	if mod.Module == "" {
+		return nil, ErrNoModule
+	}
+	return mod, nil
+}
+
+// ParseWorkFile parses the go.work file at path from data
+func ParseWorkFile(path string, data []byte) (*WorkFile, error) {
+	directives, err := parseDirectives(data)
+	if err != nil {
+		return nil, err
+	}
+
+	work := &WorkFile{Path: path}
+	dir := filepath.Dir(path)
+	for _, d := range directives {
+		switch {
+		case d.Verb == "go" && len(d.Args) == 1:
+			work.Go = d.Args[0]
+		case d.Verb == "use" && len(d.Args) == 1:
+			use := filepath.FromSlash(d.Args[0])
+			if !filepath.IsAbs(use) {
+				use = filepath.Join(dir, use)
+			}
+			work.Use = append(work.Use, use)
+		case d.Verb == "replace":
+			if r, ok := parseReplace(d.Args); ok {
+				work.Replace = append(work.Replace, r)
+			}
+		}
+	}
+	return work, nil
+}
+
+var (
+	cacheMu   sync.Mutex
+	modCache  = make(map[string]*ModFile)
+	workCache = make(map[string]*WorkFile)
+)
+
+/*
+ReadModFile reads and parses the go.mod file at path, parsed files are cached
+by path and modification time.
+*/
+func ReadModFile(path string) (*ModFile, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := path + "@" + fi.ModTime().String()
+	cacheMu.Lock()
+	mod, ok := modCache[key]
+	cacheMu.Unlock()
+	if ok {
+		return mod, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	mod, err = ParseModFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	modCache[key] = mod
+	cacheMu.Unlock()
+	return mod, nil
+}
+
+/*
+ReadWorkFile reads and parses the go.work file at path, parsed files are
+cached by path and modification time like go.mod files.
+*/
+func ReadWorkFile(path string) (*WorkFile, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key := path + "@" + fi.ModTime().String()
+	cacheMu.Lock()
+	work, ok := workCache[key]
+	cacheMu.Unlock()
+	if ok {
+		return work, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	work, err = ParseWorkFile(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheMu.Lock()
+	workCache[key] = work
+	cacheMu.Unlock()
+	return work, nil
+}
+
+/*
+FindWorkFile returns the go.work file governing dir, like the go command:
+GOWORK names it when set ("off" disables workspaces), otherwise it is the
+closest go.work above dir. It returns "" when there is none.
+*/
+func FindWorkFile(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+
+	for {
+		candidate := filepath.Join(dir, "go.work")
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
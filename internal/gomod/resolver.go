@@ -0,0 +1,195 @@
+package gomod
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/manosriram/wingman/internal/utils"
+)
+
+// Module is a module path and the directory its packages are read from
+type Module struct {
+	Path string
+	Dir  string
+}
+
+/*
+Resolver maps import paths to directories for the files of one module, the
+main module: the one of the closest go.mod. In workspace mode the modules
+of the go.work use directives are resolvable too. Replace directives
+pointing to local directories are honored, the ones of go.work taking
+precedence over the ones of go.mod files, like the go command does.
+*/
+type Resolver struct {
+	Main    Module
+	Modules []Module // Longest path first, so the most specific module wins
+	Files   []string // The go.mod and go.work files it was built from
+
+	stamp string
+}
+
+var (
+	resolverMu    sync.Mutex
+	resolverCache = make(map[string]*Resolver) // go.mod and go.work paths vs their resolver
+)
+
+// Paths and modification times of files, a missing file has none
+func filesStamp(paths []string) string {
+	var stamp strings.Builder
+	for _, path := range paths {
+		stamp.WriteString(path + "@")
+		if fi, err := os.Stat(path); err == nil {
+			stamp.WriteString(fi.ModTime().String())
+		}
+		stamp.WriteString("\x00")
+	}
+	return stamp.String()
+}
+
+func localReplaceDir(r Replace, base string) string {
+	dir := filepath.FromSlash(r.New)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(base, dir)
+	}
+	return dir
+}
+
+/*
+NewResolver returns the resolver of the module the file or directory at path
+belongs to. A module's files share one resolver, it is built again only when
+one of the files it was built from changes or another go.work governs it.
+*/
+func NewResolver(path string) (*Resolver, error) {
+	modPath, err := utils.FindGoModPath(path)
+	if err != nil {
+		return nil, err
+	}
+	workPath := FindWorkFile(filepath.Dir(modPath))
+
+	key := modPath + "\x00" + workPath
+	resolverMu.Lock()
+	r, ok := resolverCache[key]
+	resolverMu.Unlock()
+	if ok && r.stamp == filesStamp(r.Files) {
+		return r, nil
+	}
+
+	r, err = newResolver(modPath, workPath)
+	if err != nil {
+		return nil, err
+	}
+	r.stamp = filesStamp(r.Files)
+
+	resolverMu.Lock()
+	resolverCache[key] = r
+	resolverMu.Unlock()
+	return r, nil
+}
+
+// newResolver builds the resolver of the module of the go.mod at modPath, workPath is "" outside a workspace
+func newResolver(modPath, workPath string) (*Resolver, error) {
+	mod, err := ReadModFile(modPath)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Resolver{Main: Module{Path: mod.Module, Dir: filepath.Dir(modPath)}, Files: []string{modPath}}
+	modules := map[string]string{mod.Module: r.Main.Dir}
+
+	// Local replaces in the order they apply, a later one wins
+	var replaces []Module
+	addReplaces := func(list []Replace, base string) {
+		for _, replace := range list {
+			if replace.IsLocal() {
+				replaces = append(replaces, Module{Path: replace.Old, Dir: localReplaceDir(replace, base)})
+			}
+		}
+	}
+	addReplaces(mod.Replace, r.Main.Dir)
+
+	var work *WorkFile
+	if workPath != "" {
+		r.Files = append(r.Files, workPath)
+		if w, err := ReadWorkFile(workPath); err == nil && slices.Contains(w.Use, r.Main.Dir) {
+			work = w
+		}
+	}
+
+	if work != nil {
+		for _, use := range work.Use {
+			if use == r.Main.Dir {
+				continue
+			}
+			useModPath := filepath.Join(use, "go.mod")
+			r.Files = append(r.Files, useModPath)
+			useMod, err := ReadModFile(useModPath)
+			if err != nil {
+				continue
+			}
+			modules[useMod.Module] = use
+			addReplaces(useMod.Replace, use)
+		}
+		addReplaces(work.Replace, filepath.Dir(work.Path))
+	}
+
+	for _, replace := range replaces {
+		modules[replace.Path] = replace.Dir
+	}
+
+	for path, dir := range modules {
+		r.Modules = append(r.Modules, Module{Path: path, Dir: dir})
+	}
+	sort.Slice(r.Modules, func(i, j int) bool {
+		if len(r.Modules[i].Path) != len(r.Modules[j].Path) {
+			return len(r.Modules[i].Path) > len(r.Modules[j].Path)
+		}
+		return r.Modules[i].Path < r.Modules[j].Path
+	})
+	return r, nil
+}
+
+/*
+Resolve returns the directory of the package with the given import path,
+false when no known module provides it, as for the standard library and
+dependencies which are not replaced by a local directory.
+*/
+func (r *Resolver) Resolve(importPath string) (string, bool) {
+	for _, m := range r.Modules {
+		if importPath == m.Path {
+			return m.Dir, true
+		}
+		if strings.HasPrefix(importPath, m.Path+"/") {
+			return filepath.Join(m.Dir, filepath.FromSlash(strings.TrimPrefix(importPath, m.Path+"/"))), true
+		}
+	}
+	return "", false
+}
+
+/*
+ImportPath returns the import path of the package in dir, as declared by the
+closest go.mod above it.
+*/
+func ImportPath(dir string) (string, error) {
+	modPath, err := utils.FindGoModPath(dir)
+	if err != nil {
+		return "", err
+	}
+	mod, err := ReadModFile(modPath)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(filepath.Dir(modPath), dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", errors.New("directory outside of its module")
+	}
+	if rel == "." {
+		return mod.Module, nil
+	}
+	return mod.Module + "/" + filepath.ToSlash(rel), nil
+}
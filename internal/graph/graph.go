@@ -1,17 +1,33 @@
 package graph
 
 import (
+	"sort"
+
 	"github.com/manosriram/wingman/internal/types"
 )
 
+/*
+GraphNode is the end of an edge. Weight is the strength of the edge, linking
+the same nodes again adds to it rather than adding another edge.
+*/
 type GraphNode struct {
 	NodeValue string
+	Weight    float64
 }
 
 func NewGraphNode(nodeValue string) GraphNode {
 	return GraphNode{
 		NodeValue: nodeValue,
+		Weight:    1,
+	}
+}
+
+// Weight of the edge to the node, nodes built without one weigh 1
+func (n GraphNode) EdgeWeight() float64 {
+	if n.Weight <= 0 {
+		return 1
 	}
+	return n.Weight
 }
 
 type Graph struct {
@@ -31,9 +47,89 @@ func (d *Graph) addEdge(src, dest GraphNode) {
 	if _, ok := d.G[dest.NodeValue]; !ok {
 		d.G[dest.NodeValue] = []GraphNode{}
 	}
+	for i, n := range d.G[src.NodeValue] {
+		if n.NodeValue == dest.NodeValue {
+			d.G[src.NodeValue][i].Weight = n.EdgeWeight() + dest.EdgeWeight()
+			return
+		}
+	}
 	d.G[src.NodeValue] = append(d.G[src.NodeValue], dest)
 }
 
+/*
+Nodes returns the nodes in lexical order. Scores are sums of floats, which
+depend on the order they are added in, so algorithms go through the nodes
+in this order rather than the order of the map to score the same every run.
+*/
+func (d *Graph) Nodes() []string {
+	nodes := make([]string, 0, len(d.G))
+	for node := range d.G {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+// AddNode adds a node without edges, so it is scored like the linked ones
+func (d *Graph) AddNode(nodeKey string) {
+	if _, ok := d.G[nodeKey]; !ok {
+		d.G[nodeKey] = []GraphNode{}
+	}
+}
+
+// Clone returns a copy of the graph, changing one leaves the other as it is
+func (d *Graph) Clone() *Graph {
+	clone := NewGraph()
+	for node, edges := range d.G {
+		clone.G[node] = append([]GraphNode{}, edges...)
+	}
+	return clone
+}
+
+// RemoveNodes removes the nodes along with the edges leaving and reaching them
+func (d *Graph) RemoveNodes(nodeKeys ...string) {
+	removed := make(map[string]bool, len(nodeKeys))
+	for _, key := range nodeKeys {
+		removed[key] = true
+		delete(d.G, key)
+	}
+	for node, edges := range d.G {
+		kept := edges[:0:0]
+		for _, n := range edges {
+			if !removed[n.NodeValue] {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) != len(edges) {
+			d.G[node] = kept
+		}
+	}
+}
+
+// RemoveOutEdges removes the edges leaving the node, the node itself stays
+func (d *Graph) RemoveOutEdges(nodeKey string) {
+	if _, ok := d.G[nodeKey]; ok {
+		d.G[nodeKey] = []GraphNode{}
+	}
+}
+
+func (d *Graph) AddEdge(src, dest string) {
+	d.addEdge(NewGraphNode(src), NewGraphNode(dest))
+}
+
+func (d *Graph) AddWeightedEdge(src, dest string, weight float64) {
+	d.addEdge(NewGraphNode(src), GraphNode{NodeValue: dest, Weight: weight})
+}
+
+// Sum of the weights of the edges leaving the node
+func (d *Graph) GetOutWeightOfNode(nodeKey string) float64 {
+	var weight float64
+	for _, n := range d.G[nodeKey] {
+		weight += n.EdgeWeight()
+	}
+	return weight
+}
+
 func (d *Graph) GetOutNodesOfNode(nodeKey string) []GraphNode {
 	return d.G[nodeKey]
 }
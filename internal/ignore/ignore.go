@@ -0,0 +1,320 @@
+package ignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+const GIT_IGNORE_FILE = ".gitignore"
+
+// Patterns for wingman only, on top of the ones of git
+const WINGMAN_IGNORE_FILE = ".wingmanignore"
+
+// Read in every directory, a pattern of the later file wins over the earlier one
+var ignoreFiles = []string{GIT_IGNORE_FILE, WINGMAN_IGNORE_FILE}
+
+// IsIgnoreFile tells whether the file at p holds ignore patterns, see Matcher
+func IsIgnoreFile(p string) bool {
+	return slices.Contains(ignoreFiles, filepath.Base(p)) || strings.HasSuffix(p, filepath.Join(".git", "info", "exclude"))
+}
+
+/*
+rule is one pattern of an ignore file. Patterns with a slash other than a
+trailing one are matched against the path relative to the directory of the
+ignore file, the others against the base name, at any depth.
+*/
+type rule struct {
+	Negate   bool // !pattern, the path is included again
+	DirOnly  bool // pattern/, only matches directories
+	Anchored bool
+	Regex    *regexp.Regexp
+}
+
+func (r rule) matches(rel string, isDir bool) bool {
+	if r.DirOnly && !isDir {
+		return false
+	}
+	if r.Anchored {
+		return r.Regex.MatchString(rel)
+	}
+	return r.Regex.MatchString(path.Base(rel))
+}
+
+// parseRule reads one line of an ignore file, false for blank lines and comments
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are dropped unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.Negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		r.DirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		r.Anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	regex, err := regexp.Compile("^" + patternToRegex(line) + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.Regex = regex
+	return r, true
+}
+
+/*
+patternToRegex translates a pattern to a regular expression. A ** segment
+matches any number of directories: a leading one makes the rest match at any
+depth, a trailing one matches everything inside and one in the middle any
+directories in between.
+*/
+func patternToRegex(pattern string) string {
+	segments := strings.Split(pattern, "/")
+
+	var b strings.Builder
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		if segment == "**" {
+			if last {
+				b.WriteString(".*")
+			} else {
+				b.WriteString("(?:.*/)?")
+			}
+			continue
+		}
+
+		b.WriteString(globToRegex(segment))
+		if !last {
+			b.WriteString("/")
+		}
+	}
+	return b.String()
+}
+
+// globToRegex translates the wildcards of a path segment, none of which matches a slash
+func globToRegex(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString("[^/]*")
+		case '?':
+			b.WriteString("[^/]")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(glob[i])))
+			} else {
+				b.WriteString(regexp.QuoteMeta("\\"))
+			}
+		case '[':
+			end := classEnd(glob, i)
+			if end < 0 {
+				b.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+
+			class := glob[i+1 : end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, "[", "\\[") + "]")
+			i = end
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// Index of the ] closing the class opened at start, -1 when it is not closed
+func classEnd(glob string, start int) int {
+	i := start + 1
+	if i < len(glob) && (glob[i] == '!' || glob[i] == '^') {
+		i++
+	}
+	// A ] right after the opening bracket is part of the class
+	if i < len(glob) && glob[i] == ']' {
+		i++
+	}
+	for ; i < len(glob); i++ {
+		if glob[i] == ']' {
+			return i
+		}
+	}
+	return -1
+}
+
+func readRules(path string) []rule {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []rule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if r, ok := parseRule(scanner.Text()); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+/*
+Matcher tells which paths under Root are ignored, with the semantics of git:
+the .gitignore and .wingmanignore files of every directory, and the
+.git/info/exclude of Root, apply to the paths below them, the patterns of a
+deeper file win over the ones above it and the last matching pattern of a
+file wins. Ignore files are read once, when first needed, so a Matcher is
+made for a walk. It is not safe for concurrent use.
+*/
+type Matcher struct {
+	Root  string
+	rules map[string][]rule // Directory relative to Root, "" for Root, vs its patterns
+	files []string          // Ignore files looked for, whether they exist or not
+}
+
+/*
+WorkTreeRoot returns the top level of the git work tree containing dir, the
+closest directory with a .git entry, or dir itself outside of a work tree.
+A Matcher rooted there applies the ignore files above dir as git does.
+*/
+func WorkTreeRoot(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	for current := dir; ; {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return dir
+		}
+		current = parent
+	}
+}
+
+func NewMatcher(root string) *Matcher {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Matcher{
+		Root:  root,
+		rules: make(map[string][]rule),
+	}
+}
+
+func (m *Matcher) rulesOf(dir string) []rule {
+	if rules, ok := m.rules[dir]; ok {
+		return rules
+	}
+
+	var paths []string
+	if dir == "" {
+		paths = append(paths, filepath.Join(m.Root, ".git", "info", "exclude"))
+	}
+	for _, name := range ignoreFiles {
+		paths = append(paths, filepath.Join(m.Root, filepath.FromSlash(dir), name))
+	}
+
+	var rules []rule
+	for _, path := range paths {
+		rules = append(rules, readRules(path)...)
+	}
+	m.files = append(m.files, paths...)
+	m.rules[dir] = rules
+	return rules
+}
+
+/*
+Files returns the ignore files the matcher looked for so far, including the
+ones which do not exist, so creating one can be noticed too.
+*/
+func (m *Matcher) Files() []string {
+	files := slices.Clone(m.files)
+	slices.Sort(files)
+	return files
+}
+
+// Path relative to Root with forward slashes, false for Root itself and paths outside of it
+func (m *Matcher) relative(p string) (string, bool) {
+	abs, err := filepath.Abs(p)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(m.Root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Whether the patterns ignore rel itself, whatever its parent directories
+func (m *Matcher) matchSelf(rel string, isDir bool) bool {
+	ignored := false
+	parts := strings.Split(rel, "/")
+	for i := range parts {
+		dir := strings.Join(parts[:i], "/")
+		relToDir := strings.Join(parts[i:], "/")
+		for _, r := range m.rulesOf(dir) {
+			if r.matches(relToDir, isDir) {
+				ignored = !r.Negate
+			}
+		}
+	}
+	return ignored
+}
+
+/*
+IgnoredEntry tells whether a path met during a walk of Root is ignored. Its
+parent directories are not checked, a walk does not enter ignored ones.
+*/
+func (m *Matcher) IgnoredEntry(p string, isDir bool) bool {
+	rel, ok := m.relative(p)
+	return ok && m.matchSelf(rel, isDir)
+}
+
+/*
+Ignored tells whether any path is ignored. As with git a file in an ignored
+directory is ignored, even if a pattern includes it again. Paths outside of
+Root are never ignored.
+*/
+func (m *Matcher) Ignored(p string, isDir bool) bool {
+	rel, ok := m.relative(p)
+	if !ok {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		if m.matchSelf(strings.Join(parts[:i], "/"), i < len(parts) || isDir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,254 @@
+package language
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+/*
+CargoManifest holds the parts of a Cargo.toml used to map crate names to
+directories. Names are the ones used in rust paths, with "-" replaced by "_".
+*/
+type CargoManifest struct {
+	Path        string
+	PackageName string
+	// Globs of the workspace members, relative to the manifest
+	Members []string
+	// Path dependencies, from the name they are imported as to their directory
+	Dependencies map[string]string
+	// Dependencies declared with "workspace = true", resolved against the workspace manifest
+	WorkspaceDependencies []string
+	// [workspace.dependencies] of a workspace manifest
+	SharedDependencies map[string]string
+}
+
+var (
+	// [package] and [[bin]] table headers
+	tomlTableRegex     = regexp.MustCompile(`^\[\[?\s*([^\]]+?)\s*\]\]?$`)
+	tomlKeyValueRegex  = regexp.MustCompile(`^([A-Za-z0-9_.\-"]+)\s*=\s*(.*)$`)
+	tomlStringRegex    = regexp.MustCompile(`"([^"]*)"`)
+	tomlInlineKeyRegex = regexp.MustCompile(`([A-Za-z0-9_\-]+)\s*=\s*("[^"]*"|true|false)`)
+)
+
+var (
+	cargoMu    sync.Mutex
+	cargoCache = make(map[string]*CargoManifest)
+)
+
+func rustCrateName(name string) string {
+	return strings.ReplaceAll(name, "-", "_")
+}
+
+func tomlString(value string) string {
+	if m := tomlStringRegex.FindStringSubmatch(value); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// Values of an inline table, { path = "../core", package = "core-lib" }
+func tomlInlineTable(value string) map[string]string {
+	table := make(map[string]string)
+	for _, m := range tomlInlineKeyRegex.FindAllStringSubmatch(value, -1) {
+		table[m[1]] = strings.Trim(m[2], `"`)
+	}
+	return table
+}
+
+/*
+ParseCargoManifest reads the package name, workspace members and path
+dependencies of the Cargo.toml at path. It understands the subset of TOML
+manifests are written in: tables, strings, arrays spanning lines and inline
+tables.
+*/
+func ParseCargoManifest(path string) (*CargoManifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	manifest := &CargoManifest{
+		Path:               path,
+		Dependencies:       make(map[string]string),
+		SharedDependencies: make(map[string]string),
+	}
+	dir := filepath.Dir(path)
+
+	// name is what the dependency is imported as, even when "package" renames it
+	addDependency := func(shared bool, name string, table map[string]string) {
+		switch {
+		case table["path"] != "" && shared:
+			manifest.SharedDependencies[rustCrateName(name)] = filepath.Join(dir, table["path"])
+		case table["path"] != "":
+			manifest.Dependencies[rustCrateName(name)] = filepath.Join(dir, table["path"])
+		case table["workspace"] == "true" && !shared:
+			manifest.WorkspaceDependencies = append(manifest.WorkspaceDependencies, rustCrateName(name))
+		}
+	}
+
+	var table string
+	// [dependencies.name] tables are collected until the next table starts
+	var dependencyName string
+	dependencyTable := make(map[string]string)
+	flushDependency := func() {
+		if dependencyName != "" {
+			addDependency(strings.HasPrefix(table, "workspace."), dependencyName, dependencyTable)
+		}
+		dependencyName = ""
+		dependencyTable = make(map[string]string)
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Arrays and inline tables may span lines
+		for strings.Count(line, "[")+strings.Count(line, "{") > strings.Count(line, "]")+strings.Count(line, "}") && scanner.Scan() {
+			line += " " + strings.TrimSpace(scanner.Text())
+		}
+
+		if m := tomlTableRegex.FindStringSubmatch(line); m != nil {
+			flushDependency()
+			table = m[1]
+			for _, prefix := range []string{"dependencies.", "dev-dependencies.", "build-dependencies.", "workspace.dependencies."} {
+				if strings.HasPrefix(table, prefix) {
+					dependencyName = strings.Trim(strings.TrimPrefix(table, prefix), `"`)
+					table = strings.TrimSuffix(prefix, ".")
+				}
+			}
+			continue
+		}
+
+		m := tomlKeyValueRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, value := strings.Trim(m[1], `"`), m[2]
+
+		switch {
+		case dependencyName != "":
+			dependencyTable[key] = strings.Trim(strings.TrimSpace(value), `"`)
+		case table == "package" && key == "name":
+			manifest.PackageName = rustCrateName(tomlString(value))
+		case table == "workspace" && key == "members":
+			for _, member := range tomlStringRegex.FindAllStringSubmatch(value, -1) {
+				manifest.Members = append(manifest.Members, member[1])
+			}
+		case table == "dependencies" || table == "dev-dependencies" || table == "build-dependencies":
+			addDependency(false, key, tomlInlineTable(value))
+		case table == "workspace.dependencies":
+			addDependency(true, key, tomlInlineTable(value))
+		}
+	}
+	flushDependency()
+
+	return manifest, scanner.Err()
+}
+
+// Manifests are cached by path and modification time
+func loadCargoManifest(path string) *CargoManifest {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil
+	}
+
+	key := path + "@" + fi.ModTime().String()
+	cargoMu.Lock()
+	manifest, ok := cargoCache[key]
+	cargoMu.Unlock()
+	if ok {
+		return manifest
+	}
+
+	manifest, err = ParseCargoManifest(path)
+	if err != nil {
+		return nil
+	}
+	cargoMu.Lock()
+	cargoCache[key] = manifest
+	cargoMu.Unlock()
+	return manifest
+}
+
+// The manifest of the closest Cargo.toml with a [package] above path
+func findCrateManifest(path string) *CargoManifest {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if manifest := loadCargoManifest(filepath.Join(dir, "Cargo.toml")); manifest != nil && manifest.PackageName != "" {
+			return manifest
+		}
+		if dir == filepath.Dir(dir) {
+			return nil
+		}
+	}
+}
+
+// The manifest of the workspace the crate at crateDir is a member of
+func findWorkspaceManifest(crateDir string) *CargoManifest {
+	for dir := crateDir; ; dir = filepath.Dir(dir) {
+		if manifest := loadCargoManifest(filepath.Join(dir, "Cargo.toml")); manifest != nil && len(manifest.Members) > 0 {
+			// The root package of a workspace is a member without being listed
+			if dir == crateDir || slices.Contains(manifest.workspaceMembers(), crateDir) {
+				return manifest
+			}
+		}
+		if dir == filepath.Dir(dir) {
+			return nil
+		}
+	}
+}
+
+// Directories of the workspace members, member globs expanded
+func (m *CargoManifest) workspaceMembers() []string {
+	dir := filepath.Dir(m.Path)
+
+	var members []string
+	for _, member := range m.Members {
+		matches, err := filepath.Glob(filepath.Join(dir, filepath.FromSlash(member)))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			if _, err := os.Stat(filepath.Join(match, "Cargo.toml")); err == nil {
+				members = append(members, match)
+			}
+		}
+	}
+	return members
+}
+
+/*
+rustCrates returns the crates a file of the crate described by manifest can
+name in its paths, mapped to their directories: the crate itself, the other
+members of its workspace and its path dependencies.
+*/
+func rustCrates(manifest *CargoManifest) map[string]string {
+	crateDir := filepath.Dir(manifest.Path)
+	crates := map[string]string{manifest.PackageName: crateDir}
+
+	if workspace := findWorkspaceManifest(crateDir); workspace != nil {
+		for _, member := range workspace.workspaceMembers() {
+			if m := loadCargoManifest(filepath.Join(member, "Cargo.toml")); m != nil && m.PackageName != "" {
+				crates[m.PackageName] = member
+			}
+		}
+		for _, name := range manifest.WorkspaceDependencies {
+			if dir, ok := workspace.SharedDependencies[name]; ok {
+				crates[name] = dir
+			}
+		}
+	}
+
+	for name, dir := range manifest.Dependencies {
+		crates[name] = dir
+	}
+	return crates
+}
@@ -17,3 +17,15 @@ func (d *DefaultStrategy) resolveImportNodes(args ResolveImportNodesArgs) []type
 func (d *DefaultStrategy) GetNodeImportList() ([]types.NodeImport, error) {
 	return d.resolveImportNodes(ResolveImportNodesArgs{}), nil
 }
+
+func (d *DefaultStrategy) GetNodePackages() []string {
+	return []string{}
+}
+
+func (d *DefaultStrategy) GetNodeSignatures() []string {
+	return []string{}
+}
+
+func (d *DefaultStrategy) GetNodeSymbols() types.NodeSymbols {
+	return types.NodeSymbols{}
+}
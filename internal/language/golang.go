@@ -2,9 +2,12 @@ package language
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"slices"
 	"strings"
 
+	"github.com/manosriram/wingman/internal/gomod"
 	"github.com/manosriram/wingman/internal/types"
 	"github.com/manosriram/wingman/internal/utils"
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -13,11 +16,17 @@ import (
 /*
 GolangStrategy implements LangStrategy.
 
- 1. Internal imports are considered by getting the imports of each node and
-    checking the import, if it starts with the module name from go.mod file,
-    it is an internal import and added to the graph
+ 1. Every non test file is registered in PkgPaths under the import path of
+    its package, the module path from the closest go.mod followed by the
+    directory of the file relative to the module root.
 
- 2. The list of imports is used to run an algorithm (pagerank) and find out the most important files.
+ 2. The import specs of a file are resolved with a gomod.Resolver, an import
+    path of the module, of another module of the go.work workspace or of a
+    module replaced by a local directory maps to every file of the package.
+    Aliased, dot and blank imports resolve by their path like any other
+    import, the standard library and other dependencies are ignored.
+
+ 3. The list of imports is used to run an algorithm (pagerank) and find out the most important files.
     The goal of this method is to send the signatures of the most important files for the
     repo context tree to the LLM.
 */
@@ -26,7 +35,6 @@ type GolangStrategy struct {
 	NodePath string
 	PkgPaths map[string][]string
 	Parser   utils.TreeSitterParserType
-	Visit    map[*tree_sitter.Node]bool
 }
 
 // func NewGolangStrategy(data []byte, path string, parser utils.TreeSitterParserType) *GolangStrategy {
@@ -35,56 +43,70 @@ func NewGolangStrategy(args StrategyArgs) *GolangStrategy {
 		NodeData: args.NodeData,
 		NodePath: args.NodePath,
 		Parser:   args.Parser,
-		Visit:    make(map[*tree_sitter.Node]bool),
 		PkgPaths: args.PkgPaths,
 	}
 }
 
-func (g *GolangStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+/*
+Files of the package with the given import path. Without PkgPaths, as when
+a single file is parsed, the package directory is read from the file system.
+*/
+func (g *GolangStrategy) resolveImportPath(importPath string, args ResolveImportNodesArgs) []string {
+	dir, ok := args.GolangResolver.Resolve(importPath)
+	if !ok {
+		return nil
+	}
+	if g.PkgPaths != nil {
+		// A replaced module may declare another path than the one it replaces
+		key, err := gomod.ImportPath(dir)
+		if err != nil {
+			return nil
+		}
+		return g.PkgPaths[key]
+	}
 
-	rootNode := args.RootNode
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
 
-	g.Visit[rootNode] = true
-	// modFileData := args.GolangModFileData
+	var paths []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".go") && !strings.HasSuffix(name, "_test.go") {
+			paths = append(paths, filepath.Join(dir, name))
+		}
+	}
+	return paths
+}
 
+func (g *GolangStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+	rootNode := args.RootNode
 	imports := []types.NodeImport{}
 
-	if rootNode.Kind() == "call_expression" {
-		funcNode := rootNode.ChildByFieldName("function")
-		if funcNode != nil {
-			callExpr := string(g.NodeData[funcNode.StartByte():funcNode.EndByte()])
-			if strings.Contains(callExpr, ".") {
-				pkg := strings.Split(callExpr, ".")[0]
-
-				// We now know which file/pkgs this imports from
-				// Build the graph upon this knowledge, such that the imports being populated are meaningful
-				// Return valid imports from this function on calculation, and the graph should build itself correctly
-				if _, ok := g.PkgPaths[pkg]; ok {
-					for _, z := range g.PkgPaths[pkg] {
-						n := types.NodeImport{
-							ImportPackage: z,
-							FilePath:      g.NodePath,
-						}
-
-						if !slices.Contains(imports, n) {
-							imports = append(imports, n)
-						}
-					}
+	if rootNode.Kind() == "import_spec" {
+		if pathNode := rootNode.ChildByFieldName("path"); pathNode != nil {
+			importPath := strings.Trim(string(g.NodeData[pathNode.StartByte():pathNode.EndByte()]), "\"`")
+			for _, path := range g.resolveImportPath(importPath, args) {
+				n := types.NodeImport{
+					ImportPackage: path,
+					FilePath:      g.NodePath,
+				}
+				if path != g.NodePath && !slices.Contains(imports, n) {
+					imports = append(imports, n)
 				}
 			}
 		}
+		return imports
 	}
 
 	for i := uint(0); i < rootNode.ChildCount(); i++ {
 		args.RootNode = rootNode.Child(i)
-		imports = append(imports, g.resolveImportNodes(args)...)
-		// for _, i := range imports {
-		// if !slices.Contains(im, i) {
-		// imports = append(imports, i)
-		// }
-		// }
-	}
-	g.Visit[rootNode] = false
+		for _, n := range g.resolveImportNodes(args) {
+			if !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
 	return imports
 }
 
@@ -96,30 +118,86 @@ func (g *GolangStrategy) GetNodeImportList() ([]types.NodeImport, error) {
 	}
 	defer tree.Close()
 
-	modFilePath, err := utils.FindGoModPath(g.NodePath)
+	resolver, err := gomod.NewResolver(g.NodePath)
 	if err != nil {
 		return []types.NodeImport{}, errors.New("Error reading go.mod file")
 	}
-	modFile, err := utils.ReadGoModFile(modFilePath)
+
+	rootNode := tree.RootNode()
+
+	return g.resolveImportNodes(ResolveImportNodesArgs{
+		RootNode:          rootNode,
+		GolangModFileData: resolver.Main.Path,
+		GolangResolver:    resolver,
+	}), nil
+}
+
+// Go files are registered under the import path of their package, test files are not part of it for importers
+func (g *GolangStrategy) GetNodePackages() []string {
+	if strings.HasSuffix(g.NodePath, "_test.go") {
+		return []string{}
+	}
+
+	importPath, err := gomod.ImportPath(filepath.Dir(g.NodePath))
 	if err != nil {
-		return []types.NodeImport{}, errors.New("Error reading go.mod file")
+		return []string{}
 	}
+	return []string{importPath}
+}
 
-	modFileSplit := strings.Split(string(modFile), "\n")
-	if len(modFileSplit) < 1 {
-		return []types.NodeImport{}, errors.New("Error reading go.mod file")
+func getFunctionInfo(node *tree_sitter.Node, source []byte) (name string, params string) {
+	if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+		name = string(source[nameNode.StartByte():nameNode.EndByte()])
+	}
+	if paramsNode := node.ChildByFieldName("parameters"); paramsNode != nil {
+		params = string(source[paramsNode.StartByte():paramsNode.EndByte()])
 	}
+	return name, params
+}
 
-	moduleNameSplit := strings.Split(modFileSplit[0], " ")
-	if len(moduleNameSplit) < 1 {
-		return []types.NodeImport{}, errors.New("Error reading go.mod file")
+// Identifiers of a Go file which may name a symbol of the repository
+var golangReferenceKinds = map[string]bool{"identifier": true, "type_identifier": true, "field_identifier": true}
+
+func getGolangDefinitions(node *tree_sitter.Node, source []byte, path string) []types.Symbol {
+	var definitions []types.Symbol
+
+	switch node.Kind() {
+	case "function_declaration", "method_declaration":
+		fnName, fnParams := getFunctionInfo(node, source)
+		if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+			definitions = append(definitions, newNodeSymbol(path, node, nameNode, source, fnName+fnParams))
+		}
+	case "type_spec":
+		if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+			definitions = append(definitions, newNodeSymbol(path, node, nameNode, source, "type "+string(source[nameNode.StartByte():nameNode.EndByte()])))
+		}
 	}
-	modFileData := moduleNameSplit[1]
 
-	rootNode := tree.RootNode()
+	for i := uint(0); i < node.ChildCount(); i++ {
+		definitions = append(definitions, getGolangDefinitions(node.Child(i), source, path)...)
+	}
+	return definitions
+}
 
-	return g.resolveImportNodes(ResolveImportNodesArgs{
-		RootNode:          rootNode,
-		GolangModFileData: modFileData,
-	}), nil
+func (g *GolangStrategy) GetNodeSignatures() []string {
+	tree := g.Parser.GetLanguageParser(types.GOLANG).Parse(g.NodeData, nil)
+	if tree == nil {
+		return []string{}
+	}
+	defer tree.Close()
+
+	return signaturesOf(getGolangDefinitions(tree.RootNode(), g.NodeData, g.NodePath))
+}
+
+func (g *GolangStrategy) GetNodeSymbols() types.NodeSymbols {
+	tree := g.Parser.GetLanguageParser(types.GOLANG).Parse(g.NodeData, nil)
+	if tree == nil {
+		return types.NodeSymbols{}
+	}
+	defer tree.Close()
+
+	return types.NodeSymbols{
+		Definitions: getGolangDefinitions(tree.RootNode(), g.NodeData, g.NodePath),
+		References:  collectReferences(tree.RootNode(), g.NodeData, golangReferenceKinds),
+	}
 }
@@ -0,0 +1,244 @@
+package language
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// PkgPaths keys of javascript modules, the absolute path of the file without its extension
+const JS_PKG_PREFIX = "js:"
+
+// Extensions a module specifier may leave out, in the order node tries them
+var jsExtensions = []string{".js", ".mjs", ".cjs", ".jsx"}
+
+/*
+JavaScriptStrategy implements LangStrategy for ES modules and CommonJS.
+
+ 1. Every file is registered in PkgPaths under its absolute path without the
+    extension, index files also under their directory, which is how node
+    resolves extension-less and directory specifiers.
+
+ 2. Relative specifiers of import and export declarations, dynamic import()
+    and require() calls are resolved against these keys. Bare specifiers
+    ("react") point to dependencies and are ignored.
+*/
+type JavaScriptStrategy struct {
+	NodeData   []byte
+	NodePath   string
+	PkgPaths   map[string][]string
+	Parser     utils.TreeSitterParserType
+	Language   types.Language
+	Extensions []string
+}
+
+func NewJavaScriptStrategy(args StrategyArgs) *JavaScriptStrategy {
+	return &JavaScriptStrategy{
+		NodeData:   args.NodeData,
+		NodePath:   args.NodePath,
+		PkgPaths:   args.PkgPaths,
+		Parser:     args.Parser,
+		Language:   types.JAVASCRIPT,
+		Extensions: jsExtensions,
+	}
+}
+
+// Trims the longest of extensions path ends with, so "x.d.ts" loses ".d.ts" and not ".ts"
+func trimExtension(path string, extensions []string) string {
+	longest := ""
+	for _, ext := range extensions {
+		if strings.HasSuffix(path, ext) && len(ext) > len(longest) {
+			longest = ext
+		}
+	}
+	return strings.TrimSuffix(path, longest)
+}
+
+func (j *JavaScriptStrategy) GetNodePackages() []string {
+	path := trimExtension(j.NodePath, j.Extensions)
+	packages := []string{JS_PKG_PREFIX + path}
+	if filepath.Base(path) == "index" {
+		packages = append(packages, JS_PKG_PREFIX+filepath.Dir(path))
+	}
+	return packages
+}
+
+func isRelativeSpecifier(specifier string) bool {
+	return specifier == "." || specifier == ".." || strings.HasPrefix(specifier, "./") || strings.HasPrefix(specifier, "../")
+}
+
+/*
+resolveModulePath returns the files of an absolute module path. The path is
+looked up as is first since names like "x.service" are not extensions.
+*/
+func (j *JavaScriptStrategy) resolveModulePath(path string) []string {
+	if paths, ok := j.PkgPaths[JS_PKG_PREFIX+path]; ok {
+		return paths
+	}
+	if trimmed := trimExtension(path, j.Extensions); trimmed != path {
+		return j.PkgPaths[JS_PKG_PREFIX+trimmed]
+	}
+	return nil
+}
+
+func (j *JavaScriptStrategy) resolveSpecifier(specifier string) []string {
+	if !isRelativeSpecifier(specifier) {
+		return nil
+	}
+	return j.resolveModulePath(filepath.Join(filepath.Dir(j.NodePath), filepath.FromSlash(specifier)))
+}
+
+// The value of a string literal, "" for template strings with substitutions
+func stringValue(node *tree_sitter.Node, source []byte) string {
+	if node == nil || (node.Kind() != "string" && node.Kind() != "template_string") {
+		return ""
+	}
+	if node.NamedChildCount() > 1 {
+		return ""
+	}
+	if node.NamedChildCount() == 1 && node.NamedChild(0).Kind() != "string_fragment" {
+		return ""
+	}
+
+	text := string(source[node.StartByte():node.EndByte()])
+	if len(text) < 2 {
+		return ""
+	}
+	return text[1 : len(text)-1]
+}
+
+/*
+moduleSpecifier returns the module specifier of an import/export
+declaration, dynamic import() or require() call, and of the typescript
+import x = require() form.
+*/
+func moduleSpecifier(node *tree_sitter.Node, source []byte) string {
+	switch node.Kind() {
+	case "import_statement", "export_statement", "import_require_clause":
+		return stringValue(node.ChildByFieldName("source"), source)
+	case "call_expression":
+		function := node.ChildByFieldName("function")
+		arguments := node.ChildByFieldName("arguments")
+		if function == nil || arguments == nil || arguments.NamedChildCount() == 0 {
+			return ""
+		}
+		if function.Kind() == "import" || string(source[function.StartByte():function.EndByte()]) == "require" {
+			return stringValue(arguments.NamedChild(0), source)
+		}
+	}
+	return ""
+}
+
+func (j *JavaScriptStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+	rootNode := args.RootNode
+	imports := []types.NodeImport{}
+
+	if specifier := moduleSpecifier(rootNode, j.NodeData); specifier != "" {
+		for _, path := range j.resolveSpecifier(specifier) {
+			n := types.NodeImport{
+				ImportPackage: path,
+				FilePath:      j.NodePath,
+			}
+			if path != j.NodePath && !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+
+	for i := uint(0); i < rootNode.ChildCount(); i++ {
+		args.RootNode = rootNode.Child(i)
+		for _, n := range j.resolveImportNodes(args) {
+			if !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+	return imports
+}
+
+func (j *JavaScriptStrategy) parse() (*tree_sitter.Tree, error) {
+	parser := j.Parser.GetLanguageParser(j.Language)
+	if parser == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	tree := parser.Parse(j.NodeData, nil)
+	if tree == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	return tree, nil
+}
+
+func (j *JavaScriptStrategy) GetNodeImportList() ([]types.NodeImport, error) {
+	tree, err := j.parse()
+	if err != nil {
+		return []types.NodeImport{}, err
+	}
+	defer tree.Close()
+
+	return j.resolveImportNodes(ResolveImportNodesArgs{
+		RootNode: tree.RootNode(),
+	}), nil
+}
+
+var javaScriptReferenceKinds = map[string]bool{"identifier": true, "property_identifier": true, "shorthand_property_identifier": true}
+
+func (j *JavaScriptStrategy) getDefinitions(node *tree_sitter.Node) []types.Symbol {
+	var definitions []types.Symbol
+
+	if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+		switch node.Kind() {
+		case "function_declaration", "generator_function_declaration", "method_definition":
+			fnName, fnParams := getFunctionInfo(node, j.NodeData)
+			definitions = append(definitions, newNodeSymbol(j.NodePath, node, nameNode, j.NodeData, fnName+fnParams))
+		case "class_declaration":
+			definitions = append(definitions, newNodeSymbol(j.NodePath, node, nameNode, j.NodeData, "class "+string(j.NodeData[nameNode.StartByte():nameNode.EndByte()])))
+		case "variable_declarator":
+			// const handler = (req, res) => {...}
+			value := node.ChildByFieldName("value")
+			if value != nil && (value.Kind() == "arrow_function" || value.Kind() == "function_expression") {
+				fnName, _ := getFunctionInfo(node, j.NodeData)
+				_, fnParams := getFunctionInfo(value, j.NodeData)
+				if fnParams == "" {
+					// A single arrow function parameter may be written without parentheses
+					if param := value.ChildByFieldName("parameter"); param != nil {
+						fnParams = "(" + string(j.NodeData[param.StartByte():param.EndByte()]) + ")"
+					}
+				}
+				definitions = append(definitions, newNodeSymbol(j.NodePath, node, nameNode, j.NodeData, fnName+fnParams))
+			}
+		}
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		definitions = append(definitions, j.getDefinitions(node.Child(i))...)
+	}
+	return definitions
+}
+
+func (j *JavaScriptStrategy) GetNodeSignatures() []string {
+	tree, err := j.parse()
+	if err != nil {
+		return []string{}
+	}
+	defer tree.Close()
+
+	return signaturesOf(j.getDefinitions(tree.RootNode()))
+}
+
+func (j *JavaScriptStrategy) GetNodeSymbols() types.NodeSymbols {
+	tree, err := j.parse()
+	if err != nil {
+		return types.NodeSymbols{}
+	}
+	defer tree.Close()
+
+	return types.NodeSymbols{
+		Definitions: j.getDefinitions(tree.RootNode()),
+		References:  collectReferences(tree.RootNode(), j.NodeData, javaScriptReferenceKinds),
+	}
+}
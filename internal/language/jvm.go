@@ -0,0 +1,376 @@
+package language
+
+import (
+	"errors"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// PkgPaths keys of jvm types, their fully qualified name ("jvm:com.acme.orders.Order"), "jvm:com.acme.orders.*" for the package
+const JVM_PKG_PREFIX = "jvm:"
+
+var (
+	kotlinPackageRegex     = regexp.MustCompile(`(?m)^[ \t]*package[ \t]+([\w.]+)`)
+	kotlinImportRegex      = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+([\w.]+?)(\.\*)?(?:[ \t]+as[ \t]+\w+)?[ \t]*;?[ \t]*$`)
+	kotlinDeclarationRegex = regexp.MustCompile(`(?m)^([ \t]*)(?:(?:public|private|internal|protected|open|abstract|sealed|data|enum|annotation|inner|value|inline|expect|actual|final|fun|companion)[ \t]+)*(class|interface|object|typealias)[ \t]+([A-Za-z_]\w*)`)
+	kotlinFunctionRegex    = regexp.MustCompile(`(?m)^([ \t]*)(?:(?:public|private|internal|protected|open|abstract|override|suspend|inline|operator|infix|tailrec|external|expect|actual|final)[ \t]+)*fun[ \t]+(?:<[^>(]*>[ \t]*)?(?:[\w.<>?, ]+\.)?([A-Za-z_]\w*)\s*\(`)
+	kotlinTypeNameRegex    = regexp.MustCompile(`\b[A-Z]\w*\b`)
+)
+
+// Quotes of kotlin strings and char literals, so '"' does not start a string
+const KOTLIN_QUOTES = `"'`
+
+// Kinds of java declarations and the word their signature starts with
+var javaTypeDeclarations = map[string]string{
+	"class_declaration":           "class",
+	"interface_declaration":       "interface",
+	"enum_declaration":            "enum",
+	"record_declaration":          "record",
+	"annotation_type_declaration": "@interface",
+}
+
+type jvmImport struct {
+	Path     string
+	Wildcard bool
+}
+
+// What the strategy needs to know about a java or kotlin file
+type jvmUnit struct {
+	Package    string
+	Imports    []jvmImport
+	Declared   []string // Top level types, and functions in kotlin
+	References []string // Capitalized names, which may be types of the same package
+}
+
+/*
+JVMStrategy implements LangStrategy for Java and Kotlin. Java files are
+parsed with tree-sitter, kotlin ones, which have no grammar in the parser
+set, are scanned lexically.
+
+ 1. Every file is registered in PkgPaths under the fully qualified names of
+    its top level declarations and of its file name, and under the wildcard
+    key of its package. The package is the one declared, or the directory
+    relative to a src/<set>/java or src/<set>/kotlin source root when there
+    is none.
+
+ 2. Imports are resolved against these keys, taking the longest prefix of
+    the imported name so nested classes and static imports resolve to the
+    file of their outer class, wildcard imports to every file of the
+    package. Types of the same package need no import, so capitalized names
+    matching one are resolved too.
+*/
+type JVMStrategy struct {
+	NodeData []byte
+	NodePath string
+	PkgPaths map[string][]string
+	Parser   utils.TreeSitterParserType
+	Language types.Language
+}
+
+func NewJVMStrategy(args StrategyArgs) *JVMStrategy {
+	return &JVMStrategy{
+		NodeData: args.NodeData,
+		NodePath: args.NodePath,
+		PkgPaths: args.PkgPaths,
+		Parser:   args.Parser,
+		Language: args.StrategyLanguage,
+	}
+}
+
+func jvmQualifiedName(pkg string, name string) string {
+	if pkg == "" {
+		return name
+	}
+	return pkg + "." + name
+}
+
+// Package of a file without a package declaration, from its directory under the source root
+func jvmSourcePackage(path string) string {
+	parts := strings.Split(filepath.ToSlash(filepath.Dir(path)), "/")
+	for i := len(parts) - 1; i >= 2; i-- {
+		if (parts[i] == "java" || parts[i] == "kotlin") && parts[i-2] == "src" {
+			return strings.Join(parts[i+1:], ".")
+		}
+	}
+	return ""
+}
+
+func (j *JVMStrategy) nodeText(node *tree_sitter.Node) string {
+	return string(j.NodeData[node.StartByte():node.EndByte()])
+}
+
+func (j *JVMStrategy) parse() (*tree_sitter.Tree, error) {
+	parser := j.Parser.GetLanguageParser(types.JAVA)
+	if parser == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	tree := parser.Parse(j.NodeData, nil)
+	if tree == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	return tree, nil
+}
+
+func (j *JVMStrategy) collectJavaReferences(node *tree_sitter.Node, references map[string]bool) {
+	if node.Kind() == "type_identifier" || node.Kind() == "identifier" {
+		if text := j.nodeText(node); unicode.IsUpper(rune(text[0])) {
+			references[text] = true
+		}
+	}
+	for i := uint(0); i < node.ChildCount(); i++ {
+		j.collectJavaReferences(node.Child(i), references)
+	}
+}
+
+func (j *JVMStrategy) javaUnit() (*jvmUnit, error) {
+	tree, err := j.parse()
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+
+	unit := &jvmUnit{}
+	root := tree.RootNode()
+	for i := uint(0); i < root.NamedChildCount(); i++ {
+		child := root.NamedChild(i)
+		switch child.Kind() {
+		case "package_declaration":
+			if child.NamedChildCount() > 0 {
+				unit.Package = j.nodeText(child.NamedChild(child.NamedChildCount() - 1))
+			}
+		case "import_declaration":
+			var imp jvmImport
+			for k := uint(0); k < child.NamedChildCount(); k++ {
+				switch part := child.NamedChild(k); part.Kind() {
+				case "scoped_identifier", "identifier":
+					imp.Path = j.nodeText(part)
+				case "asterisk":
+					imp.Wildcard = true
+				}
+			}
+			if imp.Path != "" {
+				unit.Imports = append(unit.Imports, imp)
+			}
+		default:
+			if _, ok := javaTypeDeclarations[child.Kind()]; ok {
+				if name := child.ChildByFieldName("name"); name != nil {
+					unit.Declared = append(unit.Declared, j.nodeText(name))
+				}
+			}
+		}
+	}
+
+	references := make(map[string]bool)
+	j.collectJavaReferences(root, references)
+	for name := range references {
+		unit.References = append(unit.References, name)
+	}
+	sort.Strings(unit.References)
+	return unit, nil
+}
+
+func (j *JVMStrategy) kotlinUnit() *jvmUnit {
+	source := stripComments(string(j.NodeData), KOTLIN_QUOTES)
+	unit := &jvmUnit{}
+
+	if m := kotlinPackageRegex.FindStringSubmatch(source); m != nil {
+		unit.Package = m[1]
+	}
+	for _, m := range kotlinImportRegex.FindAllStringSubmatch(source, -1) {
+		unit.Imports = append(unit.Imports, jvmImport{Path: m[1], Wildcard: m[2] != ""})
+	}
+	for _, m := range kotlinDeclarationRegex.FindAllStringSubmatch(source, -1) {
+		if m[1] == "" {
+			unit.Declared = append(unit.Declared, m[3])
+		}
+	}
+	for _, m := range kotlinFunctionRegex.FindAllStringSubmatch(source, -1) {
+		if m[1] == "" {
+			unit.Declared = append(unit.Declared, m[2])
+		}
+	}
+
+	for _, name := range kotlinTypeNameRegex.FindAllString(source, -1) {
+		if !slices.Contains(unit.References, name) {
+			unit.References = append(unit.References, name)
+		}
+	}
+	return unit
+}
+
+func (j *JVMStrategy) unit() (*jvmUnit, error) {
+	var unit *jvmUnit
+	if j.Language == types.KOTLIN {
+		unit = j.kotlinUnit()
+	} else {
+		var err error
+		if unit, err = j.javaUnit(); err != nil {
+			return nil, err
+		}
+	}
+
+	if unit.Package == "" {
+		unit.Package = jvmSourcePackage(j.NodePath)
+	}
+	return unit, nil
+}
+
+func (j *JVMStrategy) GetNodePackages() []string {
+	unit, err := j.unit()
+	if err != nil {
+		return []string{}
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(j.NodePath), filepath.Ext(j.NodePath))
+	packages := []string{JVM_PKG_PREFIX + jvmQualifiedName(unit.Package, "*")}
+	for _, name := range append([]string{stem}, unit.Declared...) {
+		if key := JVM_PKG_PREFIX + jvmQualifiedName(unit.Package, name); !slices.Contains(packages, key) {
+			packages = append(packages, key)
+		}
+	}
+	return packages
+}
+
+// Files of the longest prefix of name which is a known type
+func (j *JVMStrategy) resolveName(name string) []string {
+	parts := strings.Split(name, ".")
+	for n := len(parts); n > 0; n-- {
+		if paths, ok := j.PkgPaths[JVM_PKG_PREFIX+strings.Join(parts[:n], ".")]; ok {
+			return paths
+		}
+	}
+	return nil
+}
+
+func (j *JVMStrategy) resolveImport(imp jvmImport) []string {
+	if imp.Wildcard {
+		if paths, ok := j.PkgPaths[JVM_PKG_PREFIX+imp.Path+".*"]; ok {
+			return paths
+		}
+	}
+	return j.resolveName(imp.Path)
+}
+
+func (j *JVMStrategy) resolveUnitImports(unit *jvmUnit) []types.NodeImport {
+	imports := []types.NodeImport{}
+	add := func(paths []string) {
+		for _, path := range paths {
+			n := types.NodeImport{
+				ImportPackage: path,
+				FilePath:      j.NodePath,
+			}
+			if path != j.NodePath && !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+
+	for _, imp := range unit.Imports {
+		add(j.resolveImport(imp))
+	}
+	for _, name := range unit.References {
+		add(j.PkgPaths[JVM_PKG_PREFIX+jvmQualifiedName(unit.Package, name)])
+	}
+	return imports
+}
+
+func (j *JVMStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+	unit, err := j.unit()
+	if err != nil {
+		return []types.NodeImport{}
+	}
+	return j.resolveUnitImports(unit)
+}
+
+func (j *JVMStrategy) GetNodeImportList() ([]types.NodeImport, error) {
+	unit, err := j.unit()
+	if err != nil {
+		return []types.NodeImport{}, err
+	}
+	return j.resolveUnitImports(unit), nil
+}
+
+var javaReferenceKinds = map[string]bool{"identifier": true, "type_identifier": true}
+
+func (j *JVMStrategy) getJavaDefinitions(node *tree_sitter.Node) []types.Symbol {
+	var definitions []types.Symbol
+
+	if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+		switch node.Kind() {
+		case "method_declaration", "constructor_declaration":
+			fnName, fnParams := getFunctionInfo(node, j.NodeData)
+			definitions = append(definitions, newNodeSymbol(j.NodePath, node, nameNode, j.NodeData, fnName+fnParams))
+		default:
+			if keyword, ok := javaTypeDeclarations[node.Kind()]; ok {
+				signature := keyword + " " + j.nodeText(nameNode)
+				if params := node.ChildByFieldName("parameters"); params != nil {
+					signature += j.nodeText(params)
+				}
+				definitions = append(definitions, newNodeSymbol(j.NodePath, node, nameNode, j.NodeData, signature))
+			}
+		}
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		definitions = append(definitions, j.getJavaDefinitions(node.Child(i))...)
+	}
+	return definitions
+}
+
+func (j *JVMStrategy) getKotlinDefinitions(source string) []types.Symbol {
+	var definitions []lexicalDefinition
+
+	for _, m := range kotlinDeclarationRegex.FindAllStringSubmatchIndex(source, -1) {
+		definitions = append(definitions, lexicalDefinition{m[0], m[6], source[m[6]:m[7]], source[m[4]:m[5]] + " " + source[m[6]:m[7]]})
+	}
+	for _, m := range kotlinFunctionRegex.FindAllStringSubmatchIndex(source, -1) {
+		if params := balancedGroup(source, m[1]-1, '(', ')'); params != "" {
+			definitions = append(definitions, lexicalDefinition{m[0], m[4], source[m[4]:m[5]], source[m[4]:m[5]] + params})
+		}
+	}
+
+	return lexicalSymbols(j.NodePath, source, definitions)
+}
+
+func (j *JVMStrategy) GetNodeSignatures() []string {
+	if j.Language == types.KOTLIN {
+		return signaturesOf(j.getKotlinDefinitions(stripComments(string(j.NodeData), KOTLIN_QUOTES)))
+	}
+
+	tree, err := j.parse()
+	if err != nil {
+		return []string{}
+	}
+	defer tree.Close()
+
+	return signaturesOf(j.getJavaDefinitions(tree.RootNode()))
+}
+
+func (j *JVMStrategy) GetNodeSymbols() types.NodeSymbols {
+	if j.Language == types.KOTLIN {
+		source := stripComments(string(j.NodeData), KOTLIN_QUOTES)
+		return types.NodeSymbols{
+			Definitions: j.getKotlinDefinitions(source),
+			References:  lexicalReferences(source),
+		}
+	}
+
+	tree, err := j.parse()
+	if err != nil {
+		return types.NodeSymbols{}
+	}
+	defer tree.Close()
+
+	return types.NodeSymbols{
+		Definitions: j.getJavaDefinitions(tree.RootNode()),
+		References:  collectReferences(tree.RootNode(), j.NodeData, javaReferenceKinds),
+	}
+}
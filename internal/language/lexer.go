@@ -0,0 +1,109 @@
+package language
+
+import (
+	"strings"
+)
+
+/*
+Helpers for the strategies of languages without a tree-sitter grammar in
+the parser set, which scan the source lexically instead.
+*/
+
+/*
+stripComments blanks out // and block comments of C-like source, keeping
+strings intact and newlines in place so line based scans still line up.
+quotes are the characters starting a string or char literal, backquoted
+strings may span lines. A tripled quote starts a raw string, as in kotlin,
+which spans lines, has no escapes and ends at the next tripled quote.
+*/
+func stripComments(source string, quotes string) string {
+	var out strings.Builder
+	out.Grow(len(source))
+
+	for i := 0; i < len(source); i++ {
+		c := source[i]
+
+		switch {
+		case strings.IndexByte(quotes, c) >= 0 && strings.HasPrefix(source[i:], strings.Repeat(string(c), 3)):
+			end := len(source)
+			if close := strings.Index(source[i+3:], source[i:i+3]); close >= 0 {
+				end = i + 3 + close + 3
+				// """"" ends with the last quotes of the run, the others are part of the string
+				for end < len(source) && source[end] == c {
+					end++
+				}
+			}
+			out.WriteString(source[i:end])
+			i = end - 1
+		case strings.IndexByte(quotes, c) >= 0:
+			end := i + 1
+			for end < len(source) && source[end] != c {
+				if source[end] == '\\' {
+					end++
+				} else if source[end] == '\n' && c != '`' {
+					break
+				}
+				end++
+			}
+			end = min(end, len(source)-1)
+			out.WriteString(source[i : end+1])
+			i = end
+		case c == '/' && i+1 < len(source) && source[i+1] == '/':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+			if i < len(source) {
+				out.WriteByte('\n')
+			}
+		case c == '/' && i+1 < len(source) && source[i+1] == '*':
+			end := strings.Index(source[i+2:], "*/")
+			comment := source[i:]
+			if end >= 0 {
+				comment = source[i : i+2+end+2]
+			}
+			out.WriteString(strings.Repeat("\n", strings.Count(comment, "\n")))
+			out.WriteByte(' ')
+			i += len(comment) - 1
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String()
+}
+
+/*
+groupEnd returns the index of the close character matching the open one at
+start, -1 when it is not closed.
+*/
+func groupEnd(source string, start int, open byte, close byte) int {
+	if start >= len(source) || source[start] != open {
+		return -1
+	}
+
+	depth := 0
+	for i := start; i < len(source); i++ {
+		switch source[i] {
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+/*
+balancedGroup returns the text from the open character at start to its
+matching close, with runs of whitespace collapsed, or "" when it is not
+closed.
+*/
+func balancedGroup(source string, start int, open byte, close byte) string {
+	end := groupEnd(source, start, open, close)
+	if end < 0 {
+		return ""
+	}
+	return strings.Join(strings.Fields(source[start:end+1]), " ")
+}
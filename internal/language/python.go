@@ -0,0 +1,290 @@
+package language
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// PkgPaths keys of python modules are namespaced so they never collide with Go package names
+const PYTHON_PKG_PREFIX = "python:"
+
+/*
+PythonStrategy implements LangStrategy.
+
+ 1. Every file is registered in PkgPaths under its dotted module name
+    relative to the repository root ("a.b.c" for a/b/c.py, "a.b" for
+    a/b/__init__.py), and also relative to its source root, the first parent
+    directory which is not a package, so src/ layouts resolve too.
+
+ 2. Absolute imports ("import a.b", "from a.b import c") are resolved against
+    these keys, relative imports ("from ..pkg import x") against the file
+    system starting at the directory of the file. Imports of modules outside
+    the repository are ignored.
+*/
+type PythonStrategy struct {
+	NodeData []byte
+	NodePath string
+	RootDir  string
+	PkgPaths map[string][]string
+	Parser   utils.TreeSitterParserType
+}
+
+func NewPythonStrategy(args StrategyArgs) *PythonStrategy {
+	return &PythonStrategy{
+		NodeData: args.NodeData,
+		NodePath: args.NodePath,
+		RootDir:  args.RootDir,
+		PkgPaths: args.PkgPaths,
+		Parser:   args.Parser,
+	}
+}
+
+// Dotted module name of a python file relative to dir
+func pythonModuleName(dir string, path string) string {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if parts[len(parts)-1] == "__init__" {
+		parts = parts[:len(parts)-1]
+	}
+	return strings.Join(parts, ".")
+}
+
+func isPythonPackage(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, "__init__.py"))
+	return err == nil
+}
+
+func (p *PythonStrategy) GetNodePackages() []string {
+	var packages []string
+
+	if p.RootDir != "" {
+		if name := pythonModuleName(p.RootDir, p.NodePath); name != "" {
+			packages = append(packages, PYTHON_PKG_PREFIX+name)
+		}
+	}
+
+	sourceRoot := filepath.Dir(p.NodePath)
+	for isPythonPackage(sourceRoot) && sourceRoot != filepath.Dir(sourceRoot) {
+		sourceRoot = filepath.Dir(sourceRoot)
+	}
+	if name := pythonModuleName(sourceRoot, p.NodePath); name != "" && !slices.Contains(packages, PYTHON_PKG_PREFIX+name) {
+		packages = append(packages, PYTHON_PKG_PREFIX+name)
+	}
+
+	return packages
+}
+
+func (p *PythonStrategy) nodeText(node *tree_sitter.Node) string {
+	return string(p.NodeData[node.StartByte():node.EndByte()])
+}
+
+// Files of the longest prefix of the dotted module which is in the repository
+func (p *PythonStrategy) resolveAbsoluteModule(module string) []string {
+	parts := strings.Split(module, ".")
+	for i := len(parts); i > 0; i-- {
+		if paths, ok := p.PkgPaths[PYTHON_PKG_PREFIX+strings.Join(parts[:i], ".")]; ok {
+			return paths
+		}
+	}
+	return nil
+}
+
+// The module file or package __init__ for a dotted path under dir
+func resolvePythonPath(dir string, module string) string {
+	base := dir
+	if module != "" {
+		base = filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(module, ".", "/")))
+	}
+
+	for _, candidate := range []string{base + ".py", filepath.Join(base, "__init__.py")} {
+		if fi, err := os.Stat(candidate); err == nil && !fi.IsDir() {
+			return candidate
+		}
+	}
+	return ""
+}
+
+/*
+Files imported by "from <module> import <names>". A name may be a submodule,
+which is preferred, or an attribute of the module itself.
+*/
+func (p *PythonStrategy) resolveFromImport(moduleNode *tree_sitter.Node, names []string) []string {
+	var paths []string
+
+	if moduleNode.Kind() != "relative_import" {
+		module := p.nodeText(moduleNode)
+		for _, name := range names {
+			if found, ok := p.PkgPaths[PYTHON_PKG_PREFIX+module+"."+name]; ok {
+				paths = append(paths, found...)
+				continue
+			}
+			paths = append(paths, p.resolveAbsoluteModule(module)...)
+		}
+		if len(names) == 0 {
+			paths = append(paths, p.resolveAbsoluteModule(module)...)
+		}
+		return paths
+	}
+
+	// One dot is the package of the file, each one after goes up a level
+	dir := filepath.Dir(p.NodePath)
+	var module string
+	for i := uint(0); i < moduleNode.ChildCount(); i++ {
+		child := moduleNode.Child(i)
+		switch child.Kind() {
+		case "import_prefix":
+			for range strings.Count(p.nodeText(child), ".") - 1 {
+				dir = filepath.Dir(dir)
+			}
+		case "dotted_name":
+			module = p.nodeText(child)
+		}
+	}
+
+	for _, name := range names {
+		submodule := name
+		if module != "" {
+			submodule = module + "." + name
+		}
+		if found := resolvePythonPath(dir, submodule); found != "" {
+			paths = append(paths, found)
+			continue
+		}
+		if found := resolvePythonPath(dir, module); found != "" {
+			paths = append(paths, found)
+		}
+	}
+	if len(names) == 0 {
+		if found := resolvePythonPath(dir, module); found != "" {
+			paths = append(paths, found)
+		}
+	}
+	return paths
+}
+
+// Names of an import statement, without their aliases
+func (p *PythonStrategy) importedNames(node *tree_sitter.Node) []string {
+	var names []string
+
+	cursor := node.Walk()
+	defer cursor.Close()
+	for _, child := range node.ChildrenByFieldName("name", cursor) {
+		if child.Kind() == "aliased_import" {
+			if name := child.ChildByFieldName("name"); name != nil {
+				names = append(names, p.nodeText(name))
+			}
+			continue
+		}
+		names = append(names, p.nodeText(&child))
+	}
+	return names
+}
+
+func (p *PythonStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+	rootNode := args.RootNode
+	imports := []types.NodeImport{}
+
+	var paths []string
+	switch rootNode.Kind() {
+	case "import_statement":
+		for _, module := range p.importedNames(rootNode) {
+			paths = append(paths, p.resolveAbsoluteModule(module)...)
+		}
+	case "import_from_statement":
+		if moduleNode := rootNode.ChildByFieldName("module_name"); moduleNode != nil {
+			paths = p.resolveFromImport(moduleNode, p.importedNames(rootNode))
+		}
+	}
+
+	for _, path := range paths {
+		n := types.NodeImport{
+			ImportPackage: path,
+			FilePath:      p.NodePath,
+		}
+		if path != p.NodePath && !slices.Contains(imports, n) {
+			imports = append(imports, n)
+		}
+	}
+
+	for i := uint(0); i < rootNode.ChildCount(); i++ {
+		args.RootNode = rootNode.Child(i)
+		for _, n := range p.resolveImportNodes(args) {
+			if !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+	return imports
+}
+
+func (p *PythonStrategy) GetNodeImportList() ([]types.NodeImport, error) {
+	tree := p.Parser.GetLanguageParser(types.PYTHON).Parse(p.NodeData, nil)
+	if tree == nil {
+		return []types.NodeImport{}, errors.New("Error initializing Parser")
+	}
+	defer tree.Close()
+
+	return p.resolveImportNodes(ResolveImportNodesArgs{
+		RootNode: tree.RootNode(),
+	}), nil
+}
+
+var pythonReferenceKinds = map[string]bool{"identifier": true}
+
+func (p *PythonStrategy) getDefinitions(node *tree_sitter.Node) []types.Symbol {
+	var definitions []types.Symbol
+
+	if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+		switch node.Kind() {
+		case "function_definition":
+			fnName, fnParams := getFunctionInfo(node, p.NodeData)
+			definitions = append(definitions, newNodeSymbol(p.NodePath, node, nameNode, p.NodeData, fnName+fnParams))
+		case "class_definition":
+			signature := "class " + p.nodeText(nameNode)
+			if superclasses := node.ChildByFieldName("superclasses"); superclasses != nil {
+				signature += p.nodeText(superclasses)
+			}
+			definitions = append(definitions, newNodeSymbol(p.NodePath, node, nameNode, p.NodeData, signature))
+		}
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		definitions = append(definitions, p.getDefinitions(node.Child(i))...)
+	}
+	return definitions
+}
+
+func (p *PythonStrategy) GetNodeSignatures() []string {
+	tree := p.Parser.GetLanguageParser(types.PYTHON).Parse(p.NodeData, nil)
+	if tree == nil {
+		return []string{}
+	}
+	defer tree.Close()
+
+	return signaturesOf(p.getDefinitions(tree.RootNode()))
+}
+
+func (p *PythonStrategy) GetNodeSymbols() types.NodeSymbols {
+	tree := p.Parser.GetLanguageParser(types.PYTHON).Parse(p.NodeData, nil)
+	if tree == nil {
+		return types.NodeSymbols{}
+	}
+	defer tree.Close()
+
+	return types.NodeSymbols{
+		Definitions: p.getDefinitions(tree.RootNode()),
+		References:  collectReferences(tree.RootNode(), p.NodeData, pythonReferenceKinds),
+	}
+}
@@ -0,0 +1,423 @@
+package language
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// PkgPaths keys of rust modules, the crate directory followed by the module path ("rust:/repo/core::net::tcp")
+const RUST_PKG_PREFIX = "rust:"
+
+/*
+RustStrategy implements LangStrategy for rust crates.
+
+ 1. Every file under the src directory of a crate (the closest Cargo.toml
+    with a [package]) is registered in PkgPaths under the crate directory and
+    its module path: src/lib.rs and src/main.rs are the crate root, src/a.rs
+    and src/a/mod.rs are "a", src/a/b.rs is "a::b".
+
+ 2. "mod x;" declarations are resolved against the file system like rustc
+    does. Paths of use declarations and expressions starting with crate,
+    self, super or the name of a crate are resolved against the keys, taking
+    the longest module prefix of the path. The crates a file can name are its
+    own, the members of its Cargo workspace and its path dependencies.
+*/
+type RustStrategy struct {
+	NodeData []byte
+	NodePath string
+	PkgPaths map[string][]string
+	Parser   utils.TreeSitterParserType
+}
+
+func NewRustStrategy(args StrategyArgs) *RustStrategy {
+	return &RustStrategy{
+		NodeData: args.NodeData,
+		NodePath: args.NodePath,
+		PkgPaths: args.PkgPaths,
+		Parser:   args.Parser,
+	}
+}
+
+func rustModuleKey(crateDir string, module []string) string {
+	if len(module) == 0 {
+		return RUST_PKG_PREFIX + crateDir
+	}
+	return RUST_PKG_PREFIX + crateDir + "::" + strings.Join(module, "::")
+}
+
+/*
+Module path of the file within its crate, ok is false for files outside of
+src and for binaries in src/bin, which are crates of their own.
+*/
+func (r *RustStrategy) module(manifest *CargoManifest) (module []string, ok bool) {
+	if manifest == nil {
+		return nil, false
+	}
+
+	rel, err := filepath.Rel(filepath.Join(filepath.Dir(manifest.Path), "src"), r.NodePath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return nil, false
+	}
+
+	parts := strings.Split(filepath.ToSlash(strings.TrimSuffix(rel, ".rs")), "/")
+	if parts[0] == "bin" && len(parts) > 1 {
+		return nil, false
+	}
+	if len(parts) == 1 && (parts[0] == "lib" || parts[0] == "main") {
+		return []string{}, true
+	}
+	if parts[len(parts)-1] == "mod" {
+		parts = parts[:len(parts)-1]
+	}
+	return parts, true
+}
+
+func (r *RustStrategy) GetNodePackages() []string {
+	manifest := findCrateManifest(r.NodePath)
+	module, ok := r.module(manifest)
+	if !ok {
+		return []string{}
+	}
+	return []string{rustModuleKey(filepath.Dir(manifest.Path), module)}
+}
+
+func (r *RustStrategy) nodeText(node *tree_sitter.Node) string {
+	return string(r.NodeData[node.StartByte():node.EndByte()])
+}
+
+// Source of the node on one line, as the repo map shows it
+func (r *RustStrategy) compactText(node *tree_sitter.Node) string {
+	return strings.Join(strings.Fields(r.nodeText(node)), " ")
+}
+
+// The value of a #[path = "..."] attribute preceding a mod item, "" without one
+func (r *RustStrategy) pathAttribute(node *tree_sitter.Node) string {
+	for sibling := node.PrevNamedSibling(); sibling != nil; sibling = sibling.PrevNamedSibling() {
+		switch sibling.Kind() {
+		case "line_comment", "block_comment":
+			continue
+		case "attribute_item":
+			attribute := sibling.NamedChild(0)
+			if attribute == nil || attribute.NamedChildCount() == 0 || r.nodeText(attribute.NamedChild(0)) != "path" {
+				continue
+			}
+			if value := attribute.ChildByFieldName("value"); value != nil && value.Kind() == "string_literal" {
+				text := r.nodeText(value)
+				return strings.TrimSuffix(strings.TrimPrefix(text, `"`), `"`)
+			}
+			continue
+		}
+		break
+	}
+	return ""
+}
+
+/*
+File of a "mod x;" declaration, looked up next to crate roots and mod.rs, in
+a directory named after the file otherwise. Declarations inside inline mod
+blocks are looked up in the directories of these.
+*/
+func (r *RustStrategy) resolveModDeclaration(node *tree_sitter.Node, inline []string) string {
+	nameNode := node.ChildByFieldName("name")
+	if nameNode == nil || node.ChildByFieldName("body") != nil {
+		return ""
+	}
+	name := r.nodeText(nameNode)
+
+	dir := filepath.Dir(r.NodePath)
+	module, ok := r.module(findCrateManifest(r.NodePath))
+	if base := filepath.Base(r.NodePath); ok && len(module) > 0 && base != "mod.rs" {
+		dir = filepath.Join(dir, strings.TrimSuffix(base, ".rs"))
+	}
+	dir = filepath.Join(append([]string{dir}, inline...)...)
+
+	candidates := []string{filepath.Join(dir, name+".rs"), filepath.Join(dir, name, "mod.rs")}
+	if path := r.pathAttribute(node); path != "" {
+		candidates = []string{filepath.Join(filepath.Dir(r.NodePath), filepath.FromSlash(path))}
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
+
+/*
+usePaths returns the paths a use tree imports, "a::{b, c::{d, self}}" gives
+a::b, a::c::d and a::c. Aliases and glob segments are dropped.
+*/
+func (r *RustStrategy) usePaths(node *tree_sitter.Node) []string {
+	switch node.Kind() {
+	case "use_as_clause":
+		if path := node.ChildByFieldName("path"); path != nil {
+			return r.usePaths(path)
+		}
+	case "use_wildcard":
+		if node.NamedChildCount() > 0 {
+			return r.usePaths(node.NamedChild(0))
+		}
+	case "use_list":
+		var paths []string
+		for i := uint(0); i < node.NamedChildCount(); i++ {
+			paths = append(paths, r.usePaths(node.NamedChild(i))...)
+		}
+		return paths
+	case "scoped_use_list":
+		list := node.ChildByFieldName("list")
+		if list == nil {
+			return nil
+		}
+		prefix := ""
+		if path := node.ChildByFieldName("path"); path != nil {
+			prefix = strings.Join(r.usePaths(path), "")
+		}
+		var paths []string
+		for _, path := range r.usePaths(list) {
+			switch {
+			case path == "self":
+				paths = append(paths, prefix)
+			case prefix != "":
+				paths = append(paths, prefix+"::"+path)
+			default:
+				paths = append(paths, path)
+			}
+		}
+		return paths
+	case "identifier", "scoped_identifier", "crate", "self", "super":
+		return []string{strings.TrimPrefix(strings.Join(strings.Fields(r.nodeText(node)), ""), "::")}
+	}
+	return nil
+}
+
+/*
+Files of the longest module prefix of path. Paths not starting with crate,
+self, super or a known crate are relative to the current module only when
+relative is set, which is the case for use declarations. The current module
+is the one of the file followed by the inline mod blocks the path is in.
+*/
+func (r *RustStrategy) resolvePath(path string, manifest *CargoManifest, crates map[string]string, inline []string, relative bool) []string {
+	segments := strings.Split(path, "::")
+	for i := range segments {
+		segments[i] = strings.TrimSpace(segments[i])
+	}
+
+	current, registered := r.module(manifest)
+	current = append(slices.Clone(current), inline...)
+
+	crateDir := ""
+	if manifest != nil {
+		crateDir = filepath.Dir(manifest.Path)
+	}
+
+	var base []string
+	minimum := 0
+	switch segments[0] {
+	case "crate":
+		segments = segments[1:]
+	case "self":
+		base, segments = current, segments[1:]
+	case "super":
+		base = current
+		for len(segments) > 0 && segments[0] == "super" && len(base) > 0 {
+			base, segments = base[:len(base)-1], segments[1:]
+		}
+	default:
+		if dir, ok := crates[segments[0]]; ok {
+			crateDir, segments = dir, segments[1:]
+		} else if relative && registered {
+			base, minimum = current, 1
+		} else {
+			return nil
+		}
+	}
+	if crateDir == "" {
+		return nil
+	}
+
+	for n := len(segments); n >= minimum; n-- {
+		module := append(slices.Clone(base), segments[:n]...)
+		if paths, ok := r.PkgPaths[rustModuleKey(crateDir, module)]; ok {
+			return paths
+		}
+	}
+	return nil
+}
+
+func (r *RustStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+	rootNode := args.RootNode
+	imports := []types.NodeImport{}
+	add := func(paths ...string) {
+		for _, path := range paths {
+			n := types.NodeImport{
+				ImportPackage: path,
+				FilePath:      r.NodePath,
+			}
+			if path != "" && path != r.NodePath && !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+
+	switch rootNode.Kind() {
+	case "mod_item":
+		// mod tests { use super::*; } names the module of the file
+		if body := rootNode.ChildByFieldName("body"); body != nil {
+			if nameNode := rootNode.ChildByFieldName("name"); nameNode != nil {
+				args.RootNode = body
+				args.RustInlineModules = append(slices.Clone(args.RustInlineModules), r.nodeText(nameNode))
+				return r.resolveImportNodes(args)
+			}
+		}
+		add(r.resolveModDeclaration(rootNode, args.RustInlineModules))
+	case "use_declaration":
+		if argument := rootNode.ChildByFieldName("argument"); argument != nil {
+			for _, path := range r.usePaths(argument) {
+				add(r.resolvePath(path, args.RustManifest, args.RustCrates, args.RustInlineModules, true)...)
+			}
+		}
+		return imports
+	case "scoped_identifier", "scoped_type_identifier":
+		// crate::a::f(), super::X::new() and paths through other crates, the longest path is the one resolved
+		add(r.resolvePath(strings.Join(strings.Fields(r.nodeText(rootNode)), ""), args.RustManifest, args.RustCrates, args.RustInlineModules, false)...)
+		return imports
+	}
+
+	for i := uint(0); i < rootNode.ChildCount(); i++ {
+		args.RootNode = rootNode.Child(i)
+		for _, n := range r.resolveImportNodes(args) {
+			if !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+	return imports
+}
+
+func (r *RustStrategy) parse() (*tree_sitter.Tree, error) {
+	parser := r.Parser.GetLanguageParser(types.RUST)
+	if parser == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	tree := parser.Parse(r.NodeData, nil)
+	if tree == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	return tree, nil
+}
+
+func (r *RustStrategy) GetNodeImportList() ([]types.NodeImport, error) {
+	tree, err := r.parse()
+	if err != nil {
+		return []types.NodeImport{}, err
+	}
+	defer tree.Close()
+
+	manifest := findCrateManifest(r.NodePath)
+	crates := map[string]string{}
+	if manifest != nil {
+		crates = rustCrates(manifest)
+	}
+	return r.resolveImportNodes(ResolveImportNodesArgs{
+		RootNode:     tree.RootNode(),
+		RustManifest: manifest,
+		RustCrates:   crates,
+	}), nil
+}
+
+// The name of the type an impl block is for, the name references to the block go by
+func rustTypeName(node *tree_sitter.Node) *tree_sitter.Node {
+	switch node.Kind() {
+	case "type_identifier":
+		return node
+	case "scoped_type_identifier":
+		return node.ChildByFieldName("name")
+	case "generic_type", "reference_type", "pointer_type":
+		if inner := node.ChildByFieldName("type"); inner != nil {
+			return rustTypeName(inner)
+		}
+	}
+	return nil
+}
+
+// Identifiers of a rust file which may name a symbol of the repository
+var rustReferenceKinds = map[string]bool{"identifier": true, "type_identifier": true, "field_identifier": true}
+
+var rustTypeKeywords = map[string]string{
+	"struct_item": "struct",
+	"enum_item":   "enum",
+	"trait_item":  "trait",
+	"union_item":  "union",
+}
+
+/*
+getDefinitions returns functions and methods with their parameters, structs,
+enums, traits and impl blocks, in the order they are declared.
+*/
+func (r *RustStrategy) getDefinitions(node *tree_sitter.Node) []types.Symbol {
+	var definitions []types.Symbol
+
+	switch node.Kind() {
+	case "function_item", "function_signature_item":
+		if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+			fnName, fnParams := getFunctionInfo(node, r.NodeData)
+			definitions = append(definitions, newNodeSymbol(r.NodePath, node, nameNode, r.NodeData, fnName+fnParams))
+		}
+	case "struct_item", "enum_item", "trait_item", "union_item":
+		if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+			definitions = append(definitions, newNodeSymbol(r.NodePath, node, nameNode, r.NodeData, rustTypeKeywords[node.Kind()]+" "+r.nodeText(nameNode)))
+		}
+	case "impl_item":
+		// impl<T> Display for Wrapper<T>, without the where clause
+		typeNode := node.ChildByFieldName("type")
+		if typeNode == nil {
+			break
+		}
+		if nameNode := rustTypeName(typeNode); nameNode != nil {
+			signature := "impl"
+			if params := node.ChildByFieldName("type_parameters"); params != nil {
+				signature += r.compactText(params)
+			}
+			if trait := node.ChildByFieldName("trait"); trait != nil {
+				signature += " " + r.compactText(trait) + " for"
+			}
+			signature += " " + r.compactText(typeNode)
+			definitions = append(definitions, newNodeSymbol(r.NodePath, node, nameNode, r.NodeData, signature))
+		}
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		definitions = append(definitions, r.getDefinitions(node.Child(i))...)
+	}
+	return definitions
+}
+
+func (r *RustStrategy) GetNodeSignatures() []string {
+	tree, err := r.parse()
+	if err != nil {
+		return []string{}
+	}
+	defer tree.Close()
+
+	return signaturesOf(r.getDefinitions(tree.RootNode()))
+}
+
+func (r *RustStrategy) GetNodeSymbols() types.NodeSymbols {
+	tree, err := r.parse()
+	if err != nil {
+		return types.NodeSymbols{}
+	}
+	defer tree.Close()
+
+	return types.NodeSymbols{
+		Definitions: r.getDefinitions(tree.RootNode()),
+		References:  collectReferences(tree.RootNode(), r.NodeData, rustReferenceKinds),
+	}
+}
@@ -1,6 +1,7 @@
 package language
 
 import (
+	"github.com/manosriram/wingman/internal/gomod"
 	"github.com/manosriram/wingman/internal/types"
 	"github.com/manosriram/wingman/internal/utils"
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
@@ -15,6 +16,23 @@ type LangStrategy interface {
 	*/
 	GetNodeImportList() ([]types.NodeImport, error)
 
+	/*
+		Return the keys the node is registered under in PkgPaths, imports of
+		other nodes are resolved against these keys
+	*/
+	GetNodePackages() []string
+
+	/*
+		Return the signatures of the node which make up its part of the repo map
+	*/
+	GetNodeSignatures() []string
+
+	/*
+		Return the definitions of the node, the same ones GetNodeSignatures
+		returns, and the identifiers it references, see symbols.Index
+	*/
+	GetNodeSymbols() types.NodeSymbols
+
 	/*
 		Internal method which parses the code repository and lists the imports
 	*/
@@ -25,6 +43,16 @@ func GetStrategy(args StrategyArgs) LangStrategy {
 	switch args.StrategyLanguage {
 	case types.GOLANG:
 		return NewGolangStrategy(args)
+	case types.PYTHON:
+		return NewPythonStrategy(args)
+	case types.JAVASCRIPT:
+		return NewJavaScriptStrategy(args)
+	case types.TYPESCRIPT:
+		return NewTypeScriptStrategy(args)
+	case types.RUST:
+		return NewRustStrategy(args)
+	case types.JAVA, types.KOTLIN:
+		return NewJVMStrategy(args)
 	}
 	return NewDefaultStrategy(args)
 }
@@ -32,6 +60,7 @@ func GetStrategy(args StrategyArgs) LangStrategy {
 type StrategyArgs struct {
 	NodeData         []byte
 	NodePath         string
+	RootDir          string // Root of the repository, used to name the packages of a node
 	Parser           utils.TreeSitterParserType
 	PkgPaths         map[string][]string
 	StrategyLanguage types.Language
@@ -39,5 +68,9 @@ type StrategyArgs struct {
 
 type ResolveImportNodesArgs struct {
 	RootNode          *tree_sitter.Node
-	GolangModFileData string
+	GolangModFileData string // Module path from go.mod
+	GolangResolver    *gomod.Resolver
+	RustManifest      *CargoManifest    // Manifest of the crate of the node
+	RustCrates        map[string]string // Crates the node can name, see rustCrates
+	RustInlineModules []string          // Names of the inline mod blocks enclosing the node
 }
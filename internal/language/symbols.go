@@ -0,0 +1,101 @@
+package language
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/types"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+/*
+Helpers building the definitions/references index of a node. Definitions
+are the symbols the repo map shows, references are every identifier of the
+node; the symbol graph links each reference to the definitions of that name
+it can see.
+*/
+
+var identifierRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+
+// Signatures of the definitions, in the order they are declared
+func signaturesOf(definitions []types.Symbol) []string {
+	signatures := make([]string, 0, len(definitions))
+	for _, d := range definitions {
+		signatures = append(signatures, d.Signature)
+	}
+	return signatures
+}
+
+// A definition spanning node, named by nameNode
+func newNodeSymbol(path string, node *tree_sitter.Node, nameNode *tree_sitter.Node, source []byte, signature string) types.Symbol {
+	return types.Symbol{
+		Name:      string(source[nameNode.StartByte():nameNode.EndByte()]),
+		Signature: signature,
+		FilePath:  path,
+		Line:      int(node.StartPosition().Row) + 1,
+		Start:     node.StartByte(),
+		End:       node.EndByte(),
+	}
+}
+
+// Identifiers of the given node kinds in the tree
+func collectReferences(node *tree_sitter.Node, source []byte, kinds map[string]bool) []types.SymbolReference {
+	var references []types.SymbolReference
+	if kinds[node.Kind()] {
+		references = append(references, types.SymbolReference{
+			Name:   string(source[node.StartByte():node.EndByte()]),
+			Offset: node.StartByte(),
+		})
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		references = append(references, collectReferences(node.Child(i), source, kinds)...)
+	}
+	return references
+}
+
+// A definition found by a lexical scan, the name starts at nameOffset
+type lexicalDefinition struct {
+	offset     int
+	nameOffset int
+	name       string
+	signature  string
+}
+
+/*
+lexicalSymbols turns the definitions of a lexical scan into symbols. Without
+a syntax tree the end of a definition is unknown, each one is taken to
+extend to the start of the next.
+*/
+func lexicalSymbols(path string, source string, definitions []lexicalDefinition) []types.Symbol {
+	sort.SliceStable(definitions, func(i, j int) bool {
+		return definitions[i].offset < definitions[j].offset
+	})
+
+	symbols := make([]types.Symbol, 0, len(definitions))
+	for i, d := range definitions {
+		end := len(source)
+		if i+1 < len(definitions) {
+			end = definitions[i+1].offset
+		}
+		symbols = append(symbols, types.Symbol{
+			Name:      d.name,
+			Signature: d.signature,
+			FilePath:  path,
+			Line:      strings.Count(source[:d.nameOffset], "\n") + 1,
+			Start:     uint(d.offset),
+			End:       uint(end),
+		})
+	}
+	return symbols
+}
+
+// Every identifier of source, keywords included since no definition is named after one
+func lexicalReferences(source string) []types.SymbolReference {
+	var references []types.SymbolReference
+	for _, m := range identifierRegex.FindAllStringIndex(source, -1) {
+		references = append(references, types.SymbolReference{Name: source[m[0]:m[1]], Offset: uint(m[0])})
+	}
+	return references
+}
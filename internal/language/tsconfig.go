@@ -0,0 +1,201 @@
+package language
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Levels of "extends" followed when loading a tsconfig.json
+const TSCONFIG_MAX_EXTENDS = 8
+
+/*
+TSConfig holds the module resolution options of a tsconfig.json (or
+jsconfig.json), with BaseURL and the targets of Paths made absolute.
+*/
+type TSConfig struct {
+	Path    string
+	BaseURL string
+	Paths   map[string][]string
+}
+
+type tsconfigFile struct {
+	Extends         string `json:"extends"`
+	CompilerOptions struct {
+		BaseURL *string             `json:"baseUrl"`
+		Paths   map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+var trailingCommaRegex = regexp.MustCompile(`,(\s*[}\]])`)
+
+var (
+	tsconfigMu    sync.Mutex
+	tsconfigCache = make(map[string]*TSConfig)
+)
+
+// tsconfig.json allows comments and trailing commas, which encoding/json does not
+func readTSConfigFile(path string) (*tsconfigFile, error) {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	source := trailingCommaRegex.ReplaceAllString(stripComments(string(d), `"`), "$1")
+
+	var file tsconfigFile
+	if err := json.Unmarshal([]byte(source), &file); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+/*
+LoadTSConfig reads the tsconfig.json at path. Options missing from it are
+taken from the config it extends, when that is a relative path, and paths
+are resolved against baseUrl or, without one, the directory of the config
+declaring them.
+*/
+func LoadTSConfig(path string) (*TSConfig, error) {
+	config := &TSConfig{Path: path}
+
+	var hasBaseURL, hasPaths bool
+	for depth := 0; path != "" && depth < TSCONFIG_MAX_EXTENDS; depth++ {
+		file, err := readTSConfigFile(path)
+		if err != nil {
+			if depth == 0 {
+				return nil, err
+			}
+			break
+		}
+
+		dir := filepath.Dir(path)
+		if !hasBaseURL && file.CompilerOptions.BaseURL != nil {
+			config.BaseURL = filepath.Join(dir, *file.CompilerOptions.BaseURL)
+			hasBaseURL = true
+		}
+		if !hasPaths && file.CompilerOptions.Paths != nil {
+			config.Paths = make(map[string][]string)
+			for pattern, targets := range file.CompilerOptions.Paths {
+				for _, target := range targets {
+					config.Paths[pattern] = append(config.Paths[pattern], target)
+				}
+			}
+			// Targets are made absolute once baseUrl is known
+			defer func(dir string) {
+				base := dir
+				if config.BaseURL != "" {
+					base = config.BaseURL
+				}
+				for pattern, targets := range config.Paths {
+					for i, target := range targets {
+						if !filepath.IsAbs(target) {
+							targets[i] = filepath.Join(base, filepath.FromSlash(target))
+						}
+					}
+					config.Paths[pattern] = targets
+				}
+			}(dir)
+			hasPaths = true
+		}
+
+		path = ""
+		if strings.HasPrefix(file.Extends, ".") {
+			path = filepath.Join(dir, file.Extends)
+			if filepath.Ext(path) != ".json" {
+				path += ".json"
+			}
+		}
+	}
+
+	return config, nil
+}
+
+/*
+findTSConfig returns the config of the closest tsconfig.json or
+jsconfig.json above the file at path, nil when there is none. Configs are
+cached by path and modification time.
+*/
+func findTSConfig(path string) *TSConfig {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		for _, name := range []string{"tsconfig.json", "jsconfig.json"} {
+			candidate := filepath.Join(dir, name)
+			fi, err := os.Stat(candidate)
+			if err != nil {
+				continue
+			}
+
+			key := candidate + "@" + fi.ModTime().String()
+			tsconfigMu.Lock()
+			config, ok := tsconfigCache[key]
+			tsconfigMu.Unlock()
+			if ok {
+				return config
+			}
+
+			config, err = LoadTSConfig(candidate)
+			if err != nil {
+				return nil
+			}
+			tsconfigMu.Lock()
+			tsconfigCache[key] = config
+			tsconfigMu.Unlock()
+			return config
+		}
+
+		if dir == filepath.Dir(dir) {
+			return nil
+		}
+	}
+}
+
+/*
+Candidates returns the absolute module paths a bare specifier maps to.
+Patterns of Paths are tried from the longest prefix, like tsc does, then the
+specifier is looked up under BaseURL.
+*/
+func (c *TSConfig) Candidates(specifier string) []string {
+	patterns := make([]string, 0, len(c.Paths))
+	for pattern := range c.Paths {
+		patterns = append(patterns, pattern)
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		pi, _, _ := strings.Cut(patterns[i], "*")
+		pj, _, _ := strings.Cut(patterns[j], "*")
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return patterns[i] < patterns[j]
+	})
+
+	var candidates []string
+	for _, pattern := range patterns {
+		prefix, suffix, wildcard := strings.Cut(pattern, "*")
+
+		var match string
+		if !wildcard {
+			if specifier != pattern {
+				continue
+			}
+		} else {
+			if !strings.HasPrefix(specifier, prefix) || !strings.HasSuffix(specifier, suffix) || len(specifier) < len(prefix)+len(suffix) {
+				continue
+			}
+			match = specifier[len(prefix) : len(specifier)-len(suffix)]
+		}
+
+		for _, target := range c.Paths[pattern] {
+			candidates = append(candidates, strings.Replace(target, "*", filepath.FromSlash(match), 1))
+		}
+		break
+	}
+
+	if c.BaseURL != "" {
+		candidates = append(candidates, filepath.Join(c.BaseURL, filepath.FromSlash(specifier)))
+	}
+	return candidates
+}
@@ -0,0 +1,211 @@
+package language
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Extensions of typescript modules, javascript ones are resolved too since both may import each other
+var tsExtensions = append([]string{".ts", ".tsx", ".mts", ".cts", ".d.ts"}, jsExtensions...)
+
+/*
+TypeScriptStrategy implements LangStrategy for .ts, .tsx, .mts and .cts
+files, .tsx ones are parsed with the TSX grammar.
+
+ 1. Files are registered in PkgPaths like javascript ones, under JS_PKG_PREFIX
+    and their absolute path without the extension, so typescript and
+    javascript modules resolve into each other. Declaration files (x.d.ts)
+    are registered as x.
+
+ 2. Relative specifiers are resolved against the directory of the file, bare
+    ones against the paths and baseUrl of the closest tsconfig.json. A ".js"
+    specifier resolves to the ".ts" file it is compiled from, as tsc does.
+*/
+type TypeScriptStrategy struct {
+	NodeData   []byte
+	NodePath   string
+	PkgPaths   map[string][]string
+	Parser     utils.TreeSitterParserType
+	Language   types.Language
+	Extensions []string
+}
+
+func NewTypeScriptStrategy(args StrategyArgs) *TypeScriptStrategy {
+	language := types.TYPESCRIPT
+	if strings.HasSuffix(args.NodePath, ".tsx") {
+		language = types.TSX
+	}
+	return &TypeScriptStrategy{
+		NodeData:   args.NodeData,
+		NodePath:   args.NodePath,
+		PkgPaths:   args.PkgPaths,
+		Parser:     args.Parser,
+		Language:   language,
+		Extensions: tsExtensions,
+	}
+}
+
+func (t *TypeScriptStrategy) GetNodePackages() []string {
+	path := trimExtension(t.NodePath, t.Extensions)
+	packages := []string{JS_PKG_PREFIX + path}
+	if filepath.Base(path) == "index" {
+		packages = append(packages, JS_PKG_PREFIX+filepath.Dir(path))
+	}
+	return packages
+}
+
+func (t *TypeScriptStrategy) resolveModulePath(path string) []string {
+	if paths, ok := t.PkgPaths[JS_PKG_PREFIX+path]; ok {
+		return paths
+	}
+	if trimmed := trimExtension(path, t.Extensions); trimmed != path {
+		return t.PkgPaths[JS_PKG_PREFIX+trimmed]
+	}
+	return nil
+}
+
+func (t *TypeScriptStrategy) resolveSpecifier(specifier string) []string {
+	if isRelativeSpecifier(specifier) {
+		return t.resolveModulePath(filepath.Join(filepath.Dir(t.NodePath), filepath.FromSlash(specifier)))
+	}
+
+	config := findTSConfig(t.NodePath)
+	if config == nil {
+		return nil
+	}
+	for _, candidate := range config.Candidates(specifier) {
+		if paths := t.resolveModulePath(candidate); len(paths) > 0 {
+			return paths
+		}
+	}
+	return nil
+}
+
+func (t *TypeScriptStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
+	rootNode := args.RootNode
+	imports := []types.NodeImport{}
+
+	if specifier := moduleSpecifier(rootNode, t.NodeData); specifier != "" {
+		for _, path := range t.resolveSpecifier(specifier) {
+			n := types.NodeImport{
+				ImportPackage: path,
+				FilePath:      t.NodePath,
+			}
+			if path != t.NodePath && !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+
+	for i := uint(0); i < rootNode.ChildCount(); i++ {
+		args.RootNode = rootNode.Child(i)
+		for _, n := range t.resolveImportNodes(args) {
+			if !slices.Contains(imports, n) {
+				imports = append(imports, n)
+			}
+		}
+	}
+	return imports
+}
+
+func (t *TypeScriptStrategy) parse() (*tree_sitter.Tree, error) {
+	parser := t.Parser.GetLanguageParser(t.Language)
+	if parser == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	tree := parser.Parse(t.NodeData, nil)
+	if tree == nil {
+		return nil, errors.New("Error initializing Parser")
+	}
+	return tree, nil
+}
+
+func (t *TypeScriptStrategy) GetNodeImportList() ([]types.NodeImport, error) {
+	tree, err := t.parse()
+	if err != nil {
+		return []types.NodeImport{}, err
+	}
+	defer tree.Close()
+
+	return t.resolveImportNodes(ResolveImportNodesArgs{
+		RootNode: tree.RootNode(),
+	}), nil
+}
+
+var typeScriptReferenceKinds = map[string]bool{"identifier": true, "property_identifier": true, "shorthand_property_identifier": true, "type_identifier": true}
+
+// Keyword the repo map shows type declarations with
+var typeScriptDeclarationKeywords = map[string]string{
+	"interface_declaration":      "interface",
+	"type_alias_declaration":     "type",
+	"enum_declaration":           "enum",
+	"class_declaration":          "class",
+	"abstract_class_declaration": "class",
+}
+
+/*
+getDefinitions returns interfaces, type aliases, classes, enums, functions,
+arrow functions assigned to variables and class and interface methods, in
+the order they are declared.
+*/
+func (t *TypeScriptStrategy) getDefinitions(node *tree_sitter.Node) []types.Symbol {
+	var definitions []types.Symbol
+
+	if nameNode := node.ChildByFieldName("name"); nameNode != nil {
+		switch node.Kind() {
+		case "function_declaration", "generator_function_declaration", "function_signature", "method_definition", "method_signature", "abstract_method_signature":
+			fnName, fnParams := getFunctionInfo(node, t.NodeData)
+			definitions = append(definitions, newNodeSymbol(t.NodePath, node, nameNode, t.NodeData, fnName+fnParams))
+		case "interface_declaration", "type_alias_declaration", "enum_declaration", "class_declaration", "abstract_class_declaration":
+			definitions = append(definitions, newNodeSymbol(t.NodePath, node, nameNode, t.NodeData, typeScriptDeclarationKeywords[node.Kind()]+" "+string(t.NodeData[nameNode.StartByte():nameNode.EndByte()])))
+		case "variable_declarator":
+			// const handler = (req: Request): Response => {...}
+			value := node.ChildByFieldName("value")
+			if value != nil && (value.Kind() == "arrow_function" || value.Kind() == "function_expression") {
+				fnName, _ := getFunctionInfo(node, t.NodeData)
+				_, fnParams := getFunctionInfo(value, t.NodeData)
+				if fnParams == "" {
+					// A single arrow function parameter may be written without parentheses
+					if param := value.ChildByFieldName("parameter"); param != nil {
+						fnParams = "(" + string(t.NodeData[param.StartByte():param.EndByte()]) + ")"
+					}
+				}
+				definitions = append(definitions, newNodeSymbol(t.NodePath, node, nameNode, t.NodeData, fnName+fnParams))
+			}
+		}
+	}
+
+	for i := uint(0); i < node.ChildCount(); i++ {
+		definitions = append(definitions, t.getDefinitions(node.Child(i))...)
+	}
+	return definitions
+}
+
+func (t *TypeScriptStrategy) GetNodeSignatures() []string {
+	tree, err := t.parse()
+	if err != nil {
+		return []string{}
+	}
+	defer tree.Close()
+
+	return signaturesOf(t.getDefinitions(tree.RootNode()))
+}
+
+func (t *TypeScriptStrategy) GetNodeSymbols() types.NodeSymbols {
+	tree, err := t.parse()
+	if err != nil {
+		return types.NodeSymbols{}
+	}
+	defer tree.Close()
+
+	return types.NodeSymbols{
+		Definitions: t.getDefinitions(tree.RootNode()),
+		References:  collectReferences(tree.RootNode(), t.NodeData, typeScriptReferenceKinds),
+	}
+}
@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -10,11 +11,13 @@ import (
 	"os"
 	"strings"
 
+	"github.com/manosriram/wingman/internal/tokenizer"
 	"github.com/manosriram/wingman/internal/types"
 )
 
 type Client struct {
 	APIKey     string
+	BaseURL    string
 	HTTPClient *http.Client
 }
 
@@ -29,22 +32,55 @@ type Response struct {
 	Usage        types.Usage          `json:"usage"`
 }
 
+/*
+StreamEvent is a single server-sent event of a streamed messages response.
+
+A stream is made of one message_start, a content_block_start/delta/stop
+group per content block, one or more message_delta and a final message_stop.
+*/
+type StreamEvent struct {
+	Type         string              `json:"type"`
+	Index        int                 `json:"index"`
+	Message      *Response           `json:"message,omitempty"`
+	ContentBlock *types.ContentBlock `json:"content_block,omitempty"`
+	Delta        StreamDelta         `json:"delta"`
+	Usage        *types.Usage        `json:"usage,omitempty"`
+}
+
+type StreamDelta struct {
+	Type         string `json:"type"`
+	Text         string `json:"text"`
+	StopReason   string `json:"stop_reason"`
+	StopSequence string `json:"stop_sequence"`
+}
+
 func NewClient(apiKey string) *Client {
 	return &Client{
 		APIKey:     apiKey,
+		BaseURL:    types.APIBaseURL,
 		HTTPClient: &http.Client{},
 	}
 }
 
-func (c *Client) SendMessage(req types.Request) (*Response, error) {
+func (c *Client) newHTTPRequest(req types.Request) (*http.Request, error) {
+	return c.newHTTPRequestTo("", req)
+}
+
+// path is appended to the messages endpoint, e.g. "/count_tokens"
+func (c *Client) newHTTPRequestTo(path string, req types.Request) (*http.Request, error) {
 	// Marshal request to JSON
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = types.APIBaseURL
+	}
+
 	// Create HTTP request
-	httpReq, err := http.NewRequest("POST", types.APIBaseURL, bytes.NewBuffer(jsonData))
+	httpReq, err := http.NewRequest("POST", baseURL+path, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -54,6 +90,23 @@ func (c *Client) SendMessage(req types.Request) (*Response, error) {
 	httpReq.Header.Set("x-api-key", c.APIKey)
 	httpReq.Header.Set("anthropic-version", types.APIVersion)
 
+	return httpReq, nil
+}
+
+func apiError(statusCode int, body []byte) error {
+	var errResp types.ErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error.Type == "" {
+		return fmt.Errorf("API error (status %d): %s", statusCode, string(body))
+	}
+	return fmt.Errorf("API error: %s - %s", errResp.Error.Type, errResp.Error.Message)
+}
+
+func (c *Client) SendMessage(req types.Request) (*Response, error) {
+	httpReq, err := c.newHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
 	// Send request
 	resp, err := c.HTTPClient.Do(httpReq)
 	if err != nil {
@@ -69,11 +122,7 @@ func (c *Client) SendMessage(req types.Request) (*Response, error) {
 
 	// Check for error response
 	if resp.StatusCode != http.StatusOK {
-		var errResp types.ErrorResponse
-		if err := json.Unmarshal(body, &errResp); err != nil {
-			return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
-		}
-		return nil, fmt.Errorf("API error: %s - %s", errResp.Error.Type, errResp.Error.Message)
+		return nil, apiError(resp.StatusCode, body)
 	}
 
 	// Parse successful response
@@ -85,9 +134,153 @@ func (c *Client) SendMessage(req types.Request) (*Response, error) {
 	return &apiResp, nil
 }
 
-// TODO: read stream response
+// CountTokens returns the number of input tokens of req as counted by the API
+func (c *Client) CountTokens(req types.Request) (int, error) {
+	// The endpoint rejects generation parameters
+	req.MaxTokens = 0
+	req.Stream = false
+
+	httpReq, err := c.newHTTPRequestTo("/count_tokens", req)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, apiError(resp.StatusCode, body)
+	}
+
+	var countResp types.CountTokensResponse
+	if err := json.Unmarshal(body, &countResp); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return countResp.InputTokens, nil
+}
+
+/*
+StreamMessage sends the request with streaming enabled and calls onDelta with
+every text delta as it arrives. The returned Response is assembled from the
+stream and has the same shape as the one returned by SendMessage.
+*/
+func (c *Client) StreamMessage(req types.Request, onDelta func(string)) (*Response, error) {
+	req.Stream = true
+	httpReq, err := c.newHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, apiError(resp.StatusCode, body)
+	}
+
+	return readStream(resp.Body, onDelta)
+}
+
+func readStream(body io.Reader, onDelta func(string)) (*Response, error) {
+	apiResp := &Response{}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// Only the data lines carry the payload, the event type is repeated inside it
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+
+		var event StreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "message_start":
+			if event.Message != nil {
+				*apiResp = *event.Message
+			}
+		case "content_block_start":
+			block := types.ContentBlock{}
+			if event.ContentBlock != nil {
+				block = *event.ContentBlock
+			}
+			apiResp.Content = append(apiResp.Content, block)
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" {
+				continue
+			}
+			for len(apiResp.Content) <= event.Index {
+				apiResp.Content = append(apiResp.Content, types.ContentBlock{Type: "text"})
+			}
+			apiResp.Content[event.Index].Text += event.Delta.Text
+			if onDelta != nil {
+				onDelta(event.Delta.Text)
+			}
+		case "message_delta":
+			apiResp.StopReason = event.Delta.StopReason
+			apiResp.StopSequence = event.Delta.StopSequence
+			if event.Usage != nil {
+				apiResp.Usage.OutputTokens = event.Usage.OutputTokens
+			}
+		case "message_stop":
+			return apiResp, nil
+		case "error":
+			return nil, apiError(http.StatusOK, []byte(data))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return nil, errors.New("stream ended before message_stop")
+}
+
 func (c *Client) SendPrompt(prompt string) (string, error) {
-	req := types.Request{
+	resp, err := c.SendMessage(newPromptRequest(prompt))
+	if err != nil {
+		return "", err
+	}
+
+	return resp.GetTextResponse(), nil
+}
+
+func (c *Client) SendPromptStream(prompt string, onDelta func(string)) (string, error) {
+	resp, err := c.StreamMessage(newPromptRequest(prompt), onDelta)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.GetTextResponse(), nil
+}
+
+func newPromptRequest(prompt string) types.Request {
+	return types.Request{
 		Model:     "claude-sonnet-4-20250514",
 		MaxTokens: 4096,
 		Messages: []types.Message{
@@ -97,13 +290,6 @@ func (c *Client) SendPrompt(prompt string) (string, error) {
 			},
 		},
 	}
-
-	resp, err := c.SendMessage(req)
-	if err != nil {
-		return "", err
-	}
-
-	return resp.GetTextResponse(), nil
 }
 
 func (r *Response) GetTextResponse() string {
@@ -124,6 +310,7 @@ type ClaudeLLM struct {
 	Input               string
 	InputWithoutRepoMap string
 	Client              *Client
+	Estimator           tokenizer.Estimator
 }
 
 type LLMRequest struct {
@@ -143,6 +330,19 @@ func NewClaudeLLM(req LLMRequest) *ClaudeLLM {
 	}
 }
 
+// UseTokenCounter switches between the offline approximation and the count_tokens endpoint
+func (c *ClaudeLLM) UseTokenCounter(counter TokenCounterType) error {
+	switch counter {
+	case APPROXIMATE_TOKEN_COUNTER:
+		c.Estimator = tokenizer.NewApproximateEstimator()
+	case ANTHROPIC_TOKEN_COUNTER:
+		c.Estimator = NewAnthropicEstimator(c.Client, c.SelectedModel)
+	default:
+		return fmt.Errorf("unsupported token counter: %s", counter)
+	}
+	return nil
+}
+
 func (c ClaudeLLM) GetMaxTokenCount(model string) int64 {
 	return 200000
 }
@@ -152,47 +352,82 @@ func (c ClaudeLLM) GetSelectedModel() string {
 }
 
 func (c ClaudeLLM) GetInputTokenCount() int {
-	return len(strings.Split(c.Input, " "))
+	return c.CountTokens(c.Input)
+}
+
+func (c ClaudeLLM) CountTokens(text string) int {
+	return countTokens(c.Estimator, text)
 }
 
 func (c ClaudeLLM) WriteToHistory(request string, response *LLMResponse) error {
-	wd, err := os.Getwd()
-	if err != nil {
-		return err
+	return writeToHistory(request, response)
+}
+
+func (c ClaudeLLM) newRequest(system string, messages []types.Message) types.Request {
+	req := newPromptRequest("")
+	if c.SelectedModel != "" {
+		req.Model = c.SelectedModel
 	}
+	req.System = system
+	req.Messages = messages
+	return req
+}
 
-	f, err := os.OpenFile(wd+"/.wingman.history.md", os.O_APPEND|os.O_WRONLY, 0644)
+func (c ClaudeLLM) Call(system string, messages []types.Message) (*LLMResponse, error) {
+	f, err := openOutputFile()
 	if err != nil {
-		return errors.New("Error writing to .wingman.history.md")
+		return nil, err
 	}
 	defer f.Close()
 
-	content := fmt.Sprintf("%s\n%s\n\n\n", request, response.Response)
-	if _, err = f.WriteString(content); err != nil {
-		return errors.New("Error writing to .wingman.history.md")
+	resp, err := c.Prompt(system, messages)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	if _, err = f.WriteString(resp.Response); err != nil {
+		return nil, errors.New("Error writing to wingman.md")
+	}
+
+	return resp, nil
 }
 
-func (c ClaudeLLM) Call(prompt string) (*LLMResponse, error) {
-	wd, err := os.Getwd()
+func (c ClaudeLLM) Prompt(system string, messages []types.Message) (*LLMResponse, error) {
+	resp, err := c.Client.SendMessage(c.newRequest(system, messages))
 	if err != nil {
 		return nil, err
 	}
 
-	f, err := os.OpenFile(wd+"/wingman.md", os.O_APPEND|os.O_WRONLY, 0644)
+	return &LLMResponse{
+		Response: resp.GetTextResponse(),
+	}, nil
+}
+
+func (c ClaudeLLM) CallStream(system string, messages []types.Message, onToken func(string)) (*LLMResponse, error) {
+	f, err := openOutputFile()
 	if err != nil {
-		return nil, errors.New("Error writing to wingman.md")
+		return nil, err
 	}
 	defer f.Close()
 
-	response, err := c.Client.SendPrompt(prompt)
-	if _, err = f.WriteString(response); err != nil {
-		return nil, errors.New("Error writing to wingman.md")
+	var writeErr error
+	resp, err := c.Client.StreamMessage(c.newRequest(system, messages), func(token string) {
+		// Keep wingman.md in sync with what the user sees, one delta at a time
+		if _, err := f.WriteString(token); err != nil && writeErr == nil {
+			writeErr = errors.New("Error writing to wingman.md")
+		}
+		if onToken != nil {
+			onToken(token)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if writeErr != nil {
+		return nil, writeErr
 	}
 
 	return &LLMResponse{
-		Response: response,
+		Response: resp.GetTextResponse(),
 	}, nil
 }
@@ -1,7 +1,12 @@
 package llm
 
 import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/manosriram/wingman/internal/types"
@@ -78,7 +83,7 @@ func TestClaudeLLM_GetInputTokenCount(t *testing.T) {
 		{
 			name:  "empty input",
 			input: "",
-			want:  1,
+			want:  0,
 		},
 		{
 			name:  "single word",
@@ -90,6 +95,11 @@ func TestClaudeLLM_GetInputTokenCount(t *testing.T) {
 			input: "hello world foo bar",
 			want:  4,
 		},
+		{
+			name:  "code",
+			input: "func getUserName(id int) string {",
+			want:  10,
+		},
 	}
 
 	for _, tt := range tests {
@@ -192,3 +202,177 @@ func TestLLMResponse_Struct(t *testing.T) {
 		t.Errorf("LLMResponse.Response = %s, want test response", resp.Response)
 	}
 }
+
+const testStreamBody = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-sonnet-4-20250514","usage":{"input_tokens":12,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type":"ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":7}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func newStreamServer(t *testing.T, status int, body string) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("x-api-key header = %q, want test-key", r.Header.Get("x-api-key"))
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func TestClient_StreamMessage(t *testing.T) {
+	server := newStreamServer(t, http.StatusOK, testStreamBody)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+
+	var deltas []string
+	resp, err := client.StreamMessage(newPromptRequest("hi"), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("StreamMessage() unexpected error: %v", err)
+	}
+
+	if strings.Join(deltas, "|") != "Hello| world" {
+		t.Errorf("StreamMessage() deltas = %q, want [Hello  world]", deltas)
+	}
+	if resp.GetTextResponse() != "Hello world" {
+		t.Errorf("StreamMessage() text = %q, want Hello world", resp.GetTextResponse())
+	}
+	if resp.ID != "msg_1" {
+		t.Errorf("StreamMessage() ID = %s, want msg_1", resp.ID)
+	}
+	if resp.StopReason != "end_turn" {
+		t.Errorf("StreamMessage() StopReason = %s, want end_turn", resp.StopReason)
+	}
+	if resp.Usage.InputTokens != 12 || resp.Usage.OutputTokens != 7 {
+		t.Errorf("StreamMessage() Usage = %+v, want {12 7}", resp.Usage)
+	}
+}
+
+func TestClient_StreamMessage_SendsStreamFlag(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !strings.Contains(string(body), `"stream":true`) {
+			t.Errorf("request body = %s, want stream enabled", string(body))
+		}
+		fmt.Fprint(w, testStreamBody)
+	}))
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+
+	if _, err := client.StreamMessage(newPromptRequest("hi"), nil); err != nil {
+		t.Fatalf("StreamMessage() unexpected error: %v", err)
+	}
+}
+
+func TestClient_StreamMessage_ErrorEvent(t *testing.T) {
+	body := `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","content":[]}}
+
+event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`
+	server := newStreamServer(t, http.StatusOK, body)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+
+	_, err := client.StreamMessage(newPromptRequest("hi"), nil)
+	if err == nil {
+		t.Fatal("StreamMessage() expected error for error event")
+	}
+	if !strings.Contains(err.Error(), "overloaded_error") {
+		t.Errorf("StreamMessage() error = %v, want overloaded_error", err)
+	}
+}
+
+func TestClient_StreamMessage_HTTPError(t *testing.T) {
+	server := newStreamServer(t, http.StatusUnauthorized, `{"type":"error","error":{"type":"authentication_error","message":"invalid x-api-key"}}`)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+
+	_, err := client.StreamMessage(newPromptRequest("hi"), nil)
+	if err == nil {
+		t.Fatal("StreamMessage() expected error for non 200 status")
+	}
+	if !strings.Contains(err.Error(), "authentication_error") {
+		t.Errorf("StreamMessage() error = %v, want authentication_error", err)
+	}
+}
+
+func TestClient_StreamMessage_TruncatedStream(t *testing.T) {
+	body := strings.Split(testStreamBody, "event: message_stop")[0]
+	server := newStreamServer(t, http.StatusOK, body)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+
+	if _, err := client.StreamMessage(newPromptRequest("hi"), nil); err == nil {
+		t.Error("StreamMessage() expected error when message_stop is missing")
+	}
+}
+
+func TestClaudeLLM_CallStream(t *testing.T) {
+	server := newStreamServer(t, http.StatusOK, testStreamBody)
+	defer server.Close()
+
+	wd, _ := os.Getwd()
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/wingman.md", []byte{}, 0644); err != nil {
+		t.Fatalf("Failed to create wingman.md: %v", err)
+	}
+	os.Chdir(tmpDir)
+	defer os.Chdir(wd)
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+	llm := ClaudeLLM{SelectedModel: "claude-sonnet-4-20250514", Client: client}
+
+	var tokens strings.Builder
+	resp, err := llm.CallStream("system", []types.Message{{Role: "user", Content: "hi"}}, func(token string) {
+		tokens.WriteString(token)
+	})
+	if err != nil {
+		t.Fatalf("CallStream() unexpected error: %v", err)
+	}
+	if resp.Response != "Hello world" || tokens.String() != "Hello world" {
+		t.Errorf("CallStream() response = %q, tokens = %q, want Hello world", resp.Response, tokens.String())
+	}
+
+	written, _ := os.ReadFile(tmpDir + "/wingman.md")
+	if string(written) != "Hello world" {
+		t.Errorf("CallStream() wrote %q to wingman.md, want Hello world", string(written))
+	}
+}
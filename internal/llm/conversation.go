@@ -0,0 +1,90 @@
+package llm
+
+import (
+	"sync"
+
+	"github.com/manosriram/wingman/internal/types"
+)
+
+/*
+Conversation holds the user and assistant turns of a shell session so that
+follow up questions are answered with the previous ones in context.
+
+Only the questions and answers are kept here, the repository context is
+rebuilt for every call and sent as the system prompt (see CreateSystemPrompt),
+so /add and /drop take effect without rewriting the history.
+*/
+type Conversation struct {
+	mu    sync.Mutex
+	Turns []types.Message
+}
+
+func NewConversation() *Conversation {
+	return &Conversation{
+		Turns: []types.Message{},
+	}
+}
+
+func (c *Conversation) AddUserTurn(content string) {
+	c.addTurns(types.Message{Role: "user", Content: content})
+}
+
+func (c *Conversation) AddAssistantTurn(content string) {
+	c.addTurns(types.Message{Role: "assistant", Content: content})
+}
+
+/*
+AddExchange adds a question along with its answer. The shell adds both once
+the answer arrived, so questions answered at the same time cannot end up
+between each other's turns.
+*/
+func (c *Conversation) AddExchange(question string, answer string) {
+	c.addTurns(types.Message{Role: "user", Content: question}, types.Message{Role: "assistant", Content: answer})
+}
+
+func (c *Conversation) addTurns(turns ...types.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Turns = append(c.Turns, turns...)
+}
+
+/*
+DropOldest removes turns, the oldest ones of a copy returned by Messages,
+which no longer fit the prompt. Turns another caller removed already are
+not removed twice.
+*/
+func (c *Conversation) DropOldest(turns []types.Message) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for n < len(turns) && n < len(c.Turns) && c.Turns[n] == turns[n] {
+		n++
+	}
+	c.Turns = c.Turns[n:]
+}
+
+// Messages returns a copy of the turns, ready to be sent to the LLM
+func (c *Conversation) Messages() []types.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	messages := make([]types.Message, len(c.Turns))
+	copy(messages, c.Turns)
+	return messages
+}
+
+func (c *Conversation) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.Turns)
+}
+
+func (c *Conversation) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.Turns = []types.Message{}
+}
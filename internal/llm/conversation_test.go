@@ -0,0 +1,118 @@
+package llm
+
+import (
+	"testing"
+
+	"github.com/manosriram/wingman/internal/types"
+)
+
+func TestConversation_AccumulatesTurns(t *testing.T) {
+	c := NewConversation()
+	c.AddUserTurn("what does Foo do?")
+	c.AddAssistantTurn("Foo adds two numbers")
+	c.AddUserTurn("now make it use a mutex")
+
+	want := []types.Message{
+		{Role: "user", Content: "what does Foo do?"},
+		{Role: "assistant", Content: "Foo adds two numbers"},
+		{Role: "user", Content: "now make it use a mutex"},
+	}
+
+	got := c.Messages()
+	if len(got) != len(want) {
+		t.Fatalf("Messages() returned %d turns, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Messages()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestConversation_MessagesIsACopy(t *testing.T) {
+	c := NewConversation()
+	c.AddUserTurn("hello")
+
+	messages := c.Messages()
+	messages[0].Content = "changed"
+
+	if c.Messages()[0].Content != "hello" {
+		t.Error("Messages() should not expose the underlying turns")
+	}
+}
+
+func TestConversation_AddExchange(t *testing.T) {
+	c := NewConversation()
+	c.AddExchange("first", "answer")
+
+	want := []types.Message{{Role: "user", Content: "first"}, {Role: "assistant", Content: "answer"}}
+	if got := c.Messages(); len(got) != 2 || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("Messages() = %+v, want %+v", got, want)
+	}
+}
+
+func TestConversation_DropOldest(t *testing.T) {
+	c := NewConversation()
+	c.AddExchange("first", "answer")
+	c.AddExchange("second", "answer")
+
+	oldest := c.Messages()[:2]
+	c.AddExchange("third", "answer")
+	c.DropOldest(oldest)
+
+	if c.Len() != 4 || c.Messages()[0].Content != "second" {
+		t.Fatalf("Messages() = %+v, want the second and third exchanges", c.Messages())
+	}
+
+	// Dropped by another caller already
+	c.DropOldest(oldest)
+	if c.Len() != 4 {
+		t.Errorf("Len() = %d after dropping the same turns twice, want 4", c.Len())
+	}
+}
+
+func TestConversation_Reset(t *testing.T) {
+	c := NewConversation()
+	c.AddUserTurn("hello")
+	c.AddAssistantTurn("hi")
+
+	c.Reset()
+
+	if c.Len() != 0 {
+		t.Errorf("Len() after Reset() = %d, want 0", c.Len())
+	}
+}
+
+func TestWithSystemMessage(t *testing.T) {
+	messages := []types.Message{{Role: "user", Content: "hi"}}
+
+	got := withSystemMessage("context", messages)
+	if len(got) != 2 || got[0].Role != "system" || got[0].Content != "context" || got[1] != messages[0] {
+		t.Errorf("withSystemMessage() = %+v, want system message first", got)
+	}
+
+	if got := withSystemMessage("", messages); len(got) != 1 {
+		t.Errorf("withSystemMessage() with empty system = %+v, want messages unchanged", got)
+	}
+}
+
+func TestClaudeLLM_NewRequest(t *testing.T) {
+	llm := ClaudeLLM{SelectedModel: "claude-opus-4-5-20251101"}
+	messages := []types.Message{
+		{Role: "user", Content: "first"},
+		{Role: "assistant", Content: "answer"},
+		{Role: "user", Content: "follow up"},
+	}
+
+	req := llm.newRequest("repo map", messages)
+
+	if req.Model != "claude-opus-4-5-20251101" {
+		t.Errorf("newRequest() Model = %s, want claude-opus-4-5-20251101", req.Model)
+	}
+	if req.System != "repo map" {
+		t.Errorf("newRequest() System = %q, want repo map", req.System)
+	}
+	if len(req.Messages) != 3 || req.Messages[2].Content != "follow up" {
+		t.Errorf("newRequest() Messages = %+v, want the full history", req.Messages)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/manosriram/wingman/internal/types"
@@ -26,10 +27,25 @@ type LLM interface {
 	GetMaxTokenCount(string) int64
 	GetSelectedModel() string
 	GetInputTokenCount() int
-	Call(string) (*LLMResponse, error)
+	CountTokens(text string) int
+	Call(system string, messages []types.Message) (*LLMResponse, error)
+	// Prompt is Call without the answer written to wingman.md, for prompts of
+	// wingman's own like the commit message
+	Prompt(system string, messages []types.Message) (*LLMResponse, error)
 	WriteToHistory(request string, response *LLMResponse) error
 }
 
+/*
+StreamingLLM is implemented by the models which can stream their answer.
+
+onToken is called with every chunk of text as soon as it arrives, the
+returned LLMResponse holds the full answer once the stream is complete.
+*/
+type StreamingLLM interface {
+	LLM
+	CallStream(system string, messages []types.Message, onToken func(string)) (*LLMResponse, error)
+}
+
 func NewLLM(model string) (LLM, error) {
 	if model == "" {
 		return nil, errors.New("model cannot be empty")
@@ -40,33 +56,129 @@ func NewLLM(model string) (LLM, error) {
 			return nil, errors.New("env ANTHROPIC_API_KEY not set")
 		}
 		return NewClaudeLLM(LLMRequest{Model: model}), nil
-	} else if strings.HasPrefix(model, "gpt") {
-		return nil, errors.New("OpenAI models not yet implemented")
+	} else if strings.HasPrefix(model, OLLAMA_MODEL_PREFIX) {
+		return NewOllamaLLM(LLMRequest{Model: strings.TrimPrefix(model, OLLAMA_MODEL_PREFIX)}), nil
+	} else if strings.HasPrefix(model, LLAMACPP_MODEL_PREFIX) {
+		return NewLlamaCppLLM(LLMRequest{Model: strings.TrimPrefix(model, LLAMACPP_MODEL_PREFIX)}), nil
+	} else if strings.HasPrefix(model, OPENAI_MODEL_PREFIX) || isOpenAIModel(model) {
+		// A custom base URL points to a self-hosted server which may not need a key
+		if os.Getenv("OPENAI_API_KEY") == "" && os.Getenv("OPENAI_BASE_URL") == "" {
+			return nil, errors.New("env OPENAI_API_KEY not set")
+		}
+		return NewOpenAILLM(LLMRequest{Model: strings.TrimPrefix(model, OPENAI_MODEL_PREFIX)}), nil
 	}
 
 	return nil, errors.New("unsupported model: " + model)
 }
 
-// TODO: add token count check
-func CreateMasterPrompt(signatures map[string][]string, addedFiles map[string]string, input string) string {
-	var prompt strings.Builder
-	prompt.WriteString(types.BASE_LLM_PROMPT)
+// Chat completion style APIs take the system prompt as the first message
+func withSystemMessage(system string, messages []types.Message) []types.Message {
+	if system == "" {
+		return messages
+	}
+	return append([]types.Message{{Role: "system", Content: system}}, messages...)
+}
 
-	for filepath, signature := range signatures {
-		prompt.WriteString(filepath + ": \n")
-		for _, s := range signature {
-			prompt.WriteString(s + "\n")
-		}
-		prompt.WriteString("\n")
+func openOutputFile() (*os.File, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
 	}
 
+	f, err := os.OpenFile(wd+"/wingman.md", os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, errors.New("Error writing to wingman.md")
+	}
+	return f, nil
+}
+
+func writeToHistory(request string, response *LLMResponse) error {
+	wd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(wd+"/.wingman.history.md", os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return errors.New("Error writing to .wingman.history.md")
+	}
+	defer f.Close()
+
+	content := fmt.Sprintf("%s\n%s\n\n\n", request, response.Response)
+	if _, err = f.WriteString(content); err != nil {
+		return errors.New("Error writing to .wingman.history.md")
+	}
+
+	return nil
+}
+
+// RepoMapEntry is one file of the repo map along with the signatures it defines
+type RepoMapEntry struct {
+	Path       string
+	Signatures []string
+}
+
+// EstimateTokenCount counts tokens with the offline approximation, for callers without an LLM at hand
+func EstimateTokenCount(text string) int {
+	return countTokens(nil, text)
+}
+
+func FormatRepoMapEntry(entry RepoMapEntry) string {
+	var prompt strings.Builder
+	prompt.WriteString(entry.Path + ": \n")
+	for _, s := range entry.Signatures {
+		prompt.WriteString(s + "\n")
+	}
 	prompt.WriteString("\n")
-	for path, content := range addedFiles {
-		fmt.Fprintf(&prompt, "%s : %s", path, content)
+	return prompt.String()
+}
+
+func FormatAddedFiles(addedFiles map[string]string) string {
+	paths := make([]string, 0, len(addedFiles))
+	for path := range addedFiles {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var prompt strings.Builder
+	for _, path := range paths {
+		fmt.Fprintf(&prompt, "%s : %s", path, addedFiles[path])
 		prompt.WriteString("\n")
 	}
+	return prompt.String()
+}
+
+/*
+CreateSystemPrompt returns the repository context sent as the system prompt
+of every call: the instructions, the repo map and the contents of the files
+added with /add. The questions themselves travel as conversation turns.
+
+The entries are written in the given order, which is the order of importance
+when they come from Repository.BuildRepoMap.
+*/
+func CreateSystemPrompt(entries []RepoMapEntry, addedFiles map[string]string) string {
+	var prompt strings.Builder
+	prompt.WriteString(types.BASE_LLM_PROMPT)
+
+	for _, entry := range entries {
+		prompt.WriteString(FormatRepoMapEntry(entry))
+	}
+
 	prompt.WriteString("\n")
+	prompt.WriteString(FormatAddedFiles(addedFiles))
+	prompt.WriteString("\n")
+
+	return prompt.String()
+}
 
-	return prompt.String() + "\n\n\n" + "Now answer the below question keeping in mind the above context\n\n" + input
+func CreateMasterPrompt(signatures map[string][]string, addedFiles map[string]string, input string) string {
+	entries := make([]RepoMapEntry, 0, len(signatures))
+	for path, s := range signatures {
+		entries = append(entries, RepoMapEntry{Path: path, Signatures: s})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
 
+	return CreateSystemPrompt(entries, addedFiles) + "\n\n\n" + "Now answer the below question keeping in mind the above context\n\n" + input
 }
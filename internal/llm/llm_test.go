@@ -2,6 +2,7 @@ package llm
 
 import (
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -90,13 +91,60 @@ func TestNewLLM_EmptyModel(t *testing.T) {
 }
 
 func TestNewLLM_GPTModel(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", "")
+
+	tests := []struct {
+		name      string
+		model     string
+		wantModel string
+	}{
+		{name: "gpt model", model: "gpt-4o", wantModel: "gpt-4o"},
+		{name: "o-series model", model: "o3-mini", wantModel: "o3-mini"},
+		{name: "prefixed self-hosted model", model: "openai/qwen2.5-coder", wantModel: "qwen2.5-coder"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			llm, err := NewLLM(tt.model)
+			if err != nil {
+				t.Fatalf("NewLLM(%s) unexpected error: %v", tt.model, err)
+			}
+			openai, ok := llm.(*OpenAILLM)
+			if !ok {
+				t.Fatalf("NewLLM(%s) = %T, want *OpenAILLM", tt.model, llm)
+			}
+			if openai.GetSelectedModel() != tt.wantModel {
+				t.Errorf("NewLLM(%s) SelectedModel = %s, want %s", tt.model, openai.GetSelectedModel(), tt.wantModel)
+			}
+		})
+	}
+}
+
+func TestNewLLM_GPTModel_MissingAPIKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "")
+
 	llm, err := NewLLM("gpt-4")
 
 	if llm != nil {
-		t.Error("NewLLM() should return nil for unimplemented GPT model")
+		t.Error("NewLLM() expected nil LLM when OPENAI_API_KEY is missing")
 	}
-	if err == nil {
-		t.Error("NewLLM() should return error for unimplemented GPT model")
+	if err == nil || err.Error() != "env OPENAI_API_KEY not set" {
+		t.Errorf("NewLLM() wrong error: %v", err)
+	}
+}
+
+func TestNewLLM_SelfHostedWithoutAPIKey(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:8000/v1")
+
+	llm, err := NewLLM("openai/llama-3-8b")
+	if err != nil {
+		t.Fatalf("NewLLM() unexpected error: %v", err)
+	}
+	if llm.(*OpenAILLM).Client.BaseURL != "http://localhost:8000/v1" {
+		t.Errorf("NewLLM() BaseURL = %s, want http://localhost:8000/v1", llm.(*OpenAILLM).Client.BaseURL)
 	}
 }
 
@@ -187,3 +235,43 @@ func containsString(s, substr string) bool {
 	}
 	return false
 }
+
+func TestCreateSystemPrompt_ExcludesQuestion(t *testing.T) {
+	entries := []RepoMapEntry{
+		{Path: "/path/to/a.go", Signatures: []string{"func A()"}},
+	}
+	addedFiles := map[string]string{
+		"/path/to/a.go": "package a",
+	}
+
+	prompt := CreateSystemPrompt(entries, addedFiles)
+
+	if !containsString(prompt, "func A()") || !containsString(prompt, "package a") {
+		t.Error("CreateSystemPrompt() missing repository context")
+	}
+	if containsString(prompt, "Now answer the below question") {
+		t.Error("CreateSystemPrompt() should not contain the question")
+	}
+}
+
+func TestCreateSystemPrompt_KeepsEntryOrder(t *testing.T) {
+	entries := []RepoMapEntry{
+		{Path: "/z.go", Signatures: []string{"Z()"}},
+		{Path: "/a.go", Signatures: []string{"A()"}},
+	}
+
+	prompt := CreateSystemPrompt(entries, nil)
+
+	if strings.Index(prompt, "/z.go") > strings.Index(prompt, "/a.go") {
+		t.Error("CreateSystemPrompt() should keep the ranked order of the entries")
+	}
+}
+
+func TestEstimateTokenCount(t *testing.T) {
+	if got := EstimateTokenCount("func  Foo()\n\treturn"); got != 6 {
+		t.Errorf("EstimateTokenCount() = %d, want 6", got)
+	}
+	if got := EstimateTokenCount(""); got != 0 {
+		t.Errorf("EstimateTokenCount() = %d, want 0", got)
+	}
+}
@@ -0,0 +1,402 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/manosriram/wingman/internal/tokenizer"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+const (
+	OLLAMA_MODEL_PREFIX   = "ollama/"
+	LLAMACPP_MODEL_PREFIX = "llamacpp/"
+
+	// Used when the server cannot tell the context window of the model, and the window Ollama runs models with by default
+	LOCAL_DEFAULT_CONTEXT_WINDOW = 4096
+
+	// Context window to ask Ollama for, in tokens. Larger windows take more memory on the server
+	OLLAMA_NUM_CTX_ENV = "OLLAMA_NUM_CTX"
+
+	localDiscoveryTimeout = 3 * time.Second
+)
+
+// Turns OLLAMA_HOST style values ("127.0.0.1:11434") into a base URL
+func normalizeHost(host string, defaultHost string) string {
+	if host == "" {
+		host = defaultHost
+	}
+	if !strings.HasPrefix(host, "http://") && !strings.HasPrefix(host, "https://") {
+		host = "http://" + host
+	}
+	return strings.TrimSuffix(host, "/")
+}
+
+type OllamaClient struct {
+	Host       string
+	HTTPClient *http.Client
+}
+
+type OllamaChatResponse struct {
+	Model           string        `json:"model"`
+	Message         types.Message `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+type OllamaShowResponse struct {
+	Parameters string         `json:"parameters"`
+	ModelInfo  map[string]any `json:"model_info"`
+}
+
+func NewOllamaClient(host string) *OllamaClient {
+	return &OllamaClient{
+		Host:       normalizeHost(host, types.OllamaDefaultHost),
+		HTTPClient: &http.Client{},
+	}
+}
+
+func (c *OllamaClient) post(ctx context.Context, path string, body any) (*http.Response, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.Host+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(body, &errResp); err == nil && errResp.Error != "" {
+			return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, errResp.Error)
+		}
+		return nil, fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+	}
+
+	return resp, nil
+}
+
+/*
+Chat calls /api/chat. When the request is streamed, the server answers with
+one JSON object per line and onDelta is called with the content of each, the
+returned response holds the full message either way.
+*/
+func (c *OllamaClient) Chat(req types.OllamaChatRequest, onDelta func(string)) (*OllamaChatResponse, error) {
+	resp, err := c.post(context.Background(), "/api/chat", req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if !req.Stream {
+		var chatResp OllamaChatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&chatResp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		if chatResp.Error != "" {
+			return nil, fmt.Errorf("API error: %s", chatResp.Error)
+		}
+		return &chatResp, nil
+	}
+
+	var content strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var chunk OllamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("API error: %s", chunk.Error)
+		}
+
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				onDelta(chunk.Message.Content)
+			}
+		}
+
+		if chunk.Done {
+			chunk.Message = types.Message{Role: "assistant", Content: content.String()}
+			return &chunk, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return nil, errors.New("stream ended before done")
+}
+
+func (c *OllamaClient) ShowModel(model string) (*OllamaShowResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), localDiscoveryTimeout)
+	defer cancel()
+
+	resp, err := c.post(ctx, "/api/show", types.OllamaShowRequest{Model: model})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var showResp OllamaShowResponse
+	if err := json.NewDecoder(resp.Body).Decode(&showResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	return &showResp, nil
+}
+
+/*
+GetContextWindow returns the number of tokens the model is run with when the
+request does not set num_ctx.
+
+A num_ctx parameter set in the Modelfile wins, since that is what the server
+actually allocates. Otherwise the server runs the model with its default
+window, LOCAL_DEFAULT_CONTEXT_WINDOW, or the trained context length from
+model_info (stored under "<architecture>.context_length") when it is smaller.
+*/
+func (r *OllamaShowResponse) GetContextWindow() (int64, error) {
+	for _, line := range strings.Split(r.Parameters, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == "num_ctx" {
+			if n, err := strconv.ParseInt(fields[1], 10, 64); err == nil && n > 0 {
+				return n, nil
+			}
+		}
+	}
+
+	for key, value := range r.ModelInfo {
+		if !strings.HasSuffix(key, ".context_length") {
+			continue
+		}
+		if n, ok := value.(float64); ok && n > 0 {
+			return min(int64(n), LOCAL_DEFAULT_CONTEXT_WINDOW), nil
+		}
+	}
+
+	return 0, errors.New("context window not reported by the server")
+}
+
+/*
+OllamaLLM implements LLM over a local Ollama server, the host is read from
+OLLAMA_HOST. NumCtx is the window requested with num_ctx, read from
+OLLAMA_NUM_CTX. Without it the server picks the window, and ContextWindow is
+discovered from /api/show when the model is selected, falling back to
+LOCAL_DEFAULT_CONTEXT_WINDOW.
+*/
+type OllamaLLM struct {
+	SelectedModel       string
+	Input               string
+	InputWithoutRepoMap string
+	ContextWindow       int64
+	NumCtx              int64
+	Client              *OllamaClient
+	Estimator           tokenizer.Estimator
+}
+
+func NewOllamaLLM(req LLMRequest) *OllamaLLM {
+	c := NewOllamaClient(os.Getenv("OLLAMA_HOST"))
+
+	o := &OllamaLLM{
+		SelectedModel:       req.Model,
+		Input:               req.Input,
+		InputWithoutRepoMap: req.InputWithoutRepoMap,
+		Client:              c,
+	}
+
+	if n, err := strconv.ParseInt(os.Getenv(OLLAMA_NUM_CTX_ENV), 10, 64); err == nil && n > 0 {
+		o.NumCtx = n
+		o.ContextWindow = n
+		return o
+	}
+
+	if show, err := c.ShowModel(req.Model); err == nil {
+		if n, err := show.GetContextWindow(); err == nil {
+			o.ContextWindow = n
+		}
+	}
+
+	return o
+}
+
+func (o OllamaLLM) GetMaxTokenCount(model string) int64 {
+	if o.ContextWindow > 0 {
+		return o.ContextWindow
+	}
+	return LOCAL_DEFAULT_CONTEXT_WINDOW
+}
+
+func (o OllamaLLM) GetSelectedModel() string {
+	return o.SelectedModel
+}
+
+func (o OllamaLLM) GetInputTokenCount() int {
+	return o.CountTokens(o.Input)
+}
+
+func (o OllamaLLM) CountTokens(text string) int {
+	return countTokens(o.Estimator, text)
+}
+
+func (o OllamaLLM) WriteToHistory(request string, response *LLMResponse) error {
+	return writeToHistory(request, response)
+}
+
+func (o OllamaLLM) newRequest(system string, messages []types.Message, stream bool) types.OllamaChatRequest {
+	req := types.OllamaChatRequest{
+		Model:    o.SelectedModel,
+		Messages: withSystemMessage(system, messages),
+		Stream:   stream,
+	}
+
+	// The server allocates the whole window for every request, only ask for one the user chose
+	if o.NumCtx > 0 {
+		req.Options = map[string]any{"num_ctx": o.NumCtx}
+	}
+	return req
+}
+
+func (o OllamaLLM) Call(system string, messages []types.Message) (*LLMResponse, error) {
+	f, err := openOutputFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resp, err := o.Prompt(system, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = f.WriteString(resp.Response); err != nil {
+		return nil, errors.New("Error writing to wingman.md")
+	}
+
+	return resp, nil
+}
+
+func (o OllamaLLM) Prompt(system string, messages []types.Message) (*LLMResponse, error) {
+	resp, err := o.Client.Chat(o.newRequest(system, messages, false), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LLMResponse{
+		Response: resp.Message.Content,
+	}, nil
+}
+
+func (o OllamaLLM) CallStream(system string, messages []types.Message, onToken func(string)) (*LLMResponse, error) {
+	f, err := openOutputFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var writeErr error
+	resp, err := o.Client.Chat(o.newRequest(system, messages, true), func(token string) {
+		if _, err := f.WriteString(token); err != nil && writeErr == nil {
+			writeErr = errors.New("Error writing to wingman.md")
+		}
+		if onToken != nil {
+			onToken(token)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	return &LLMResponse{
+		Response: resp.Message.Content,
+	}, nil
+}
+
+/*
+NewLlamaCppLLM returns an OpenAILLM talking to the OpenAI compatible API of a
+llama.cpp server at LLAMACPP_HOST. The context window the server was started
+with (-c / --ctx-size) is read from its /props endpoint.
+*/
+func NewLlamaCppLLM(req LLMRequest) *OpenAILLM {
+	host := normalizeHost(os.Getenv("LLAMACPP_HOST"), types.LlamaCppDefaultHost)
+
+	o := &OpenAILLM{
+		SelectedModel:       req.Model,
+		Input:               req.Input,
+		InputWithoutRepoMap: req.InputWithoutRepoMap,
+		Client:              NewOpenAIClient(os.Getenv("LLAMACPP_API_KEY"), host+"/v1"),
+	}
+
+	if n, err := getLlamaCppContextWindow(o.Client.HTTPClient, host); err == nil {
+		o.ContextWindow = n
+	}
+
+	return o
+}
+
+func getLlamaCppContextWindow(client *http.Client, host string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), localDiscoveryTimeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", host+"/props", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("API error (status %d)", resp.StatusCode)
+	}
+
+	var props struct {
+		DefaultGenerationSettings struct {
+			NCtx int64 `json:"n_ctx"`
+		} `json:"default_generation_settings"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&props); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+	if props.DefaultGenerationSettings.NCtx <= 0 {
+		return 0, errors.New("context window not reported by the server")
+	}
+	return props.DefaultGenerationSettings.NCtx, nil
+}
@@ -0,0 +1,306 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/types"
+)
+
+const testOllamaStreamBody = `{"model":"qwen2.5-coder","message":{"role":"assistant","content":"Hello"},"done":false}
+{"model":"qwen2.5-coder","message":{"role":"assistant","content":" world"},"done":false}
+{"model":"qwen2.5-coder","message":{"role":"assistant","content":""},"done":true,"done_reason":"stop","prompt_eval_count":10,"eval_count":2}
+`
+
+const testOllamaShowBody = `{
+	"parameters": "stop \"<|im_end|>\"\ntemperature 0.7",
+	"model_info": {"general.architecture": "qwen2", "qwen2.context_length": 32768}
+}`
+
+func newOllamaServer(t *testing.T, show string, chat string, check func(types.OllamaChatRequest)) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/show":
+			if show == "" {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":"model not found"}`)
+				return
+			}
+			fmt.Fprint(w, show)
+		case "/api/chat":
+			raw, _ := io.ReadAll(r.Body)
+			var req types.OllamaChatRequest
+			if err := json.Unmarshal(raw, &req); err != nil {
+				t.Errorf("failed to decode request: %v", err)
+			}
+			if check != nil {
+				check(req)
+			}
+			fmt.Fprint(w, chat)
+		default:
+			t.Errorf("unexpected request path %s", r.URL.Path)
+		}
+	}))
+}
+
+func TestNormalizeHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{host: "", want: types.OllamaDefaultHost},
+		{host: "127.0.0.1:11434", want: "http://127.0.0.1:11434"},
+		{host: "https://ollama.internal/", want: "https://ollama.internal"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeHost(tt.host, types.OllamaDefaultHost); got != tt.want {
+			t.Errorf("normalizeHost(%q) = %s, want %s", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestOllamaShowResponse_GetContextWindow(t *testing.T) {
+	tests := []struct {
+		name    string
+		show    OllamaShowResponse
+		want    int64
+		wantErr bool
+	}{
+		{
+			name: "num_ctx parameter wins",
+			show: OllamaShowResponse{
+				Parameters: "num_ctx 8192\ntemperature 0.2",
+				ModelInfo:  map[string]any{"llama.context_length": float64(131072)},
+			},
+			want: 8192,
+		},
+		{
+			name: "server default below the context length",
+			show: OllamaShowResponse{
+				ModelInfo: map[string]any{"general.architecture": "llama", "llama.context_length": float64(131072)},
+			},
+			want: LOCAL_DEFAULT_CONTEXT_WINDOW,
+		},
+		{
+			name: "context length below the server default",
+			show: OllamaShowResponse{
+				ModelInfo: map[string]any{"general.architecture": "phi", "phi.context_length": float64(2048)},
+			},
+			want: 2048,
+		},
+		{
+			name:    "not reported",
+			show:    OllamaShowResponse{},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.show.GetContextWindow()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetContextWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetContextWindow() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewOllamaLLM_DiscoversContextWindow(t *testing.T) {
+	show := `{"parameters": "num_ctx 16384", "model_info": {"qwen2.context_length": 32768}}`
+	server := newOllamaServer(t, show, "", nil)
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	llm := NewOllamaLLM(LLMRequest{Model: "qwen2.5-coder"})
+
+	if got := llm.GetMaxTokenCount(llm.GetSelectedModel()); got != 16384 {
+		t.Errorf("GetMaxTokenCount() = %d, want 16384", got)
+	}
+	if llm.NumCtx != 0 {
+		t.Errorf("NumCtx = %d, want the window left to the server", llm.NumCtx)
+	}
+}
+
+func TestNewOllamaLLM_NumCtxFromEnv(t *testing.T) {
+	server := newOllamaServer(t, testOllamaShowBody, "", nil)
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+	t.Setenv(OLLAMA_NUM_CTX_ENV, "32768")
+
+	llm := NewOllamaLLM(LLMRequest{Model: "qwen2.5-coder"})
+
+	if llm.NumCtx != 32768 || llm.GetMaxTokenCount(llm.GetSelectedModel()) != 32768 {
+		t.Errorf("NumCtx = %d, GetMaxTokenCount() = %d, want 32768", llm.NumCtx, llm.GetMaxTokenCount(llm.GetSelectedModel()))
+	}
+}
+
+func TestOllamaLLM_NewRequest_LeavesNumCtxToServer(t *testing.T) {
+	llm := OllamaLLM{SelectedModel: "qwen2.5-coder", ContextWindow: 131072}
+
+	if req := llm.newRequest("system", nil, false); req.Options != nil {
+		t.Errorf("request options = %v, want no num_ctx unless configured", req.Options)
+	}
+}
+
+func TestNewOllamaLLM_UnknownModelFallsBack(t *testing.T) {
+	server := newOllamaServer(t, "", "", nil)
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	llm := NewOllamaLLM(LLMRequest{Model: "missing"})
+
+	if got := llm.GetMaxTokenCount(llm.GetSelectedModel()); got != LOCAL_DEFAULT_CONTEXT_WINDOW {
+		t.Errorf("GetMaxTokenCount() = %d, want %d", got, LOCAL_DEFAULT_CONTEXT_WINDOW)
+	}
+}
+
+func TestNewLLM_OllamaModel(t *testing.T) {
+	server := newOllamaServer(t, testOllamaShowBody, "", nil)
+	defer server.Close()
+	t.Setenv("OLLAMA_HOST", server.URL)
+
+	llm, err := NewLLM("ollama/qwen2.5-coder")
+	if err != nil {
+		t.Fatalf("NewLLM() unexpected error: %v", err)
+	}
+	if _, ok := llm.(*OllamaLLM); !ok {
+		t.Fatalf("NewLLM() = %T, want *OllamaLLM", llm)
+	}
+	if llm.GetSelectedModel() != "qwen2.5-coder" {
+		t.Errorf("NewLLM() SelectedModel = %s, want qwen2.5-coder", llm.GetSelectedModel())
+	}
+}
+
+func TestOllamaClient_Chat(t *testing.T) {
+	server := newOllamaServer(t, "", `{"model":"qwen2.5-coder","message":{"role":"assistant","content":"Hello world"},"done":true}`, func(req types.OllamaChatRequest) {
+		if req.Stream {
+			t.Error("request should not be streamed")
+		}
+	})
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+	resp, err := client.Chat(types.OllamaChatRequest{Model: "qwen2.5-coder"}, nil)
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+	if resp.Message.Content != "Hello world" {
+		t.Errorf("Chat() content = %q, want Hello world", resp.Message.Content)
+	}
+}
+
+func TestOllamaClient_ChatStream(t *testing.T) {
+	server := newOllamaServer(t, "", testOllamaStreamBody, func(req types.OllamaChatRequest) {
+		if !req.Stream {
+			t.Error("request should be streamed")
+		}
+	})
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+
+	var deltas []string
+	resp, err := client.Chat(types.OllamaChatRequest{Model: "qwen2.5-coder", Stream: true}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("Chat() unexpected error: %v", err)
+	}
+
+	if strings.Join(deltas, "|") != "Hello| world" {
+		t.Errorf("Chat() deltas = %q, want [Hello  world]", deltas)
+	}
+	if resp.Message.Content != "Hello world" {
+		t.Errorf("Chat() content = %q, want Hello world", resp.Message.Content)
+	}
+	if resp.DoneReason != "stop" || resp.EvalCount != 2 {
+		t.Errorf("Chat() final chunk = %+v, want done_reason stop and eval_count 2", resp)
+	}
+}
+
+func TestOllamaClient_ChatStreamError(t *testing.T) {
+	body := `{"model":"qwen2.5-coder","message":{"role":"assistant","content":"Hel"},"done":false}
+{"error":"model runner has unexpectedly stopped"}
+`
+	server := newOllamaServer(t, "", body, nil)
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL)
+	_, err := client.Chat(types.OllamaChatRequest{Model: "qwen2.5-coder", Stream: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "unexpectedly stopped") {
+		t.Errorf("Chat() error = %v, want the stream error", err)
+	}
+}
+
+func TestOllamaLLM_CallStream(t *testing.T) {
+	server := newOllamaServer(t, "", testOllamaStreamBody, func(req types.OllamaChatRequest) {
+		if req.Options["num_ctx"] != float64(32768) {
+			t.Errorf("request num_ctx = %v, want 32768", req.Options["num_ctx"])
+		}
+	})
+	defer server.Close()
+
+	tmpDir := chdirWithOutputFiles(t)
+
+	llm := OllamaLLM{
+		SelectedModel: "qwen2.5-coder",
+		ContextWindow: 32768,
+		NumCtx:        32768,
+		Client:        NewOllamaClient(server.URL),
+	}
+
+	var tokens strings.Builder
+	resp, err := llm.CallStream("system", []types.Message{{Role: "user", Content: "hi"}}, func(token string) {
+		tokens.WriteString(token)
+	})
+	if err != nil {
+		t.Fatalf("CallStream() unexpected error: %v", err)
+	}
+	if resp.Response != "Hello world" || tokens.String() != "Hello world" {
+		t.Errorf("CallStream() response = %q, tokens = %q, want Hello world", resp.Response, tokens.String())
+	}
+
+	written, _ := os.ReadFile(tmpDir + "/wingman.md")
+	if string(written) != "Hello world" {
+		t.Errorf("CallStream() wrote %q to wingman.md, want Hello world", string(written))
+	}
+}
+
+func TestNewLlamaCppLLM_DiscoversContextWindow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/props" {
+			t.Errorf("unexpected request path %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"default_generation_settings":{"n_ctx":16384},"total_slots":1}`)
+	}))
+	defer server.Close()
+	t.Setenv("LLAMACPP_HOST", server.URL)
+
+	llm, err := NewLLM("llamacpp/qwen2.5-coder")
+	if err != nil {
+		t.Fatalf("NewLLM() unexpected error: %v", err)
+	}
+
+	openai, ok := llm.(*OpenAILLM)
+	if !ok {
+		t.Fatalf("NewLLM() = %T, want *OpenAILLM", llm)
+	}
+	if openai.Client.BaseURL != server.URL+"/v1" {
+		t.Errorf("BaseURL = %s, want %s/v1", openai.Client.BaseURL, server.URL)
+	}
+	if got := openai.GetMaxTokenCount(openai.GetSelectedModel()); got != 16384 {
+		t.Errorf("GetMaxTokenCount() = %d, want 16384", got)
+	}
+}
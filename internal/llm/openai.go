@@ -0,0 +1,358 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/tokenizer"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+const (
+	// Models with this prefix are always sent to the OpenAI compatible API,
+	// which is how models served by self-hosted servers are selected
+	OPENAI_MODEL_PREFIX = "openai/"
+
+	OPENAI_DEFAULT_MODEL          = "gpt-4o"
+	OPENAI_DEFAULT_CONTEXT_WINDOW = 8192
+)
+
+// Context window of the known OpenAI model families, the longest matching prefix wins
+var openAIContextWindows = map[string]int64{
+	"gpt-5":         400000,
+	"gpt-4.1":       1047576,
+	"gpt-4o":        128000,
+	"gpt-4-turbo":   128000,
+	"gpt-4-0125":    128000,
+	"gpt-4-1106":    128000,
+	"gpt-4-32k":     32768,
+	"gpt-4":         8192,
+	"gpt-3.5-turbo": 16385,
+	"chatgpt-4o":    128000,
+	"o1":            200000,
+	"o3":            200000,
+	"o4":            200000,
+}
+
+func isOpenAIModel(model string) bool {
+	for _, prefix := range []string{"gpt", "chatgpt", "o1", "o3", "o4"} {
+		if strings.HasPrefix(model, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type OpenAIClient struct {
+	APIKey     string
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+type OpenAIResponse struct {
+	ID      string            `json:"id"`
+	Object  string            `json:"object"`
+	Model   string            `json:"model"`
+	Choices []OpenAIChoice    `json:"choices"`
+	Usage   types.OpenAIUsage `json:"usage"`
+}
+
+type OpenAIChoice struct {
+	Index        int           `json:"index"`
+	Message      types.Message `json:"message"`
+	Delta        types.Message `json:"delta"`
+	FinishReason string        `json:"finish_reason"`
+}
+
+func NewOpenAIClient(apiKey string, baseURL string) *OpenAIClient {
+	if baseURL == "" {
+		baseURL = types.OpenAIAPIBaseURL
+	}
+	return &OpenAIClient{
+		APIKey:     apiKey,
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{},
+	}
+}
+
+func (c *OpenAIClient) newHTTPRequest(req types.OpenAIRequest) (*http.Request, error) {
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequest("POST", c.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+
+	return httpReq, nil
+}
+
+func openAIError(statusCode int, body []byte) error {
+	var errResp types.OpenAIErrorResponse
+	if err := json.Unmarshal(body, &errResp); err != nil || errResp.Error.Message == "" {
+		return fmt.Errorf("API error (status %d): %s", statusCode, string(body))
+	}
+	return fmt.Errorf("API error: %s - %s", errResp.Error.Type, errResp.Error.Message)
+}
+
+func (c *OpenAIClient) SendMessage(req types.OpenAIRequest) (*OpenAIResponse, error) {
+	httpReq, err := c.newHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, openAIError(resp.StatusCode, body)
+	}
+
+	var apiResp OpenAIResponse
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	return &apiResp, nil
+}
+
+/*
+StreamMessage sends the request with streaming enabled and calls onDelta with
+the content of every chunk. The returned response has the full answer in
+its first choice, like the one returned by SendMessage.
+*/
+func (c *OpenAIClient) StreamMessage(req types.OpenAIRequest, onDelta func(string)) (*OpenAIResponse, error) {
+	req.Stream = true
+	httpReq, err := c.newHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	resp, err := c.HTTPClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		return nil, openAIError(resp.StatusCode, body)
+	}
+
+	apiResp := &OpenAIResponse{}
+	var content strings.Builder
+	var finishReason string
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data:") {
+			continue
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "" {
+			continue
+		}
+		if data == "[DONE]" {
+			apiResp.Choices = []OpenAIChoice{
+				{
+					Message:      types.Message{Role: "assistant", Content: content.String()},
+					FinishReason: finishReason,
+				},
+			}
+			return apiResp, nil
+		}
+
+		var chunk OpenAIResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream chunk: %w", err)
+		}
+		apiResp.ID = chunk.ID
+		apiResp.Object = chunk.Object
+		apiResp.Model = chunk.Model
+		if chunk.Usage.TotalTokens != 0 {
+			apiResp.Usage = chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.FinishReason != "" {
+				finishReason = choice.FinishReason
+			}
+			if choice.Delta.Content == "" {
+				continue
+			}
+			content.WriteString(choice.Delta.Content)
+			if onDelta != nil {
+				onDelta(choice.Delta.Content)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+
+	return nil, errors.New("stream ended before [DONE]")
+}
+
+func (r *OpenAIResponse) GetTextResponse() string {
+	if len(r.Choices) == 0 {
+		return ""
+	}
+	return r.Choices[0].Message.Content
+}
+
+/*
+OpenAILLM implements LLM over the chat completions API.
+
+BaseURL of the client can point to any server implementing the same API
+(vLLM, llama.cpp, LM Studio...), see NewLLM for the environment it reads.
+ContextWindow overrides the known limits when the server reports its own.
+*/
+type OpenAILLM struct {
+	SelectedModel       string
+	Input               string
+	InputWithoutRepoMap string
+	ContextWindow       int64
+	Client              *OpenAIClient
+	Estimator           tokenizer.Estimator
+}
+
+func NewOpenAILLM(req LLMRequest) *OpenAILLM {
+	c := NewOpenAIClient(os.Getenv("OPENAI_API_KEY"), os.Getenv("OPENAI_BASE_URL"))
+
+	return &OpenAILLM{
+		SelectedModel:       req.Model,
+		Input:               req.Input,
+		InputWithoutRepoMap: req.InputWithoutRepoMap,
+		Client:              c,
+	}
+}
+
+func (o OpenAILLM) GetMaxTokenCount(model string) int64 {
+	if o.ContextWindow > 0 {
+		return o.ContextWindow
+	}
+
+	var longest string
+	for prefix := range openAIContextWindows {
+		if strings.HasPrefix(model, prefix) && len(prefix) > len(longest) {
+			longest = prefix
+		}
+	}
+	if longest == "" {
+		return OPENAI_DEFAULT_CONTEXT_WINDOW
+	}
+	return openAIContextWindows[longest]
+}
+
+func (o OpenAILLM) GetSelectedModel() string {
+	return o.SelectedModel
+}
+
+func (o OpenAILLM) GetInputTokenCount() int {
+	return o.CountTokens(o.Input)
+}
+
+func (o OpenAILLM) CountTokens(text string) int {
+	return countTokens(o.Estimator, text)
+}
+
+func (o OpenAILLM) WriteToHistory(request string, response *LLMResponse) error {
+	return writeToHistory(request, response)
+}
+
+func (o OpenAILLM) newRequest(system string, messages []types.Message) types.OpenAIRequest {
+	model := o.SelectedModel
+	if model == "" {
+		model = OPENAI_DEFAULT_MODEL
+	}
+	return types.OpenAIRequest{
+		Model:    model,
+		Messages: withSystemMessage(system, messages),
+	}
+}
+
+func (o OpenAILLM) Call(system string, messages []types.Message) (*LLMResponse, error) {
+	f, err := openOutputFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	resp, err := o.Prompt(system, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = f.WriteString(resp.Response); err != nil {
+		return nil, errors.New("Error writing to wingman.md")
+	}
+
+	return resp, nil
+}
+
+func (o OpenAILLM) Prompt(system string, messages []types.Message) (*LLMResponse, error) {
+	resp, err := o.Client.SendMessage(o.newRequest(system, messages))
+	if err != nil {
+		return nil, err
+	}
+
+	return &LLMResponse{
+		Response: resp.GetTextResponse(),
+	}, nil
+}
+
+func (o OpenAILLM) CallStream(system string, messages []types.Message, onToken func(string)) (*LLMResponse, error) {
+	f, err := openOutputFile()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var writeErr error
+	resp, err := o.Client.StreamMessage(o.newRequest(system, messages), func(token string) {
+		if _, err := f.WriteString(token); err != nil && writeErr == nil {
+			writeErr = errors.New("Error writing to wingman.md")
+		}
+		if onToken != nil {
+			onToken(token)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if writeErr != nil {
+		return nil, writeErr
+	}
+
+	return &LLMResponse{
+		Response: resp.GetTextResponse(),
+	}, nil
+}
@@ -0,0 +1,319 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/types"
+)
+
+const testOpenAIResponse = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"model": "gpt-4o",
+	"choices": [
+		{"index": 0, "message": {"role": "assistant", "content": "Hello world"}, "finish_reason": "stop"}
+	],
+	"usage": {"prompt_tokens": 9, "completion_tokens": 2, "total_tokens": 11}
+}`
+
+const testOpenAIStreamBody = `data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":"Hello"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{"content":" world"}}]}
+
+data: {"id":"chatcmpl-1","object":"chat.completion.chunk","model":"gpt-4o","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}
+
+data: [DONE]
+
+`
+
+func newOpenAIServer(t *testing.T, status int, body string, check func(types.OpenAIRequest)) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("request path = %s, want /v1/chat/completions", r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer test-key" {
+			t.Errorf("Authorization header = %q, want Bearer test-key", r.Header.Get("Authorization"))
+		}
+
+		raw, _ := io.ReadAll(r.Body)
+		var req types.OpenAIRequest
+		if err := json.Unmarshal(raw, &req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if check != nil {
+			check(req)
+		}
+
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}))
+}
+
+func newTestOpenAILLM(baseURL string) OpenAILLM {
+	return OpenAILLM{
+		SelectedModel: "gpt-4o",
+		Client:        NewOpenAIClient("test-key", baseURL+"/v1/"),
+	}
+}
+
+func chdirWithOutputFiles(t *testing.T) string {
+	t.Helper()
+
+	wd, _ := os.Getwd()
+	tmpDir := t.TempDir()
+	for _, name := range []string{"wingman.md", ".wingman.history.md"} {
+		if err := os.WriteFile(tmpDir+"/"+name, []byte{}, 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+	os.Chdir(tmpDir)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	return tmpDir
+}
+
+func TestNewOpenAIClient(t *testing.T) {
+	client := NewOpenAIClient("test-key", "")
+
+	if client.BaseURL != types.OpenAIAPIBaseURL {
+		t.Errorf("NewOpenAIClient() BaseURL = %s, want %s", client.BaseURL, types.OpenAIAPIBaseURL)
+	}
+	if client.HTTPClient == nil {
+		t.Error("NewOpenAIClient() HTTPClient is nil")
+	}
+
+	client = NewOpenAIClient("", "http://localhost:8080/v1/")
+	if client.BaseURL != "http://localhost:8080/v1" {
+		t.Errorf("NewOpenAIClient() BaseURL = %s, want trailing slash trimmed", client.BaseURL)
+	}
+}
+
+func TestNewOpenAILLM(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "test-key")
+	t.Setenv("OPENAI_BASE_URL", "http://localhost:11434/v1")
+
+	llm := NewOpenAILLM(LLMRequest{Model: "gpt-4o", Input: "test input"})
+
+	if llm.SelectedModel != "gpt-4o" {
+		t.Errorf("NewOpenAILLM() SelectedModel = %s, want gpt-4o", llm.SelectedModel)
+	}
+	if llm.Client.APIKey != "test-key" {
+		t.Errorf("NewOpenAILLM() APIKey = %s, want test-key", llm.Client.APIKey)
+	}
+	if llm.Client.BaseURL != "http://localhost:11434/v1" {
+		t.Errorf("NewOpenAILLM() BaseURL = %s, want http://localhost:11434/v1", llm.Client.BaseURL)
+	}
+}
+
+func TestOpenAILLM_GetMaxTokenCount(t *testing.T) {
+	tests := []struct {
+		model string
+		want  int64
+	}{
+		{model: "gpt-4o", want: 128000},
+		{model: "gpt-4o-mini", want: 128000},
+		{model: "gpt-4.1-nano", want: 1047576},
+		{model: "gpt-4", want: 8192},
+		{model: "gpt-4-32k", want: 32768},
+		{model: "gpt-3.5-turbo", want: 16385},
+		{model: "o3-mini", want: 200000},
+		{model: "some-self-hosted-model", want: OPENAI_DEFAULT_CONTEXT_WINDOW},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.model, func(t *testing.T) {
+			llm := OpenAILLM{}
+			if got := llm.GetMaxTokenCount(tt.model); got != tt.want {
+				t.Errorf("GetMaxTokenCount(%s) = %d, want %d", tt.model, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenAIClient_SendMessage(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK, testOpenAIResponse, func(req types.OpenAIRequest) {
+		if req.Model != "gpt-4o" {
+			t.Errorf("request model = %s, want gpt-4o", req.Model)
+		}
+		if req.Stream {
+			t.Error("request should not be streamed")
+		}
+		if len(req.Messages) != 1 || req.Messages[0].Role != "user" || req.Messages[0].Content != "hi" {
+			t.Errorf("request messages = %+v, want a single user message", req.Messages)
+		}
+	})
+	defer server.Close()
+
+	llm := newTestOpenAILLM(server.URL)
+	resp, err := llm.Client.SendMessage(llm.newRequest("", []types.Message{{Role: "user", Content: "hi"}}))
+	if err != nil {
+		t.Fatalf("SendMessage() unexpected error: %v", err)
+	}
+
+	if resp.GetTextResponse() != "Hello world" {
+		t.Errorf("SendMessage() text = %q, want Hello world", resp.GetTextResponse())
+	}
+	if resp.Usage.TotalTokens != 11 {
+		t.Errorf("SendMessage() Usage.TotalTokens = %d, want 11", resp.Usage.TotalTokens)
+	}
+}
+
+func TestOpenAIClient_SendMessage_APIError(t *testing.T) {
+	body := `{"error":{"message":"Incorrect API key provided","type":"invalid_request_error","code":"invalid_api_key"}}`
+	server := newOpenAIServer(t, http.StatusUnauthorized, body, nil)
+	defer server.Close()
+
+	llm := newTestOpenAILLM(server.URL)
+	_, err := llm.Client.SendMessage(llm.newRequest("", []types.Message{{Role: "user", Content: "hi"}}))
+	if err == nil {
+		t.Fatal("SendMessage() expected error for non 200 status")
+	}
+	if !strings.Contains(err.Error(), "Incorrect API key provided") {
+		t.Errorf("SendMessage() error = %v, want API error message", err)
+	}
+}
+
+func TestOpenAIClient_SendMessage_UnstructuredError(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusBadGateway, "bad gateway", nil)
+	defer server.Close()
+
+	llm := newTestOpenAILLM(server.URL)
+	_, err := llm.Client.SendMessage(llm.newRequest("", []types.Message{{Role: "user", Content: "hi"}}))
+	if err == nil || !strings.Contains(err.Error(), "status 502") {
+		t.Errorf("SendMessage() error = %v, want status 502", err)
+	}
+}
+
+func TestOpenAIClient_StreamMessage(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK, testOpenAIStreamBody, func(req types.OpenAIRequest) {
+		if !req.Stream {
+			t.Error("request should be streamed")
+		}
+	})
+	defer server.Close()
+
+	llm := newTestOpenAILLM(server.URL)
+
+	var deltas []string
+	resp, err := llm.Client.StreamMessage(llm.newRequest("", []types.Message{{Role: "user", Content: "hi"}}), func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("StreamMessage() unexpected error: %v", err)
+	}
+
+	if strings.Join(deltas, "|") != "Hello| world" {
+		t.Errorf("StreamMessage() deltas = %q, want [Hello  world]", deltas)
+	}
+	if resp.GetTextResponse() != "Hello world" {
+		t.Errorf("StreamMessage() text = %q, want Hello world", resp.GetTextResponse())
+	}
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("StreamMessage() FinishReason = %s, want stop", resp.Choices[0].FinishReason)
+	}
+}
+
+func TestOpenAIClient_StreamMessage_TruncatedStream(t *testing.T) {
+	body := strings.Split(testOpenAIStreamBody, "data: [DONE]")[0]
+	server := newOpenAIServer(t, http.StatusOK, body, nil)
+	defer server.Close()
+
+	llm := newTestOpenAILLM(server.URL)
+	if _, err := llm.Client.StreamMessage(llm.newRequest("", []types.Message{{Role: "user", Content: "hi"}}), nil); err == nil {
+		t.Error("StreamMessage() expected error when [DONE] is missing")
+	}
+}
+
+func TestOpenAILLM_Call(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK, testOpenAIResponse, nil)
+	defer server.Close()
+
+	tmpDir := chdirWithOutputFiles(t)
+
+	llm := newTestOpenAILLM(server.URL)
+	resp, err := llm.Call("", []types.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Call() unexpected error: %v", err)
+	}
+	if resp.Response != "Hello world" {
+		t.Errorf("Call() response = %q, want Hello world", resp.Response)
+	}
+
+	written, _ := os.ReadFile(tmpDir + "/wingman.md")
+	if string(written) != "Hello world" {
+		t.Errorf("Call() wrote %q to wingman.md, want Hello world", string(written))
+	}
+}
+
+func TestOpenAILLM_Prompt_SkipsOutputFile(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK, testOpenAIResponse, nil)
+	defer server.Close()
+
+	// No wingman.md to write to
+	wd, _ := os.Getwd()
+	tmpDir := t.TempDir()
+	os.Chdir(tmpDir)
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	llm := newTestOpenAILLM(server.URL)
+	resp, err := llm.Prompt("", []types.Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("Prompt() unexpected error: %v", err)
+	}
+	if resp.Response != "Hello world" {
+		t.Errorf("Prompt() response = %q, want Hello world", resp.Response)
+	}
+	if _, err := os.Stat(tmpDir + "/wingman.md"); !os.IsNotExist(err) {
+		t.Errorf("Prompt() created wingman.md, stat error = %v", err)
+	}
+}
+
+func TestOpenAILLM_CallStream(t *testing.T) {
+	server := newOpenAIServer(t, http.StatusOK, testOpenAIStreamBody, nil)
+	defer server.Close()
+
+	tmpDir := chdirWithOutputFiles(t)
+
+	llm := newTestOpenAILLM(server.URL)
+
+	var tokens strings.Builder
+	resp, err := llm.CallStream("", []types.Message{{Role: "user", Content: "hi"}}, func(token string) {
+		tokens.WriteString(token)
+	})
+	if err != nil {
+		t.Fatalf("CallStream() unexpected error: %v", err)
+	}
+	if resp.Response != "Hello world" || tokens.String() != "Hello world" {
+		t.Errorf("CallStream() response = %q, tokens = %q, want Hello world", resp.Response, tokens.String())
+	}
+
+	written, _ := os.ReadFile(tmpDir + "/wingman.md")
+	if string(written) != "Hello world" {
+		t.Errorf("CallStream() wrote %q to wingman.md, want Hello world", string(written))
+	}
+}
+
+func TestOpenAILLM_WriteToHistory(t *testing.T) {
+	tmpDir := chdirWithOutputFiles(t)
+
+	llm := OpenAILLM{}
+	if err := llm.WriteToHistory("question", &LLMResponse{Response: "answer"}); err != nil {
+		t.Fatalf("WriteToHistory() unexpected error: %v", err)
+	}
+
+	written, _ := os.ReadFile(tmpDir + "/.wingman.history.md")
+	if string(written) != "question\nanswer\n\n\n" {
+		t.Errorf("WriteToHistory() wrote %q, want question and answer", string(written))
+	}
+}
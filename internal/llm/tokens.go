@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"crypto/sha256"
+	"sync"
+
+	"github.com/manosriram/wingman/internal/tokenizer"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+type TokenCounterType string
+
+const (
+	APPROXIMATE_TOKEN_COUNTER TokenCounterType = "approximate"
+	ANTHROPIC_TOKEN_COUNTER   TokenCounterType = "anthropic"
+)
+
+var defaultEstimator tokenizer.Estimator = tokenizer.NewApproximateEstimator()
+
+func countTokens(estimator tokenizer.Estimator, text string) int {
+	if estimator == nil {
+		estimator = defaultEstimator
+	}
+	return estimator.CountTokens(text)
+}
+
+/*
+AnthropicEstimator counts tokens with the count_tokens endpoint, which uses
+the exact tokenizer of the selected model. Each count is a request, so the
+shell counts the assembled prompt once instead of its pieces. Counts are
+cached by content, and the approximation is used whenever the endpoint
+cannot be reached.
+*/
+type AnthropicEstimator struct {
+	Client   *Client
+	Model    string
+	Fallback tokenizer.Estimator
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]int
+}
+
+func NewAnthropicEstimator(client *Client, model string) *AnthropicEstimator {
+	return &AnthropicEstimator{
+		Client:   client,
+		Model:    model,
+		Fallback: tokenizer.NewApproximateEstimator(),
+		cache:    make(map[[sha256.Size]byte]int),
+	}
+}
+
+func (a *AnthropicEstimator) CountTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	key := sha256.Sum256([]byte(text))
+	a.mu.Lock()
+	if n, ok := a.cache[key]; ok {
+		a.mu.Unlock()
+		return n
+	}
+	a.mu.Unlock()
+
+	n, err := a.Client.CountTokens(types.Request{
+		Model: a.Model,
+		Messages: []types.Message{
+			{
+				Role:    "user",
+				Content: text,
+			},
+		},
+	})
+	if err != nil {
+		return a.Fallback.CountTokens(text)
+	}
+
+	a.mu.Lock()
+	a.cache[key] = n
+	a.mu.Unlock()
+
+	return n
+}
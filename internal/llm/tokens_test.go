@@ -0,0 +1,127 @@
+package llm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/tokenizer"
+)
+
+func newCountTokensServer(t *testing.T, status int, hits *int32) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(hits, 1)
+		if r.URL.Path != "/count_tokens" {
+			t.Errorf("request path = %s, want /count_tokens", r.URL.Path)
+		}
+
+		raw, _ := io.ReadAll(r.Body)
+		var req map[string]any
+		if err := json.Unmarshal(raw, &req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if _, ok := req["max_tokens"]; ok {
+			t.Errorf("request body = %s, want no max_tokens", string(raw))
+		}
+
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			fmt.Fprint(w, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			return
+		}
+		fmt.Fprint(w, `{"input_tokens":42}`)
+	}))
+}
+
+func TestClient_CountTokens(t *testing.T) {
+	var hits int32
+	server := newCountTokensServer(t, http.StatusOK, &hits)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+
+	n, err := client.CountTokens(newPromptRequest("hi"))
+	if err != nil {
+		t.Fatalf("CountTokens() unexpected error: %v", err)
+	}
+	if n != 42 {
+		t.Errorf("CountTokens() = %d, want 42", n)
+	}
+}
+
+func TestAnthropicEstimator_CachesCounts(t *testing.T) {
+	var hits int32
+	server := newCountTokensServer(t, http.StatusOK, &hits)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+	estimator := NewAnthropicEstimator(client, "claude-sonnet-4-20250514")
+
+	for i := 0; i < 3; i++ {
+		if got := estimator.CountTokens("func main() {}"); got != 42 {
+			t.Errorf("CountTokens() = %d, want 42", got)
+		}
+	}
+	if hits != 1 {
+		t.Errorf("count_tokens called %d times, want 1", hits)
+	}
+
+	if got := estimator.CountTokens(""); got != 0 {
+		t.Errorf("CountTokens(\"\") = %d, want 0", got)
+	}
+	if hits != 1 {
+		t.Errorf("count_tokens called for empty text")
+	}
+}
+
+func TestAnthropicEstimator_FallsBackOnError(t *testing.T) {
+	var hits int32
+	server := newCountTokensServer(t, http.StatusServiceUnavailable, &hits)
+	defer server.Close()
+
+	client := NewClient("test-key")
+	client.BaseURL = server.URL
+	estimator := NewAnthropicEstimator(client, "claude-sonnet-4-20250514")
+
+	text := "func getUserName(id int) string {"
+	want := tokenizer.NewApproximateEstimator().CountTokens(text)
+	if got := estimator.CountTokens(text); got != want {
+		t.Errorf("CountTokens() = %d, want the approximation %d", got, want)
+	}
+}
+
+func TestClaudeLLM_UseTokenCounter(t *testing.T) {
+	claude := ClaudeLLM{SelectedModel: "claude-sonnet-4-20250514", Client: NewClient("test-key")}
+
+	if err := claude.UseTokenCounter(ANTHROPIC_TOKEN_COUNTER); err != nil {
+		t.Fatalf("UseTokenCounter() unexpected error: %v", err)
+	}
+	estimator, ok := claude.Estimator.(*AnthropicEstimator)
+	if !ok {
+		t.Fatalf("Estimator = %T, want *AnthropicEstimator", claude.Estimator)
+	}
+	if estimator.Model != claude.SelectedModel {
+		t.Errorf("Estimator model = %s, want %s", estimator.Model, claude.SelectedModel)
+	}
+
+	if err := claude.UseTokenCounter(TokenCounterType("words")); err == nil {
+		t.Error("UseTokenCounter() expected error for an unknown counter")
+	}
+}
+
+func TestCountTokens_DefaultsToApproximation(t *testing.T) {
+	providers := []LLM{ClaudeLLM{}, OpenAILLM{}, OllamaLLM{}}
+	for _, p := range providers {
+		if got := p.CountTokens("hello world"); got != 2 {
+			t.Errorf("%T.CountTokens() = %d, want 2", p, got)
+		}
+	}
+}
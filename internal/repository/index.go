@@ -0,0 +1,347 @@
+package repository
+
+import (
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/manosriram/wingman/internal/ast"
+	"github.com/manosriram/wingman/internal/cache"
+	"github.com/manosriram/wingman/internal/symbols"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+)
+
+/*
+indexedFile is what the indexing workers learn about one file. Packages are
+filled by the first pass, the AST, imports and symbols by the second one,
+which needs the packages of every file to resolve imports. Cached is the
+entry of the index cache when the file did not change since it was written.
+*/
+type indexedFile struct {
+	Path     string
+	Data     []byte
+	Hash     string
+	Cached   *cache.Entry
+	Parsed   bool // Whether anything had to be parsed again
+	Packages []string
+	AST      *ast.AST
+	Imports  []types.NodeImport
+	Symbols  types.NodeSymbols
+	Err      error
+}
+
+// Workers indexing the repository when Repository.Workers is not set
+func defaultWorkers() int {
+	return runtime.NumCPU()
+}
+
+/*
+forEachFile runs work on every file with a bounded pool of workers. A
+tree-sitter parser must not be used by two goroutines at once, so every
+worker owns its own parsers, freed when the pass is over. Errors are kept in
+the file they come from.
+*/
+func (r *Repository) forEachFile(files []*indexedFile, work func(f *indexedFile, parser utils.TreeSitterParserType)) {
+	workers := r.Workers
+	if workers <= 0 {
+		workers = defaultWorkers()
+	}
+	workers = min(workers, len(files))
+
+	queue := make(chan *indexedFile)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			parser := utils.NewTreeSitterParserType()
+			defer parser.Close()
+
+			for f := range queue {
+				work(f, parser)
+			}
+		}()
+	}
+
+	for _, f := range files {
+		queue <- f
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// The error of the first file that failed, in walk order
+func firstError(files []*indexedFile) error {
+	for _, f := range files {
+		if f.Err != nil {
+			return f.Err
+		}
+	}
+	return nil
+}
+
+/*
+The index cache of the repository: the index of the last run when there is
+one, else the one on disk, empty when it is not cached.
+*/
+func (r *Repository) loadCache(manifests []string) *cache.Index {
+	manifestsHash := cache.HashFiles(manifests)
+	if r.indexCache != nil && r.indexCache.Manifests == manifestsHash {
+		return r.indexCache
+	}
+	if r.CacheDir == "" {
+		return cache.NewIndex(r.TargetDir, manifestsHash)
+	}
+	return cache.Load(r.CacheDir, r.TargetDir, manifestsHash)
+}
+
+/*
+index reads and parses every file of the repository concurrently and fills
+PkgPaths, RepositoryNodesAST, NodeImports, Symbols and Signatures. Results
+are merged in walk order once each pass is over, so they do not depend on
+how the work was scheduled. Files whose hash is in the index cache are not
+parsed again unless files were added or removed, their imports are not
+resolved again unless the packages changed too. It returns the indexed paths
+in walk order.
+*/
+func (r *Repository) index() ([]string, error) {
+	paths, manifests, _, err := r.listFiles()
+	if err != nil {
+		return nil, err
+	}
+	cached := r.loadCache(manifests)
+	index := cache.NewIndex(r.TargetDir, cached.Manifests)
+	index.Tree = cache.TreeHash(paths)
+
+	files := make([]*indexedFile, len(paths))
+	for i, path := range paths {
+		files[i] = &indexedFile{Path: path}
+	}
+
+	r.forEachFile(files, func(f *indexedFile, parser utils.TreeSitterParserType) {
+		f.Data, f.Err = os.ReadFile(f.Path)
+		if f.Err != nil {
+			return
+		}
+
+		f.Hash = cache.Hash(f.Data)
+		if entry, ok := cached.Files[f.Path]; ok && entry.Hash == f.Hash {
+			f.Cached = &entry
+			if cached.Tree == index.Tree {
+				f.Packages = entry.Packages
+				return
+			}
+		}
+		f.Parsed = true
+		f.Packages = r.getStrategyWithParser(f.Path, f.Data, parser).GetNodePackages()
+	})
+	// Files which cannot be read are left out, like unreadable directories
+	files = slices.DeleteFunc(files, func(f *indexedFile) bool {
+		return f.Err != nil
+	})
+	paths = paths[:0]
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	for _, f := range files {
+		for _, pkg := range f.Packages {
+			r.PkgPaths[pkg] = append(r.PkgPaths[pkg], f.Path)
+		}
+	}
+	index.Packages = cache.PackagesHash(r.PkgPaths)
+	sameImports := cached.Tree == index.Tree && cached.Packages == index.Packages
+
+	// PkgPaths is only read from here on
+	r.forEachFile(files, func(f *indexedFile, parser utils.TreeSitterParserType) {
+		f.AST = ast.NewASTFromData(f.Path, f.Data, r.PkgPaths, parser)
+		if f.Cached == nil {
+			f.Parsed = true
+			f.Symbols = r.getStrategyWithParser(f.Path, f.Data, parser).GetNodeSymbols()
+		} else {
+			f.Symbols = f.Cached.Symbols
+		}
+
+		if f.Cached != nil && sameImports {
+			f.Imports = f.Cached.Imports
+			return
+		}
+		f.Parsed = true
+		f.Imports, f.Err = f.AST.GetNodeImports()
+	})
+	if err := firstError(files); err != nil {
+		return nil, err
+	}
+
+	r.CachedFiles = 0
+	for _, f := range files {
+		// The parsers of the worker are freed, the AST keeps the repository's
+		f.AST.Parser = r.TreeSitterLanguageParser
+		r.RepositoryNodesAST[f.Path] = f.AST
+		r.NodeImports[f.Path] = f.Imports
+		r.Symbols.Add(f.Path, f.Symbols)
+
+		r.Signatures[f.Path] = definitionSignatures(f.Symbols)
+
+		if !f.Parsed {
+			r.CachedFiles++
+		}
+		index.Files[f.Path] = cache.Entry{
+			Hash:     f.Hash,
+			Packages: f.Packages,
+			Imports:  f.Imports,
+			Symbols:  f.Symbols,
+		}
+	}
+
+	r.indexCache = index
+	if r.CacheDir != "" {
+		// The cache only saves time, a repository it cannot be written to is still indexed
+		_ = index.Save(r.CacheDir)
+	}
+	return paths, nil
+}
+
+// Signatures of the definitions of a file, as declared
+func definitionSignatures(nodeSymbols types.NodeSymbols) []string {
+	signatures := []string{}
+	for _, d := range nodeSymbols.Definitions {
+		signatures = append(signatures, d.Signature)
+	}
+	return signatures
+}
+
+/*
+reindex parses again the indexed files among paths whose contents changed,
+and patches their entries in NodeImports, RepositoryNodesAST, Signatures,
+Symbols and the index cache. The file and symbol graphs are patched too, the
+edges of the files which may refer to the changed ones resolved again. The
+maps and graphs are copied before they are changed, the repository they
+were taken from may be in use, see PrepareRefresh. It returns the changed
+paths, and false when a file cannot be read anymore or its packages changed:
+other files may import it differently then, and the whole repository has
+to be indexed again.
+*/
+func (r *Repository) reindex(paths []string) ([]string, bool, error) {
+	var files []*indexedFile
+	for _, path := range paths {
+		if _, ok := r.indexCache.Files[path]; ok {
+			files = append(files, &indexedFile{Path: path})
+		}
+	}
+	slices.SortFunc(files, func(a, b *indexedFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+
+	r.forEachFile(files, func(f *indexedFile, parser utils.TreeSitterParserType) {
+		f.Data, f.Err = os.ReadFile(f.Path)
+		if f.Err != nil {
+			return
+		}
+		f.Hash = cache.Hash(f.Data)
+		if f.Hash == r.indexCache.Files[f.Path].Hash {
+			return
+		}
+
+		f.Parsed = true
+		strategy := r.getStrategyWithParser(f.Path, f.Data, parser)
+		f.Packages = strategy.GetNodePackages()
+		f.Symbols = strategy.GetNodeSymbols()
+		f.AST = ast.NewASTFromData(f.Path, f.Data, r.PkgPaths, parser)
+		f.Imports, f.Err = f.AST.GetNodeImports()
+	})
+
+	var changed []*indexedFile
+	for _, f := range files {
+		switch {
+		case f.Err != nil && !f.Parsed:
+			// Removed or unreadable since it was listed
+			return nil, false, nil
+		case f.Err != nil:
+			return nil, false, f.Err
+		case !f.Parsed:
+			continue
+		}
+		if !slices.Equal(f.Packages, r.indexCache.Files[f.Path].Packages) {
+			return nil, false, nil
+		}
+		changed = append(changed, f)
+	}
+	if len(changed) == 0 {
+		return nil, true, nil
+	}
+
+	r.NodeImports = maps.Clone(r.NodeImports)
+	r.RepositoryNodesAST = maps.Clone(r.RepositoryNodesAST)
+	r.Signatures = maps.Clone(r.Signatures)
+	r.Symbols = &symbols.Index{
+		Definitions: maps.Clone(r.Symbols.Definitions),
+		References:  maps.Clone(r.Symbols.References),
+	}
+	index := *r.indexCache
+	index.Files = maps.Clone(index.Files)
+	r.indexCache = &index
+
+	var changedPaths []string
+	previous := make(map[string][]types.Symbol, len(changed))
+	for _, f := range changed {
+		changedPaths = append(changedPaths, f.Path)
+		previous[f.Path] = r.Symbols.Definitions[f.Path]
+
+		f.AST.Parser = r.TreeSitterLanguageParser
+		r.RepositoryNodesAST[f.Path] = f.AST
+		r.NodeImports[f.Path] = f.Imports
+		r.Symbols.Add(f.Path, f.Symbols)
+		r.Signatures[f.Path] = definitionSignatures(f.Symbols)
+		index.Files[f.Path] = cache.Entry{
+			Hash:     f.Hash,
+			Packages: f.Packages,
+			Imports:  f.Imports,
+			Symbols:  f.Symbols,
+		}
+	}
+
+	dependents := r.Symbols.Dependents(changedPaths, r.NodeImports)
+	r.Graph = r.Graph.Clone()
+	r.patchFileGraph(dependents)
+	r.SymbolGraph = r.SymbolGraph.Clone()
+	r.Symbols.PatchGraph(r.SymbolGraph, r.NodeImports, previous, dependents)
+	return changedPaths, true, nil
+}
+
+/*
+patchFileGraph builds the edges of the files in sources again from their
+imports, weighted by weightImportEdges. Nodes left without any edge are
+removed, a graph built from scratch has none.
+*/
+func (r *Repository) patchFileGraph(sources map[string]bool) {
+	var candidates []string
+	for _, src := range slices.Sorted(maps.Keys(sources)) {
+		candidates = append(candidates, src)
+		for _, n := range r.Graph.GetOutNodesOfNode(src) {
+			candidates = append(candidates, n.NodeValue)
+		}
+		r.Graph.RemoveOutEdges(src)
+	}
+	for _, src := range slices.Sorted(maps.Keys(sources)) {
+		r.Graph.BuildGraphFromImports(r.NodeImports[src])
+	}
+	r.weightImportEdges(sources)
+
+	reached := make(map[string]bool)
+	for _, edges := range r.Graph.G {
+		for _, n := range edges {
+			reached[n.NodeValue] = true
+		}
+	}
+	var isolated []string
+	for _, node := range candidates {
+		if len(r.Graph.GetOutNodesOfNode(node)) == 0 && !reached[node] {
+			isolated = append(isolated, node)
+		}
+	}
+	r.Graph.RemoveNodes(isolated...)
+}
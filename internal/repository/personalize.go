@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	inputIdentifierRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*`)
+	inputPathRegex       = regexp.MustCompile(`[\w./-]*[\w-]\.\w+`)
+)
+
+/*
+inputFiles returns the scanned files the question is about: the files added
+to the chat and the ones whose path relative to TargetDir, or file name, is
+mentioned in input.
+*/
+func (r *Repository) inputFiles(input string) map[string]bool {
+	files := make(map[string]bool)
+	for path := range r.AddedFiles {
+		if abs, err := filepath.Abs(path); err == nil {
+			if _, ok := r.NodeImports[abs]; ok {
+				files[abs] = true
+			}
+		}
+	}
+
+	mentioned := make(map[string]bool)
+	for _, token := range inputPathRegex.FindAllString(input, -1) {
+		mentioned[strings.TrimPrefix(filepath.ToSlash(token), "./")] = true
+	}
+	if len(mentioned) == 0 {
+		return files
+	}
+
+	for path := range r.NodeImports {
+		rel, err := filepath.Rel(r.TargetDir, path)
+		if err != nil {
+			continue
+		}
+		if mentioned[filepath.ToSlash(rel)] || mentioned[filepath.Base(path)] || mentioned[path] {
+			files[path] = true
+		}
+	}
+	return files
+}
+
+/*
+personalization returns the teleport weights of the file graph and of the
+symbol graph for a question. Each file the question is about weighs 1, in
+the symbol graph that weight is shared by the file and its symbols so a
+large file does not outweigh a small one. Each symbol whose name is
+mentioned in input weighs 1 too, and adds as much to its file.
+*/
+func (r *Repository) personalization(input string) (files map[string]float64, symbols map[string]float64) {
+	files = make(map[string]float64)
+	symbols = make(map[string]float64)
+
+	for path := range r.inputFiles(input) {
+		files[path] += 1
+
+		definitions := r.Symbols.Definitions[path]
+		share := 1.0 / float64(len(definitions)+1)
+		symbols[path] += share
+		for _, d := range definitions {
+			symbols[d.ID()] += share
+		}
+	}
+
+	mentioned := make(map[string]bool)
+	for _, name := range inputIdentifierRegex.FindAllString(input, -1) {
+		mentioned[name] = true
+	}
+	for _, s := range r.Symbols.Symbols() {
+		if mentioned[s.Name] {
+			symbols[s.ID()] += 1
+			files[s.FilePath] += 1
+		}
+	}
+	return files, symbols
+}
+
+/*
+RankForInput re-ranks the files and symbols for a question with the context
+algorithm. PageRank is personalized toward the files added to the chat and
+the files and symbols mentioned in input, so the repo map is about what is
+asked; the other algorithms rank the same for every question. Without any
+of them the ranking is the one of Run.
+*/
+func (r *Repository) RankForInput(input string) error {
+	if len(r.NodeImports) == 0 {
+		// Nothing was scanned, keep whatever ranking was set
+		return nil
+	}
+
+	files, symbols := r.personalization(input)
+
+	if _, err := r.scoreFiles(files); err != nil {
+		return err
+	}
+	return r.rankSymbols(symbols)
+}
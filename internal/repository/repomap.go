@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"sort"
+
+	"github.com/manosriram/wingman/internal/llm"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+type RepoMapOptions struct {
+	// Tokens the whole prompt may use, 0 means no limit
+	MaxTokens int
+
+	// The question and the previous turns are sent along with the map,
+	// their tokens are reserved before any file is added
+	Input   string
+	History []types.Message
+
+	CountTokens func(string) int
+}
+
+type RepoMap struct {
+	Entries          []llm.RepoMapEntry
+	TruncatedFiles   []string // Files left out of the map, most important first
+	TruncatedSymbols int      // Ranked symbols left out of the map
+	TokenCount       int      // Tokens used by the entries
+	ReservedTokens   int      // Tokens used by the instructions, added files, question and history
+	TokenBudget      int
+}
+
+func (m RepoMap) IsTruncated() bool {
+	return len(m.TruncatedFiles) > 0 || m.TruncatedSymbols > 0
+}
+
+/*
+BuildRepoMap fills the map within the token budget. With ranked symbols it
+adds them one by one in score order, so a heavily used function makes it in
+without the rest of its file, see buildSymbolEntries. Otherwise, as when the
+symbols were not ranked, it adds the signatures of whole files in score
+order until the next file does not fit in the budget anymore. Everything
+from that file on is reported in TruncatedFiles.
+*/
+func (r *Repository) BuildRepoMap(opts RepoMapOptions) RepoMap {
+	countTokens := opts.CountTokens
+	if countTokens == nil {
+		countTokens = llm.EstimateTokenCount
+	}
+
+	repoMap := RepoMap{
+		Entries:     []llm.RepoMapEntry{},
+		TokenBudget: opts.MaxTokens,
+	}
+
+	repoMap.ReservedTokens = countTokens(types.BASE_LLM_PROMPT) +
+		countTokens(llm.FormatAddedFiles(r.AddedFiles)) +
+		countTokens(opts.Input)
+	for _, m := range opts.History {
+		repoMap.ReservedTokens += countTokens(m.Content)
+	}
+
+	if len(r.RankedSymbols) > 0 {
+		r.buildSymbolEntries(&repoMap, opts.MaxTokens, countTokens)
+		return repoMap
+	}
+
+	for i, path := range r.RankedFiles {
+		entry := llm.RepoMapEntry{
+			Path:       path,
+			Signatures: r.Signatures[path],
+		}
+		tokens := countTokens(llm.FormatRepoMapEntry(entry))
+
+		if opts.MaxTokens > 0 && repoMap.ReservedTokens+repoMap.TokenCount+tokens > opts.MaxTokens {
+			repoMap.TruncatedFiles = append(repoMap.TruncatedFiles, r.RankedFiles[i:]...)
+			break
+		}
+
+		repoMap.Entries = append(repoMap.Entries, entry)
+		repoMap.TokenCount += tokens
+	}
+
+	return repoMap
+}
+
+/*
+buildSymbolEntries adds the ranked symbols to the map until the next one
+does not fit in the budget anymore. The symbols are grouped in one entry per
+file, the entries ordered by their best symbol and the signatures of an
+entry in declaration order. A symbol costs the tokens of its signature line,
+plus those of the path line and the blank line closing the entry when it is
+the first of its file. Each piece is counted once, a counter asking a server
+makes one request per signature rather than one per entry rebuilt.
+Files none of whose symbols made it in are reported in TruncatedFiles.
+*/
+func (r *Repository) buildSymbolEntries(repoMap *RepoMap, maxTokens int, countTokens func(string) int) {
+	entries := make(map[string]int) // File path vs its index in Entries
+	included := make(map[string][]types.Symbol)
+
+	for i, symbol := range r.RankedSymbols {
+		path := symbol.FilePath
+		tokens := countTokens(symbol.Signature + "\n")
+		if _, ok := entries[path]; !ok {
+			tokens += countTokens(llm.FormatRepoMapEntry(llm.RepoMapEntry{Path: path}))
+		}
+
+		if maxTokens > 0 && repoMap.ReservedTokens+repoMap.TokenCount+tokens > maxTokens {
+			repoMap.TruncatedSymbols = len(r.RankedSymbols) - i
+			break
+		}
+
+		fileSymbols := append(append([]types.Symbol{}, included[path]...), symbol)
+		sort.SliceStable(fileSymbols, func(i, j int) bool {
+			return fileSymbols[i].Line < fileSymbols[j].Line
+		})
+		entry := llm.RepoMapEntry{Path: path}
+		for _, s := range fileSymbols {
+			entry.Signatures = append(entry.Signatures, s.Signature)
+		}
+
+		if index, ok := entries[path]; ok {
+			repoMap.Entries[index] = entry
+		} else {
+			entries[path] = len(repoMap.Entries)
+			repoMap.Entries = append(repoMap.Entries, entry)
+		}
+		included[path] = fileSymbols
+		repoMap.TokenCount += tokens
+	}
+
+	for _, path := range r.RankedFiles {
+		if _, ok := entries[path]; !ok && len(r.Symbols.Definitions[path]) > 0 {
+			repoMap.TruncatedFiles = append(repoMap.TruncatedFiles, path)
+		}
+	}
+}
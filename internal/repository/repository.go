@@ -1,26 +1,49 @@
 package repository
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
 	"sort"
+	"time"
 
+	"github.com/manosriram/wingman/internal/algorithm"
 	"github.com/manosriram/wingman/internal/ast"
+	"github.com/manosriram/wingman/internal/cache"
+	"github.com/manosriram/wingman/internal/edit"
+	"github.com/manosriram/wingman/internal/git"
 	"github.com/manosriram/wingman/internal/graph"
+	"github.com/manosriram/wingman/internal/ignore"
+	"github.com/manosriram/wingman/internal/language"
 	"github.com/manosriram/wingman/internal/llm"
+	"github.com/manosriram/wingman/internal/symbols"
 	"github.com/manosriram/wingman/internal/types"
 	"github.com/manosriram/wingman/internal/utils"
-	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 )
 
+// Commits the recency context algorithm looks back at
+const RECENCY_MAX_COMMITS = 1000
+
 type Repository struct {
 	TargetDir                string
+	ContextAlgorithm         types.ContextAlgorithmType
+	ChangeTimes              map[string][]time.Time // Commit times by file path, loaded for the recency algorithm
 	Graph                    *graph.Graph
 	TreeSitterLanguageParser utils.TreeSitterParserType
 	PkgPaths                 map[string][]string
 	NodeImports              map[string][]types.NodeImport // Pkg vs Imports
 	RepositoryNodesAST       map[string]*ast.AST
 	Signatures               map[string][]string
+	RankedFiles              []string                   // Paths sorted by score, most important first
+	Algorithm                algorithm.ContextAlgorithm // Scores of the files, shared by their ASTs
+	Symbols                  *symbols.Index
+	SymbolGraph              *graph.Graph
+	RankedSymbols            []types.Symbol // Definitions sorted by score, most important first
 	AddedFiles               map[string]string
+	Workers                  int    // Files indexed at once, the number of CPUs when not set
+	CacheDir                 string // Where the index is cached between runs, not cached when empty
+	CachedFiles              int    // Files the last Run took from the cache rather than parsing them
+	indexCache               *cache.Index
 }
 
 type KeyValue struct {
@@ -33,109 +56,220 @@ func NewRepository(targetDir string) *Repository {
 
 	return &Repository{
 		TargetDir:                targetDir,
+		ContextAlgorithm:         types.DEFAULT_CONTEXT_ALGORITHM,
 		TreeSitterLanguageParser: treeSitterLanguageParser,
 		Graph:                    graph.NewGraph(),
 		NodeImports:              make(map[string][]types.NodeImport),
 		RepositoryNodesAST:       make(map[string]*ast.AST),
 		PkgPaths:                 make(map[string][]string),
 		Signatures:               make(map[string][]string),
+		Symbols:                  symbols.NewIndex(),
 		AddedFiles:               make(map[string]string),
+		CacheDir:                 filepath.Join(targetDir, cache.CACHE_DIR),
 	}
 }
 
-func getFunctionInfo(node *tree_sitter.Node, source []byte) (name string, params string) {
-	if nameNode := node.ChildByFieldName("name"); nameNode != nil {
-		name = string(source[nameNode.StartByte():nameNode.EndByte()])
-	}
-	if paramsNode := node.ChildByFieldName("parameters"); paramsNode != nil {
-		params = string(source[paramsNode.StartByte():paramsNode.EndByte()])
+func (r *Repository) GetNodeSignatures(path string) []string {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return []string{}
 	}
-	return name, params
-}
 
-func getNodeSignatures(node *tree_sitter.Node, source []byte) []string {
-	var signatures []string
-
-	if node.Kind() == "function_declaration" || node.Kind() == "method_declaration" {
-		// Extract the signature text (from start to the opening brace)
-		// Or extract individual parts: name, parameters, result
-
-		fnName, fnParams := getFunctionInfo(node, source)
-
-		signatures = append(signatures, fnName+fnParams)
-	}
+	return language.GetStrategy(language.StrategyArgs{
+		NodeData:         d,
+		NodePath:         path,
+		RootDir:          r.TargetDir,
+		Parser:           r.TreeSitterLanguageParser,
+		PkgPaths:         r.PkgPaths,
+		StrategyLanguage: utils.GetLanguage(path),
+	}).GetNodeSignatures()
+}
 
-	for i := uint(0); i < node.ChildCount(); i++ {
-		signatures = append(signatures, getNodeSignatures(node.Child(i), source)...)
+func (r *Repository) GetNodeSymbols(path string) types.NodeSymbols {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return types.NodeSymbols{}
 	}
-	return signatures
+	return r.getStrategy(path, d).GetNodeSymbols()
 }
 
-func (r *Repository) GetNodeSignatures(path string) []string {
-	p := r.TreeSitterLanguageParser.Parsers[types.GOLANG]
-
-	d, _ := os.ReadFile(path)
+/*
+newContextAlgorithm returns the selected context algorithm, personalized
+toward the given nodes when it is PageRank.
+*/
+func (r *Repository) newContextAlgorithm(personalization map[string]float64) (algorithm.ContextAlgorithm, error) {
+	return algorithm.GetContextAlgorithm(algorithm.ContextAlgorithmArgs{
+		AlgorithmType:   r.ContextAlgorithm,
+		Personalization: personalization,
+		ChangeTimes:     r.ChangeTimes,
+	})
+}
 
-	tree := p.Parse(d, nil)
-	root := tree.RootNode()
+// The recency algorithm scores files by their git history, read once
+func (r *Repository) loadChangeTimes() error {
+	if r.ContextAlgorithm != types.RECENCY_CONTEXT_ALGORITHM || r.ChangeTimes != nil {
+		return nil
+	}
 
-	return getNodeSignatures(root, d)
+	repo, err := git.Open(r.TargetDir)
+	if err != nil {
+		return err
+	}
+	changeTimes, err := repo.FileChangeTimes(RECENCY_MAX_COMMITS)
+	if err != nil {
+		return err
+	}
+	r.ChangeTimes = changeTimes
+	return nil
 }
 
 func (r *Repository) Run() error {
-	if err := r.walkDirAndPopulateRepositoryPkgPaths(); err != nil {
+	if _, err := r.newContextAlgorithm(nil); err != nil {
 		return err
 	}
-	if err := r.walkDirAndPopulateNodeImports(); err != nil {
+	if err := r.loadChangeTimes(); err != nil {
 		return err
 	}
+	return r.build()
+}
 
-	for _, v := range r.NodeImports {
-		r.Graph.BuildGraphFromImports(v)
+/*
+build indexes the repository, starting over from empty maps so it can run
+again when files change, then scores and ranks it.
+*/
+func (r *Repository) build() error {
+	r.Graph = graph.NewGraph()
+	r.PkgPaths = make(map[string][]string)
+	r.NodeImports = make(map[string][]types.NodeImport)
+	r.RepositoryNodesAST = make(map[string]*ast.AST)
+	r.Signatures = make(map[string][]string)
+	r.Symbols = symbols.NewIndex()
+	r.RankedFiles = nil
+	r.RankedSymbols = nil
+
+	paths, err := r.index()
+	if err != nil {
+		return err
 	}
 
-	for k := range r.NodeImports {
-		err := r.RepositoryNodesAST[k].CalculateASTNodesScore(r.Graph)
-		if err != nil {
-			return err
+	for _, path := range paths {
+		r.Graph.BuildGraphFromImports(r.NodeImports[path])
+	}
+	r.weightImportEdges(nil)
+	r.SymbolGraph = r.Symbols.BuildGraph(r.NodeImports)
+
+	if err := r.score(); err != nil {
+		return err
+	}
+	for _, a := range r.RepositoryNodesAST {
+		a.Algorithm = r.Algorithm
+	}
+
+	// The repo map itself is assembled per question within the token budget, see BuildRepoMap
+	return nil
+}
+
+/*
+weightImportEdges adds the references a file makes to the definitions of a
+file it imports to the weight of the import edge, so the files an importer
+actually uses weigh more than the rest of the package. Only the edges of
+the files in sources are weighted, the ones of every file when it is nil.
+*/
+func (r *Repository) weightImportEdges(sources map[string]bool) {
+	for src, targets := range r.Symbols.FileReferences(r.NodeImports, sources) {
+		for _, n := range r.Graph.GetOutNodesOfNode(src) {
+			if weight, ok := targets[n.NodeValue]; ok {
+				r.Graph.AddWeightedEdge(src, n.NodeValue, weight)
+			}
 		}
 	}
+}
 
-	// Sort scores descending
-	nodeVsScores := make(map[string]float64)
-	for k := range r.NodeImports {
-		nodeVsScores[k] = r.RepositoryNodesAST[k].Algorithm.GetScoreForNode(k)
-		// fmt.Printf("path = %s, score = %f\n", k, r.RepositoryNodesAST[k].Algorithm.GetScoreForNode(k))
+// score ranks the files and then the symbols, the ranking of the files breaking ties between symbols
+func (r *Repository) score() error {
+	contextAlgorithm, err := r.scoreFiles(nil)
+	if err != nil {
+		return err
 	}
+	r.Algorithm = contextAlgorithm
+	return r.rankSymbols(nil)
+}
+
+/*
+scoreFiles is the scoring stage: it runs the context algorithm once over the
+whole file graph, personalized toward the given nodes when it is PageRank,
+and orders the files by their scores.
+*/
+func (r *Repository) scoreFiles(personalization map[string]float64) (algorithm.ContextAlgorithm, error) {
+	contextAlgorithm, err := r.newContextAlgorithm(personalization)
+	if err != nil {
+		return nil, err
+	}
+	contextAlgorithm.CalculateScore(r.Graph)
+	r.sortRankedFiles(contextAlgorithm.GetScoreForNode)
+	return contextAlgorithm, nil
+}
 
+// Orders the scanned files by score into RankedFiles
+func (r *Repository) sortRankedFiles(score func(path string) float64) {
 	var sorted []KeyValue
-	for k, v := range nodeVsScores {
-		sorted = append(sorted, KeyValue{k, v})
+	for k := range r.NodeImports {
+		sorted = append(sorted, KeyValue{k, score(k)})
 	}
 
+	// Sort scores descending
 	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Value == sorted[j].Value {
+			return sorted[i].Key < sorted[j].Key
+		}
 		return sorted[i].Value > sorted[j].Value
 	})
 
+	r.RankedFiles = r.RankedFiles[:0]
 	for _, v := range sorted {
-		r.Signatures[v.Key] = r.GetNodeSignatures(v.Key)
+		r.RankedFiles = append(r.RankedFiles, v.Key)
 	}
+}
 
-	/* TODO
-	We now have scores and the file paths.
-
-	1. Create Repo Map by:
-		a. Reading the path, and appending the signatures to the final LLM prompt
+/*
+rankSymbols scores the symbol graph with the context algorithm, biased
+toward the personalized nodes if any, and orders the symbols by score. The
+symbols scoring the same, as the ones nothing refers to, are ordered by the
+rank of their file and then as declared.
+*/
+func (r *Repository) rankSymbols(personalization map[string]float64) error {
+	contextAlgorithm, err := r.newContextAlgorithm(personalization)
+	if err != nil {
+		return err
+	}
+	contextAlgorithm.CalculateScore(r.SymbolGraph)
 
-	2. Loop (1) until
-		a. All paths are done
-		b. Token limit is exhausted for the selected specific LLM (default 500K tokens)
-	*/
+	fileRank := make(map[string]int, len(r.RankedFiles))
+	for i, path := range r.RankedFiles {
+		fileRank[path] = i
+	}
 
+	ranked := r.Symbols.Symbols()
+	sort.SliceStable(ranked, func(i, j int) bool {
+		si, sj := contextAlgorithm.GetScoreForNode(ranked[i].ID()), contextAlgorithm.GetScoreForNode(ranked[j].ID())
+		if si != sj {
+			return si > sj
+		}
+		if fileRank[ranked[i].FilePath] != fileRank[ranked[j].FilePath] {
+			return fileRank[ranked[i].FilePath] < fileRank[ranked[j].FilePath]
+		}
+		return ranked[i].Line < ranked[j].Line
+	})
+	r.RankedSymbols = ranked
 	return nil
 }
 
+// AddFile adds a file to the chat, unless a .gitignore or .wingmanignore of the repository ignores it
 func (r *Repository) AddFile(path string) error {
+	if r.newIgnoreMatcher().Ignored(path, false) {
+		return fmt.Errorf("%s is ignored by a %s or %s", path, ignore.GIT_IGNORE_FILE, ignore.WINGMAN_IGNORE_FILE)
+	}
+
 	d, err := os.ReadFile(path)
 	if err != nil {
 		return err
@@ -164,6 +298,66 @@ func (r *Repository) DropFiles(paths []string) {
 	}
 }
 
-func (r *Repository) CreateMasterPrompt(input string) string {
-	return llm.CreateMasterPrompt(r.Signatures, r.AddedFiles, input)
+func (r *Repository) CreateSystemPrompt(repoMap RepoMap) string {
+	return llm.CreateSystemPrompt(repoMap.Entries, r.AddedFiles)
+}
+
+// ApplyEdits patches the files under TargetDir, nothing is written when any edit fails to apply
+func (r *Repository) ApplyEdits(edits []edit.Edit) ([]edit.FileChange, error) {
+	changes, err := r.PrepareEdits(edits)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.WriteChanges(changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// PrepareEdits computes the changes of edits without touching the files, for previewing them
+func (r *Repository) PrepareEdits(edits []edit.Edit) ([]edit.FileChange, error) {
+	return edit.NewApplier(r.TargetDir).Prepare(edits)
+}
+
+func (r *Repository) WriteChanges(changes []edit.FileChange) error {
+	if err := edit.NewApplier(r.TargetDir).Write(changes); err != nil {
+		return err
+	}
+	r.refreshAddedFiles(changes)
+	return nil
+}
+
+func (r *Repository) UndoChanges(changes []edit.FileChange) error {
+	if err := edit.NewApplier(r.TargetDir).Revert(changes); err != nil {
+		return err
+	}
+	r.refreshAddedFiles(changes)
+	return nil
+}
+
+/*
+refreshAddedFiles reloads the added files touched by changes so the next
+prompt holds what is on disk. Files are added with the path typed in /add,
+which may be relative, while changes hold absolute paths.
+*/
+func (r *Repository) refreshAddedFiles(changes []edit.FileChange) {
+	changed := make(map[string]bool)
+	for _, change := range changes {
+		changed[change.Path] = true
+	}
+
+	for path := range r.AddedFiles {
+		abs, err := filepath.Abs(path)
+		if err != nil || !changed[abs] {
+			continue
+		}
+
+		d, err := os.ReadFile(path)
+		if err != nil {
+			// Undoing an edit which created the file removes it
+			delete(r.AddedFiles, path)
+			continue
+		}
+		r.AddedFiles[path] = string(d)
+	}
 }
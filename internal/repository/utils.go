@@ -1,26 +1,20 @@
 package repository
 
 import (
-	"bufio"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
-	"github.com/manosriram/wingman/internal/ast"
+	"github.com/manosriram/wingman/internal/cache"
+	"github.com/manosriram/wingman/internal/gomod"
+	"github.com/manosriram/wingman/internal/ignore"
+	"github.com/manosriram/wingman/internal/language"
 	"github.com/manosriram/wingman/internal/types"
 	"github.com/manosriram/wingman/internal/utils"
 )
 
-func (r *Repository) walkDirAndPopulateRepositoryPkgPaths() error {
-	return filepath.WalkDir(r.TargetDir, r.populateRepositoryPkgPaths)
-}
-
-func (r *Repository) walkDirAndPopulateNodeImports() error {
-	return filepath.WalkDir(r.TargetDir, r.populateRepositoryNodeImports)
-}
-
 // func (g *GolangStrategy) resolveImportNodes(args ResolveImportNodesArgs) []types.NodeImport {
 
 // rootNode := args.RootNode
@@ -47,90 +41,117 @@ func (r *Repository) walkDirAndPopulateNodeImports() error {
 // return []string{}
 // }
 
-func (r *Repository) populateRepositoryPkgPaths(path string, d fs.DirEntry, err error) error {
-	if d.IsDir() {
-		if d.Name() == ".git" || d.Name() == ".aider" {
-			return filepath.SkipDir
-		}
-	} else {
+// Directories never walked, they hold metadata, third party code or build output
+var skipDirs = []string{".git", ".aider", cache.CACHE_DIR, "node_modules"}
 
-		var pkg string
-		if utils.GetLanguage(path) == types.GOLANG {
+// Cargo's build output, a target directory next to a Cargo.toml. Elsewhere target may be a package of its own.
+func isCargoTarget(path string) bool {
+	if filepath.Base(path) != "target" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(filepath.Dir(path), "Cargo.toml"))
+	return err == nil
+}
 
-			file, err := os.Open(path)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer file.Close()
+func (r *Repository) getStrategy(path string, data []byte) language.LangStrategy {
+	return r.getStrategyWithParser(path, data, r.TreeSitterLanguageParser)
+}
 
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				pkg = scanner.Text()
-				break
+// getStrategy with a parser of its own, for the indexing workers
+func (r *Repository) getStrategyWithParser(path string, data []byte, parser utils.TreeSitterParserType) language.LangStrategy {
+	return language.GetStrategy(language.StrategyArgs{
+		NodeData:         data,
+		NodePath:         path,
+		RootDir:          r.TargetDir,
+		Parser:           parser,
+		PkgPaths:         r.PkgPaths,
+		StrategyLanguage: utils.GetLanguage(path),
+	})
+}
+
+/*
+Files the imports of a language are resolved with, the index cache is
+dropped when one changes.
+*/
+func isManifest(name string) bool {
+	switch name {
+	case "go.mod", "go.work", "Cargo.toml":
+		return true
+	}
+	return filepath.Ext(name) == ".json" && (strings.HasPrefix(name, "tsconfig") || strings.HasPrefix(name, "jsconfig"))
+}
+
+/*
+listFiles returns the paths of the files of a known language under TargetDir
+and of the manifests, see isManifest and withGoManifests, in lexical order.
+Files ignored by a .gitignore or .wingmanignore are left out, the ignore
+files which were looked for are returned too.
+*/
+func (r *Repository) listFiles() (files []string, manifests []string, ignoreFiles []string, err error) {
+	matcher := r.newIgnoreMatcher()
+	err = filepath.WalkDir(r.TargetDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// An unreadable entry is left out rather than keeping the whole repository out
+			if path == r.TargetDir {
+				return err
 			}
-			if err := scanner.Err(); err != nil {
-				log.Fatal(err)
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
 			}
-
+			return nil
 		}
-		// } else {
-		// pkg = path
-		// }
-
-		if pkg != "" {
-			if utils.GetLanguage(path) == types.GOLANG && len(strings.Split(pkg, " ")) > 1 {
-				pkg = strings.Split(pkg, " ")[1]
+		if d.IsDir() {
+			if slices.Contains(skipDirs, d.Name()) || isCargoTarget(path) || (path != r.TargetDir && matcher.IgnoredEntry(path, true)) {
+				return filepath.SkipDir
 			}
-
-			r.PkgPaths[pkg] = append(r.PkgPaths[pkg], path)
-
+			return nil
+		}
+		if matcher.IgnoredEntry(path, false) {
+			return nil
+		}
+		if utils.GetLanguage(path) != types.UNKNOWN {
+			files = append(files, path)
+		} else if isManifest(d.Name()) {
+			manifests = append(manifests, path)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	return nil
+	return files, r.withGoManifests(manifests), matcher.Files(), nil
 }
 
-func (r *Repository) populateRepositoryNodeImports(path string, d fs.DirEntry, err error) error {
-	if d.IsDir() {
-		if d.Name() == ".git" || d.Name() == ".aider" {
-			return filepath.SkipDir
-		}
-	} else {
-
-		var pkg string
-		if utils.GetLanguage(path) == types.GOLANG {
-
-			file, err := os.Open(path)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer file.Close()
-
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				pkg = scanner.Text()
-				break
-			}
-			if err := scanner.Err(); err != nil {
-				log.Fatal(err)
-			}
+// The ignore files of the whole work tree apply, also the ones above TargetDir
+func (r *Repository) newIgnoreMatcher() *ignore.Matcher {
+	return ignore.NewMatcher(ignore.WorkTreeRoot(r.TargetDir))
+}
 
+/*
+withGoManifests adds to manifests the go.mod and go.work files the Go
+resolvers are built from, and the go.mod files of local replace targets.
+They are outside TargetDir when wingman runs in a subdirectory of a module
+or workspace, or a replace points next to it. The result is sorted.
+*/
+func (r *Repository) withGoManifests(manifests []string) []string {
+	dirs := []string{r.TargetDir}
+	for _, path := range manifests {
+		if filepath.Base(path) == "go.mod" {
+			dirs = append(dirs, filepath.Dir(path))
 		}
-		// } else {
-		// pkg = path
-		// }
-
-		if pkg != "" {
-			if utils.GetLanguage(path) == types.GOLANG && len(strings.Split(pkg, " ")) > 1 {
-				pkg = strings.Split(pkg, " ")[1]
-			}
+	}
 
-			r.RepositoryNodesAST[path] = ast.NewAST(path, r.PkgPaths, r.TreeSitterLanguageParser)
-			imports, err := r.RepositoryNodesAST[path].GetNodeImports()
-			if err != nil {
-				return err
-			}
-			r.NodeImports[path] = imports
+	all := slices.Clone(manifests)
+	for _, dir := range dirs {
+		resolver, err := gomod.NewResolver(dir)
+		if err != nil {
+			continue
+		}
+		all = append(all, resolver.Files...)
+		for _, m := range resolver.Modules {
+			all = append(all, filepath.Join(m.Dir, "go.mod"))
 		}
 	}
-	return nil
+	slices.Sort(all)
+	return slices.Compact(all)
 }
@@ -0,0 +1,368 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/manosriram/wingman/internal/ignore"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+)
+
+// Time between two polls of the Watcher
+const DEFAULT_WATCH_INTERVAL = 2 * time.Second
+
+// RefreshResult tells what Refresh found changed on disk
+type RefreshResult struct {
+	ChangedFiles  []string // Source files created, modified or removed, sorted
+	MapChanged    bool     // Whether the ranking or the signatures, so the repo map, changed
+	ReloadedFiles []string // Added files whose contents changed, sorted
+	DroppedFiles  []string // Added files which no longer exist, sorted
+}
+
+func (r RefreshResult) HasChanges() bool {
+	return len(r.ChangedFiles) > 0 || len(r.ReloadedFiles) > 0 || len(r.DroppedFiles) > 0
+}
+
+/*
+RepositoryUpdate is the repository indexed and scored again, built aside by
+PrepareRefresh and swapped in by ApplyRefresh.
+*/
+type RepositoryUpdate struct {
+	next              *Repository // nil when no indexed file changed
+	changedFiles      []string
+	signaturesChanged bool
+}
+
+/*
+Refresh brings the repository up to date with the files on disk, for callers
+which own the repository and do not know which files changed: it is indexed
+again as by Run, the files which did not change taken from the index.
+*/
+func (r *Repository) Refresh() (RefreshResult, error) {
+	update, err := r.PrepareRefresh(nil)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	return r.ApplyRefresh(update), nil
+}
+
+// A repository sharing the index of r, for a refresh to build the next one aside
+func (r *Repository) indexCopy() *Repository {
+	return &Repository{
+		TargetDir:                r.TargetDir,
+		ContextAlgorithm:         r.ContextAlgorithm,
+		ChangeTimes:              r.ChangeTimes,
+		Graph:                    r.Graph,
+		TreeSitterLanguageParser: r.TreeSitterLanguageParser,
+		PkgPaths:                 r.PkgPaths,
+		NodeImports:              r.NodeImports,
+		RepositoryNodesAST:       r.RepositoryNodesAST,
+		Signatures:               r.Signatures,
+		Symbols:                  r.Symbols,
+		SymbolGraph:              r.SymbolGraph,
+		Workers:                  r.Workers,
+		CacheDir:                 r.CacheDir,
+		CachedFiles:              r.CachedFiles,
+		indexCache:               r.indexCache,
+	}
+}
+
+/*
+structureChanged tells whether any of paths may change which files are
+indexed or what their imports resolve to: a source file created or removed,
+a manifest or an ignore file.
+*/
+func (r *Repository) structureChanged(paths []string) bool {
+	for _, path := range paths {
+		if _, ok := r.indexCache.Files[path]; ok {
+			if _, err := os.Stat(path); err != nil {
+				return true
+			}
+			continue
+		}
+		if isManifest(filepath.Base(path)) || ignore.IsIgnoreFile(path) {
+			return true
+		}
+		if rel, err := filepath.Rel(r.TargetDir, path); err == nil && !strings.HasPrefix(rel, "..") && utils.GetLanguage(path) != types.UNKNOWN {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+PrepareRefresh indexes and scores the repository again after the files at
+paths were created, modified or removed. The result is built aside from the
+fields only a refresh changes, so it runs while the repository is in use and
+ApplyRefresh swaps it in under the lock guarding the repository.
+
+When only the contents of indexed files changed, just those are parsed
+again, see reindex, and the repository scored again. Files created or
+removed, a manifest, an ignore file or packages which changed can change
+what any import resolves to, the repository is then indexed again as by
+Run, taking the files which did not change from the index. So it is when
+paths is nil. The index cache on disk is only written then: its entries are
+keyed by contents, the next run parses the files changed since again.
+*/
+func (r *Repository) PrepareRefresh(paths []string) (*RepositoryUpdate, error) {
+	next := r.indexCopy()
+	if next.ContextAlgorithm == types.RECENCY_CONTEXT_ALGORITHM {
+		// New commits change the recency of files
+		next.ChangeTimes = nil
+		if err := next.loadChangeTimes(); err != nil {
+			return nil, err
+		}
+	}
+
+	update := &RepositoryUpdate{next: next}
+	rebuild := paths == nil || r.indexCache == nil || r.structureChanged(paths)
+	if !rebuild {
+		changed, ok, err := next.reindex(paths)
+		if err != nil {
+			return nil, err
+		}
+		if ok && len(changed) == 0 && next.ContextAlgorithm != types.RECENCY_CONTEXT_ALGORITHM {
+			return &RepositoryUpdate{}, nil
+		}
+		rebuild = !ok
+		update.changedFiles = changed
+	}
+
+	if rebuild {
+		if err := next.build(); err != nil {
+			return nil, err
+		}
+		update.changedFiles = changedFiles(r, next)
+	} else if err := next.score(); err != nil {
+		return nil, err
+	}
+	update.signaturesChanged = !reflect.DeepEqual(r.Signatures, next.Signatures)
+	return update, nil
+}
+
+/*
+ApplyRefresh swaps in the index PrepareRefresh built and reloads the added
+files. The caller holds the lock guarding the repository, if any.
+*/
+func (r *Repository) ApplyRefresh(update *RepositoryUpdate) RefreshResult {
+	result := RefreshResult{ChangedFiles: update.changedFiles}
+	if next := update.next; next != nil {
+		result.MapChanged = update.signaturesChanged || !reflect.DeepEqual(r.RankedFiles, next.RankedFiles)
+
+		r.ChangeTimes = next.ChangeTimes
+		r.Graph = next.Graph
+		r.PkgPaths = next.PkgPaths
+		r.NodeImports = next.NodeImports
+		r.RepositoryNodesAST = next.RepositoryNodesAST
+		r.Signatures = next.Signatures
+		r.Symbols = next.Symbols
+		r.SymbolGraph = next.SymbolGraph
+		r.Algorithm = next.Algorithm
+		r.RankedFiles = next.RankedFiles
+		r.RankedSymbols = next.RankedSymbols
+		r.CachedFiles = next.CachedFiles
+		r.indexCache = next.indexCache
+		for _, a := range r.RepositoryNodesAST {
+			a.Algorithm = r.Algorithm
+		}
+	}
+	result.ReloadedFiles, result.DroppedFiles = r.reloadAddedFiles()
+	return result
+}
+
+// Indexed files whose hash differs between two runs, or which only one of them has
+func changedFiles(prev *Repository, next *Repository) []string {
+	var changed []string
+	for path, entry := range next.indexCache.Files {
+		if old, ok := prev.indexCache.Files[path]; !ok || old.Hash != entry.Hash {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev.indexCache.Files {
+		if _, ok := next.indexCache.Files[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// reloadAddedFiles reads the added files again, dropping the ones which were removed
+func (r *Repository) reloadAddedFiles() (reloaded []string, dropped []string) {
+	for path, contents := range r.AddedFiles {
+		d, err := os.ReadFile(path)
+		if err != nil {
+			delete(r.AddedFiles, path)
+			dropped = append(dropped, path)
+			continue
+		}
+		if string(d) != contents {
+			r.AddedFiles[path] = string(d)
+			reloaded = append(reloaded, path)
+		}
+	}
+	sort.Strings(reloaded)
+	sort.Strings(dropped)
+	return reloaded, dropped
+}
+
+type fileState struct {
+	ModTime time.Time
+	Size    int64
+}
+
+/*
+Watcher polls the files of a repository, the ones it indexes, the manifests,
+the ignore files and the added files, and refreshes the repository when one
+of them changes: our own edits as well as git checkouts. Polling needs
+nothing from the platform and a stat per file is cheap next to parsing. Lock
+guards the repository, which the shell uses from other goroutines.
+*/
+type Watcher struct {
+	Repository *Repository
+	Interval   time.Duration
+	Lock       sync.Locker
+	OnRefresh  func(RefreshResult) // Called after a refresh which changed anything
+	OnError    func(error)
+	states     map[string]fileState
+	stop       chan struct{}
+	done       chan struct{}
+}
+
+func NewWatcher(r *Repository, interval time.Duration, lock sync.Locker) *Watcher {
+	if interval <= 0 {
+		interval = DEFAULT_WATCH_INTERVAL
+	}
+	if lock == nil {
+		lock = &sync.Mutex{}
+	}
+	return &Watcher{
+		Repository: r,
+		Interval:   interval,
+		Lock:       lock,
+	}
+}
+
+/*
+Files the watcher looks at and their state, the ones which cannot be read are
+left out. Ignore files are among them, an edited pattern changes the files
+which are indexed.
+*/
+func (w *Watcher) snapshot() (map[string]fileState, error) {
+	files, manifests, ignoreFiles, err := w.Repository.listFiles()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, ignoreFiles...)
+
+	w.Lock.Lock()
+	for path := range w.Repository.AddedFiles {
+		files = append(files, path)
+	}
+	w.Lock.Unlock()
+
+	states := make(map[string]fileState, len(files)+len(manifests))
+	for _, path := range append(files, manifests...) {
+		if fi, err := os.Stat(path); err == nil {
+			states[path] = fileState{ModTime: fi.ModTime(), Size: fi.Size()}
+		}
+	}
+	return states, nil
+}
+
+/*
+Poll compares the files with the last poll and refreshes the repository when
+any of them changed. The first poll only records the state of the files. The
+refresh is prepared without the lock, which is only held to swap it in.
+*/
+func (w *Watcher) Poll() (RefreshResult, error) {
+	states, err := w.snapshot()
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	if w.states == nil {
+		w.states = states
+		return RefreshResult{}, nil
+	}
+
+	changed := changedPaths(w.states, states)
+	if len(changed) == 0 {
+		return RefreshResult{}, nil
+	}
+	update, err := w.Repository.PrepareRefresh(changed)
+	if err != nil {
+		return RefreshResult{}, err
+	}
+	w.states = states
+
+	w.Lock.Lock()
+	defer w.Lock.Unlock()
+	return w.Repository.ApplyRefresh(update), nil
+}
+
+// Paths created, modified or removed between two snapshots, sorted
+func changedPaths(prev, states map[string]fileState) []string {
+	var changed []string
+	for path, state := range states {
+		if old, ok := prev[path]; !ok || !old.ModTime.Equal(state.ModTime) || old.Size != state.Size {
+			changed = append(changed, path)
+		}
+	}
+	for path := range prev {
+		if _, ok := states[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Start records the state of the files and polls them every Interval until Stop
+func (w *Watcher) Start() error {
+	if _, err := w.Poll(); err != nil {
+		return err
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func() {
+		defer close(w.done)
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				result, err := w.Poll()
+				if err != nil {
+					if w.OnError != nil {
+						w.OnError(err)
+					}
+					continue
+				}
+				if result.HasChanges() && w.OnRefresh != nil {
+					w.OnRefresh(result)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop ends the polling, waiting for a refresh in progress
+func (w *Watcher) Stop() {
+	if w.stop == nil {
+		return
+	}
+	close(w.stop)
+	<-w.done
+	w.stop = nil
+}
@@ -0,0 +1,170 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/manosriram/wingman/internal/edit"
+	"github.com/rivo/tview"
+)
+
+// PendingChanges holds the previewed changes of the last answer until they are confirmed
+type PendingChanges struct {
+	mu      sync.Mutex
+	Changes []edit.FileChange
+}
+
+func (p *PendingChanges) Set(changes []edit.FileChange) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.Changes = changes
+}
+
+// Take returns the pending changes and clears them
+func (p *PendingChanges) Take() []edit.FileChange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changes := p.Changes
+	p.Changes = nil
+	return changes
+}
+
+func (p *PendingChanges) Has() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return len(p.Changes) > 0
+}
+
+// colorDiff escapes a unified diff and colors it for the output view
+func colorDiff(diff string) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+
+		escaped := tview.Escape(line)
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+			fmt.Fprintf(&out, "[::b]%s[::-]", escaped)
+		case strings.HasPrefix(line, "@@"):
+			fmt.Fprintf(&out, "[cyan]%s[-]", escaped)
+		case strings.HasPrefix(line, "+"):
+			fmt.Fprintf(&out, "[green]%s[-]", escaped)
+		case strings.HasPrefix(line, "-"):
+			fmt.Fprintf(&out, "[red]%s[-]", escaped)
+		default:
+			out.WriteString(escaped)
+		}
+	}
+	return out.String()
+}
+
+/*
+previewEdits reads the search/replace blocks of an answer and shows the diff
+of the changes they make. Nothing is written until the next line confirms
+them, see confirmChanges.
+*/
+func (s Shell) previewEdits(response string) string {
+	edits, err := edit.ParseEdits(response)
+	if err != nil {
+		return fmt.Sprintf("[red]Could not read the edits: %s[-]\n", tview.Escape(err.Error()))
+	}
+	if len(edits) == 0 {
+		return ""
+	}
+
+	unlock := s.lockRepository()
+	changes, err := s.Repository.PrepareEdits(edits)
+	unlock()
+	if err != nil {
+		return fmt.Sprintf("[red]No edits applied: %s[-]\n", tview.Escape(err.Error()))
+	}
+
+	var preview strings.Builder
+	for _, change := range changes {
+		preview.WriteString(colorDiff(edit.Diff(change)))
+	}
+	preview.WriteString("[yellow]Apply these changes? (y/n)[-]\n")
+
+	s.Pending.Set(changes)
+	return preview.String()
+}
+
+// confirmChanges writes the pending changes when answer is yes and discards them otherwise
+func (s Shell) confirmChanges(answer string) string {
+	changes := s.Pending.Take()
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+	default:
+		return "Discarded the changes\n"
+	}
+
+	unlock := s.lockRepository()
+	err := s.Repository.WriteChanges(changes)
+	unlock()
+	if err != nil {
+		return fmt.Sprintf("[red]No edits applied: %s[-]\n", tview.Escape(err.Error()))
+	}
+
+	var summary strings.Builder
+	for _, change := range changes {
+		if change.Existed {
+			fmt.Fprintf(&summary, "[green]Applied edit to %s[-]\n", change.Path)
+		} else {
+			fmt.Fprintf(&summary, "[green]Created %s[-]\n", change.Path)
+		}
+	}
+
+	set := edit.ChangeSet{Changes: changes}
+	if s.autoCommitEnabled() {
+		hash, message, err := s.commitPaths(changedPaths(changes), "")
+		if err != nil {
+			fmt.Fprintf(&summary, "[red]Could not commit the changes: %s[-]\n", tview.Escape(err.Error()))
+		} else {
+			set.Commit = hash
+			fmt.Fprintf(&summary, "Committed %s %s\n", hash, tview.Escape(commitSubject(message)))
+		}
+	}
+	s.EditHistory.Push(set)
+
+	return summary.String()
+}
+
+// undoChanges restores the files of the last applied change set
+func (s Shell) undoChanges() string {
+	set, ok := s.EditHistory.Pop()
+	if !ok {
+		return "Nothing to undo\n"
+	}
+
+	unlock := s.lockRepository()
+	err := s.Repository.UndoChanges(set.Changes)
+	unlock()
+	if err != nil {
+		// Keep the change set so the user can fix the file and try again
+		s.EditHistory.Push(set)
+		return fmt.Sprintf("[red]Could not undo: %s[-]\n", tview.Escape(err.Error()))
+	}
+
+	var summary strings.Builder
+	for _, change := range set.Changes {
+		fmt.Fprintf(&summary, "Undid changes to %s\n", change.Path)
+	}
+
+	// The undo is committed too, so the history keeps matching the work tree
+	if set.Commit != "" && s.autoCommitEnabled() {
+		hash, err := s.Git.Commit(fmt.Sprintf("Undo wingman edit %s", set.Commit), changedPaths(set.Changes)...)
+		if err != nil {
+			fmt.Fprintf(&summary, "[red]Could not commit the undo: %s[-]\n", tview.Escape(err.Error()))
+		} else {
+			fmt.Fprintf(&summary, "Committed %s Undo wingman edit %s\n", hash, set.Commit)
+		}
+	}
+	return summary.String()
+}
@@ -0,0 +1,177 @@
+package shell
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/manosriram/wingman/internal/edit"
+	"github.com/manosriram/wingman/internal/git"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/rivo/tview"
+)
+
+// Files wingman writes itself, never committed and not counted as uncommitted work
+var wingmanFiles = []string{"wingman.md", ".wingman.history.md"}
+
+// Diffs are cut to this many bytes before being sent to generate a commit message
+const COMMIT_DIFF_MAX_BYTES = 32 * 1024
+
+/*
+openGitRepo returns the repository containing targetDir, nil when there is
+none or git is not installed. Auto commits are refused over uncommitted
+changes unless allowDirty is set, since the edits would get mixed with them.
+*/
+func openGitRepo(targetDir string, autoCommit bool, allowDirty bool) (*git.Repo, error) {
+	repo, err := git.Open(targetDir)
+	if err != nil {
+		return nil, nil
+	}
+
+	if autoCommit && !allowDirty {
+		dirty, err := repo.IsDirty(wingmanFiles...)
+		if err != nil {
+			return nil, err
+		}
+		if dirty {
+			return nil, fmt.Errorf("%s has uncommitted changes, commit or stash them or run with -allow-dirty", repo.Dir)
+		}
+	}
+	return repo, nil
+}
+
+func (s Shell) autoCommitEnabled() bool {
+	return s.Git != nil && s.Flags.AutoCommit != nil && *s.Flags.AutoCommit
+}
+
+func changedPaths(changes []edit.FileChange) []string {
+	paths := make([]string, 0, len(changes))
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+	return paths
+}
+
+func commitSubject(message string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	return subject
+}
+
+/*
+commitPaths commits paths with message, generating one from their diff when
+message is empty. The hash and message of the commit are returned.
+*/
+func (s Shell) commitPaths(paths []string, message string) (string, string, error) {
+	if s.Git == nil {
+		return "", "", git.ErrNotARepository
+	}
+
+	if message == "" {
+		if err := s.Git.Stage(paths...); err != nil {
+			return "", "", err
+		}
+		diff, err := s.Git.StagedDiff(paths...)
+		if err != nil {
+			return "", "", err
+		}
+		message = s.generateCommitMessage(diff, paths)
+	}
+
+	hash, err := s.Git.Commit(message, paths...)
+	if err != nil {
+		return "", "", err
+	}
+	return hash, message, nil
+}
+
+// generateCommitMessage asks the LLM to describe diff, a generic message is used when it fails
+func (s Shell) generateCommitMessage(diff string, paths []string) string {
+	fallback := "wingman: update " + strings.Join(paths, ", ")
+	if s.LLM == nil || strings.TrimSpace(diff) == "" {
+		return fallback
+	}
+
+	if len(diff) > COMMIT_DIFF_MAX_BYTES {
+		diff = diff[:COMMIT_DIFF_MAX_BYTES] + "\n[diff truncated]\n"
+	}
+
+	// The commit message is not part of the conversation, keep it out of wingman.md
+	response, err := s.LLM.Prompt("", []types.Message{
+		{
+			Role:    "user",
+			Content: types.COMMIT_MESSAGE_PROMPT + diff,
+		},
+	})
+	if err != nil {
+		return fallback
+	}
+
+	message := cleanCommitMessage(response.Response)
+	if message == "" {
+		return fallback
+	}
+	return message
+}
+
+// Models like to wrap the message in code fences or quotes
+func cleanCommitMessage(response string) string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSpace(response), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
+		}
+		lines = append(lines, strings.TrimRight(line, " \t\r"))
+	}
+
+	message := strings.TrimSpace(strings.Join(lines, "\n"))
+	message = strings.Trim(message, "\"'`")
+	return strings.TrimSpace(message)
+}
+
+// showDiff returns the colored diff of the uncommitted changes of the repository
+func (s Shell) showDiff() string {
+	if s.Git == nil {
+		return fmt.Sprintf("[red]%s[-]\n", git.ErrNotARepository.Error())
+	}
+
+	files, err := s.Git.ChangedFiles(wingmanFiles...)
+	if err != nil {
+		return fmt.Sprintf("[red]%s[-]\n", tview.Escape(err.Error()))
+	}
+	if len(files) == 0 {
+		return "No changes\n"
+	}
+
+	diff, err := s.Git.Diff(files...)
+	if err != nil {
+		return fmt.Sprintf("[red]%s[-]\n", tview.Escape(err.Error()))
+	}
+	if diff == "" {
+		// Only untracked files, which git diff does not show
+		return fmt.Sprintf("Untracked files:\n%s\n", tview.Escape(strings.Join(files, "\n")))
+	}
+	return colorDiff(diff)
+}
+
+/*
+commitWorkTree commits every uncommitted change except the files wingman
+writes itself, with message or a generated one.
+*/
+func (s Shell) commitWorkTree(message string) string {
+	if s.Git == nil {
+		return fmt.Sprintf("[red]%s[-]\n", git.ErrNotARepository.Error())
+	}
+
+	files, err := s.Git.ChangedFiles(wingmanFiles...)
+	if err != nil {
+		return fmt.Sprintf("[red]%s[-]\n", tview.Escape(err.Error()))
+	}
+	if len(files) == 0 {
+		return "Nothing to commit\n"
+	}
+
+	hash, message, err := s.commitPaths(files, strings.TrimSpace(message))
+	if err != nil {
+		return fmt.Sprintf("[red]Could not commit: %s[-]\n", tview.Escape(err.Error()))
+	}
+	return fmt.Sprintf("Committed %s %s\n", hash, tview.Escape(commitSubject(message)))
+}
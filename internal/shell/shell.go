@@ -3,44 +3,122 @@ package shell
 import (
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
+	"github.com/manosriram/wingman/internal/algorithm"
+	"github.com/manosriram/wingman/internal/edit"
+	"github.com/manosriram/wingman/internal/git"
 	"github.com/manosriram/wingman/internal/llm"
 	"github.com/manosriram/wingman/internal/repository"
+	"github.com/manosriram/wingman/internal/types"
 	"github.com/rivo/tview"
 )
 
 type ProgramFlags struct {
-	Model *string
+	Model            *string
+	RepoMapFraction  *float64
+	TokenCounter     *string
+	AutoCommit       *bool
+	AllowDirty       *bool
+	ContextAlgorithm *string
+	WatchInterval    *time.Duration
 }
 
 type Shell struct {
-	ShellDir   string
-	Flags      ProgramFlags
-	Repository *repository.Repository
-	LLM        llm.LLM
+	ShellDir     string
+	Flags        ProgramFlags
+	Repository   *repository.Repository
+	LLM          llm.LLM
+	Conversation *llm.Conversation
+	App          *tview.Application
+	Status       *tview.TextView
+	Pending      *PendingChanges
+	EditHistory  *edit.History
+	Git          *git.Repo   // nil when ShellDir is not in a git repository
+	RepoLock     *sync.Mutex // Guards Repository from the watcher refreshing it and from inputs handled at the same time
 }
 
 func NewShell(targetDir string) (Shell, error) {
 	modelPtr := flag.String("model", "claude-opus-4-5-20251101", "Model of the LLM")
+	repoMapFractionPtr := flag.Float64("repo-map-fraction", 0.5, "Fraction of the model's context window the prompt may use")
+	tokenCounterPtr := flag.String("token-counter", string(llm.APPROXIMATE_TOKEN_COUNTER), "Token counter used for prompt budgets (approximate, anthropic)")
+	autoCommitPtr := flag.Bool("auto-commit", true, "Commit every applied edit with a generated message")
+	allowDirtyPtr := flag.Bool("allow-dirty", false, "Allow auto commits over uncommitted changes")
+	contextAlgorithmPtr := flag.String("context-algorithm", string(types.DEFAULT_CONTEXT_ALGORITHM), "Algorithm ranking the repo map (pagerank, hits, indegree, recency)")
+	watchIntervalPtr := flag.Duration("watch-interval", repository.DEFAULT_WATCH_INTERVAL, "Time between checks for changed files, 0 disables watching")
 	flag.Parse()
-	llm, err := llm.NewLLM(*modelPtr)
+
+	if *repoMapFractionPtr <= 0 || *repoMapFractionPtr > 1 {
+		return Shell{}, fmt.Errorf("repo-map-fraction must be in (0, 1], got %v", *repoMapFractionPtr)
+	}
+	selectedLLM, err := llm.NewLLM(*modelPtr)
+	if err != nil {
+		return Shell{}, err
+	}
+	if err := setTokenCounter(selectedLLM, llm.TokenCounterType(*tokenCounterPtr)); err != nil {
+		return Shell{}, err
+	}
+
+	repo, err := openGitRepo(targetDir, *autoCommitPtr, *allowDirtyPtr)
 	if err != nil {
 		return Shell{}, err
 	}
+	if err := checkContextAlgorithm(types.ContextAlgorithmType(*contextAlgorithmPtr), repo); err != nil {
+		return Shell{}, err
+	}
 
 	return Shell{
 		Flags: ProgramFlags{
-			Model: modelPtr,
+			Model:            modelPtr,
+			RepoMapFraction:  repoMapFractionPtr,
+			TokenCounter:     tokenCounterPtr,
+			AutoCommit:       autoCommitPtr,
+			AllowDirty:       allowDirtyPtr,
+			ContextAlgorithm: contextAlgorithmPtr,
+			WatchInterval:    watchIntervalPtr,
 		},
-		ShellDir: targetDir,
-		LLM:      llm,
+		ShellDir:     targetDir,
+		LLM:          selectedLLM,
+		Conversation: llm.NewConversation(),
+		Pending:      &PendingChanges{},
+		EditHistory:  edit.NewHistory(),
+		Git:          repo,
+		RepoLock:     &sync.Mutex{},
 	}, nil
 }
 
+// The approximation works for every provider, count_tokens is only offered by Anthropic
+func setTokenCounter(selectedLLM llm.LLM, counter llm.TokenCounterType) error {
+	if counter == llm.APPROXIMATE_TOKEN_COUNTER {
+		return nil
+	}
+
+	claude, ok := selectedLLM.(*llm.ClaudeLLM)
+	if !ok {
+		return fmt.Errorf("token counter %s is not supported for model %s", counter, selectedLLM.GetSelectedModel())
+	}
+	return claude.UseTokenCounter(counter)
+}
+
+// The recency algorithm ranks files by their git history
+func checkContextAlgorithm(algorithmType types.ContextAlgorithmType, repo *git.Repo) error {
+	if !slices.Contains(algorithm.ContextAlgorithmTypes, algorithmType) {
+		return fmt.Errorf("unknown context-algorithm %s, want one of %v", algorithmType, algorithm.ContextAlgorithmTypes)
+	}
+	if algorithmType == types.RECENCY_CONTEXT_ALGORITHM && repo == nil {
+		return fmt.Errorf("context-algorithm %s needs a git repository", algorithmType)
+	}
+	return nil
+}
+
 type CmdChannel struct {
 	Response string
 	Error    error
@@ -48,9 +126,13 @@ type CmdChannel struct {
 
 func (s Shell) Run() {
 	app := tview.NewApplication()
+	s.App = app
 
 	// targetDir := "/Users/manosriram/go/src/nimbusdb/"
 	r := repository.NewRepository(s.ShellDir)
+	if s.Flags.ContextAlgorithm != nil {
+		r.ContextAlgorithm = types.ContextAlgorithmType(*s.Flags.ContextAlgorithm)
+	}
 	err := r.Run()
 	if err != nil {
 		log.Fatalf("Error initializing program: %s\n", err.Error())
@@ -61,6 +143,15 @@ func (s Shell) Run() {
 		SetDynamicColors(true).
 		SetScrollable(true)
 
+	status := tview.NewTextView().
+		SetDynamicColors(true)
+	s.Status = status
+	fmt.Fprint(status, s.getStatusLine(0))
+
+	if watcher := s.startWatcher(output); watcher != nil {
+		defer watcher.Stop()
+	}
+
 	input := tview.NewInputField().
 		SetLabel("$ ")
 	input.SetFieldBackgroundColor(tcell.ColorBlack)
@@ -117,6 +208,7 @@ func (s Shell) Run() {
 	flex := tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(output, 0, 1, false).
+		AddItem(status, 1, 0, false).
 		AddItem(input, 1, 0, true)
 
 	app.SetRoot(flex, true).SetFocus(input).EnableMouse(true).Run()
@@ -127,6 +219,12 @@ func (s Shell) handleCommand(line string, ch chan<- CmdChannel, output *tview.Te
 	cmd := parts[0]
 	args := parts[1:]
 
+	// The line answers the confirmation of the previewed edits
+	if s.Pending != nil && s.Pending.Has() {
+		ch <- CmdChannel{Response: s.confirmChanges(line)}
+		return
+	}
+
 	switch cmd {
 	case "echo":
 		fmt.Fprintf(output, "%s", args[0])
@@ -138,7 +236,9 @@ func (s Shell) handleCommand(line string, ch chan<- CmdChannel, output *tview.Te
 		os.Exit(0)
 	case "/add":
 		paths := args
+		unlock := s.lockRepository()
 		err := s.Repository.AddFiles(paths)
+		unlock()
 		if err != nil {
 			fmt.Fprintf(output, "%s", "Error adding file(s): "+err.Error()+"\n")
 		} else {
@@ -146,19 +246,46 @@ func (s Shell) handleCommand(line string, ch chan<- CmdChannel, output *tview.Te
 		}
 	case "/drop":
 		paths := args
+		unlock := s.lockRepository()
 		s.Repository.DropFiles(paths)
+		unlock()
 		fmt.Fprintf(output, "%s", "Dropped file(s)\n")
+	case "/reset":
+		s.Conversation.Reset()
+		fmt.Fprintf(output, "%s", "Conversation reset\n")
+	case "/undo":
+		fmt.Fprintf(output, "%s", s.undoChanges())
+	case "/diff":
+		fmt.Fprintf(output, "%s", s.showDiff())
+	case "/commit":
+		fmt.Fprintf(output, "%s", s.commitWorkTree(strings.Join(args, " ")))
 	default:
 		cmdCh := CmdChannel{}
 
 		input := strings.Join(parts, " ")
-		prompt := s.Repository.CreateMasterPrompt(input)
-		response, err := s.LLM.Call(prompt)
+		system, history, promptTokens, err := s.buildPrompt(input, output)
 		if err != nil {
 			cmdCh.Error = err
-		} else {
-			cmdCh.Response = response.Response
 			ch <- cmdCh
+			return
+		}
+		s.updateStatus(promptTokens)
+
+		messages := append(history, types.Message{Role: "user", Content: input})
+		response, streamed, err := s.callLLM(system, messages, output)
+		if err != nil {
+			cmdCh.Error = err
+			ch <- cmdCh
+			return
+		}
+		s.Conversation.AddExchange(input, response.Response)
+
+		// A streamed answer is already on screen
+		if !streamed {
+			cmdCh.Response = response.Response
+		}
+		if preview := s.previewEdits(response.Response); preview != "" {
+			cmdCh.Response += "\n" + preview
 		}
 
 		err = s.LLM.WriteToHistory(input, response)
@@ -168,3 +295,193 @@ func (s Shell) handleCommand(line string, ch chan<- CmdChannel, output *tview.Te
 		ch <- cmdCh
 	}
 }
+
+/*
+buildPrompt ranks the repository for the input and assembles the system
+prompt within the budget. The repo map is filled with the local estimate,
+which costs no requests, and the finished prompt is counted with the
+selected counter after the repository lock is released. If that count is
+over the budget, the map is built again with less room once, after that the
+oldest exchanges of the conversation are dropped until the prompt fits.
+The previous turns to send along with the input are returned.
+*/
+func (s Shell) buildPrompt(input string, output io.Writer) (string, []types.Message, int, error) {
+	budget := s.getPromptTokenBudget()
+	history := s.Conversation.Messages()
+	maxTokens := budget
+
+	var system string
+	var repoMap repository.RepoMap
+	var promptTokens int
+	dropped := 0
+	for attempt := 0; ; attempt++ {
+		unlock := s.lockRepository()
+		if err := s.Repository.RankForInput(input); err != nil {
+			unlock()
+			return "", nil, 0, err
+		}
+		repoMap = s.Repository.BuildRepoMap(repository.RepoMapOptions{
+			MaxTokens:   maxTokens,
+			Input:       input,
+			History:     history[dropped:],
+			CountTokens: llm.EstimateTokenCount,
+		})
+		system = s.Repository.CreateSystemPrompt(repoMap)
+		unlock()
+
+		promptTokens = s.LLM.CountTokens(promptText(system, history[dropped:], input))
+		excess := promptTokens - budget
+		if budget <= 0 || excess <= 0 {
+			break
+		}
+		if attempt == 0 && maxTokens-excess > 0 {
+			maxTokens -= excess
+			continue
+		}
+		if len(history)-dropped < 2 {
+			// Nothing left to drop, the input alone is over the budget
+			break
+		}
+
+		// Drop exchanges until the estimate of what they held covers the excess, then count again
+		for freed := 0; freed < excess && len(history)-dropped >= 2; dropped += 2 {
+			freed += llm.EstimateTokenCount(history[dropped].Content + "\n" + history[dropped+1].Content + "\n")
+		}
+	}
+
+	if dropped > 0 {
+		s.Conversation.DropOldest(history[:dropped])
+		fmt.Fprintf(output, "[yellow]Dropped the %d oldest turns of the conversation to fit the prompt budget (%d tokens)[-]\n", dropped, budget)
+	}
+	if repoMap.IsTruncated() {
+		fmt.Fprintf(output, "[yellow]Repo map truncated to %d of %d files, %d symbols left out (%d tokens reserved, %d budget)[-]\n",
+			len(repoMap.Entries), len(repoMap.Entries)+len(repoMap.TruncatedFiles), repoMap.TruncatedSymbols, repoMap.ReservedTokens, repoMap.TokenBudget)
+	}
+	return system, history[dropped:], promptTokens, nil
+}
+
+// The system prompt, the previous turns and the question as one text, counted in one go
+func promptText(system string, history []types.Message, input string) string {
+	var text strings.Builder
+	text.WriteString(system + "\n")
+	for _, m := range history {
+		text.WriteString(m.Content + "\n")
+	}
+	text.WriteString(input)
+	return text.String()
+}
+
+// Tokens the prompt may use, a fraction of the selected model's context window
+func (s Shell) getPromptTokenBudget() int {
+	fraction := 1.0
+	if s.Flags.RepoMapFraction != nil {
+		fraction = *s.Flags.RepoMapFraction
+	}
+	return int(float64(s.LLM.GetMaxTokenCount(s.LLM.GetSelectedModel())) * fraction)
+}
+
+// Model, prompt tokens, prompt budget and context window of the selected model
+func (s Shell) getStatusLine(promptTokens int) string {
+	model := s.LLM.GetSelectedModel()
+	return fmt.Sprintf("[gray]%s | prompt %d / %d tokens | context %d[-]",
+		model, promptTokens, s.getPromptTokenBudget(), s.LLM.GetMaxTokenCount(model))
+}
+
+func (s Shell) updateStatus(promptTokens int) {
+	if s.Status == nil || s.App == nil {
+		return
+	}
+
+	line := s.getStatusLine(promptTokens)
+	s.App.QueueUpdateDraw(func() {
+		s.Status.SetText(line)
+	})
+}
+
+/*
+callLLM streams the answer into the output view when the selected model
+supports it, and falls back to a blocking call otherwise.
+*/
+func (s Shell) callLLM(system string, messages []types.Message, output *tview.TextView) (*llm.LLMResponse, bool, error) {
+	streamer, ok := s.LLM.(llm.StreamingLLM)
+	if !ok || s.App == nil {
+		response, err := s.LLM.Call(system, messages)
+		return response, false, err
+	}
+
+	response, err := streamer.CallStream(system, messages, func(token string) {
+		s.App.QueueUpdateDraw(func() {
+			fmt.Fprintf(output, "%s", token)
+			output.ScrollToEnd()
+		})
+	})
+	return response, true, err
+}
+
+// lockRepository keeps the watcher from refreshing the repository until the returned func is called
+func (s Shell) lockRepository() (unlock func()) {
+	if s.RepoLock == nil {
+		return func() {}
+	}
+	s.RepoLock.Lock()
+	return s.RepoLock.Unlock
+}
+
+/*
+startWatcher refreshes the repository as files change under the shell and
+tells about it in output. It returns nil when watching is disabled.
+*/
+func (s Shell) startWatcher(output *tview.TextView) *repository.Watcher {
+	if s.Flags.WatchInterval == nil || *s.Flags.WatchInterval <= 0 {
+		return nil
+	}
+
+	watcher := repository.NewWatcher(s.Repository, *s.Flags.WatchInterval, s.RepoLock)
+	notify := func(notice string) {
+		s.App.QueueUpdateDraw(func() {
+			fmt.Fprint(output, notice)
+			output.ScrollToEnd()
+		})
+	}
+	watcher.OnRefresh = func(result repository.RefreshResult) {
+		if notice := s.refreshNotice(result); notice != "" {
+			notify(notice)
+		}
+	}
+	watcher.OnError = func(err error) {
+		notify(fmt.Sprintf("[red]Could not refresh the repository: %s[-]\n", tview.Escape(err.Error())))
+	}
+
+	if err := watcher.Start(); err != nil {
+		fmt.Fprintf(output, "[red]Not watching for changed files: %s[-]\n", tview.Escape(err.Error()))
+		return nil
+	}
+	return watcher
+}
+
+// Short notice of a refresh, empty when neither the repo map nor the added files changed
+func (s Shell) refreshNotice(result repository.RefreshResult) string {
+	var notice strings.Builder
+	if result.MapChanged {
+		fmt.Fprintf(&notice, "[yellow]Repo map updated, %d file(s) changed[-]\n", len(result.ChangedFiles))
+	}
+	if len(result.ReloadedFiles) > 0 {
+		fmt.Fprintf(&notice, "[yellow]Reloaded %s[-]\n", s.displayPaths(result.ReloadedFiles))
+	}
+	if len(result.DroppedFiles) > 0 {
+		fmt.Fprintf(&notice, "[yellow]Dropped removed %s[-]\n", s.displayPaths(result.DroppedFiles))
+	}
+	return notice.String()
+}
+
+// Paths relative to ShellDir when under it, joined for a notice
+func (s Shell) displayPaths(paths []string) string {
+	display := make([]string, len(paths))
+	for i, path := range paths {
+		display[i] = path
+		if rel, err := filepath.Rel(s.ShellDir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			display[i] = rel
+		}
+	}
+	return tview.Escape(strings.Join(display, ", "))
+}
@@ -1,10 +1,19 @@
 package shell
 
 import (
+	"fmt"
+	"io"
 	"os"
+	"os/exec"
+	"strings"
+	"sync"
 	"testing"
 
+	"github.com/manosriram/wingman/internal/edit"
 	"github.com/manosriram/wingman/internal/llm"
+	"github.com/manosriram/wingman/internal/repository"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/rivo/tview"
 )
 
 func TestProgramFlags_Struct(t *testing.T) {
@@ -83,6 +92,10 @@ type MockLLM struct {
 	MaxTokenCount   int64
 	InputTokenCount int
 	WriteHistoryErr error
+	CountedTexts    []string
+	CountFunc       func(text string) int // Counts the texts when set, InputTokenCount is returned otherwise
+	CallFunc        func(messages []types.Message) (*llm.LLMResponse, error)
+	mu              sync.Mutex
 }
 
 func (m *MockLLM) GetMaxTokenCount(model string) int64 {
@@ -97,13 +110,30 @@ func (m *MockLLM) GetInputTokenCount() int {
 	return m.InputTokenCount
 }
 
-func (m *MockLLM) Call(prompt string) (*llm.LLMResponse, error) {
+func (m *MockLLM) CountTokens(text string) int {
+	m.mu.Lock()
+	m.CountedTexts = append(m.CountedTexts, text)
+	m.mu.Unlock()
+	if m.CountFunc != nil {
+		return m.CountFunc(text)
+	}
+	return m.InputTokenCount
+}
+
+func (m *MockLLM) Call(system string, messages []types.Message) (*llm.LLMResponse, error) {
+	if m.CallFunc != nil {
+		return m.CallFunc(messages)
+	}
 	if m.CallError != nil {
 		return nil, m.CallError
 	}
 	return &llm.LLMResponse{Response: m.CallResponse}, nil
 }
 
+func (m *MockLLM) Prompt(system string, messages []types.Message) (*llm.LLMResponse, error) {
+	return m.Call(system, messages)
+}
+
 func (m *MockLLM) WriteToHistory(request string, response *llm.LLMResponse) error {
 	return m.WriteHistoryErr
 }
@@ -148,7 +178,7 @@ func TestMockLLM_Call(t *testing.T) {
 		SelectedModel: "test-model",
 	}
 
-	resp, err := mock.Call("test prompt")
+	resp, err := mock.Call("", []types.Message{{Role: "user", Content: "test prompt"}})
 
 	if err != nil {
 		t.Errorf("MockLLM.Call() unexpected error: %v", err)
@@ -163,7 +193,7 @@ func TestMockLLM_CallError(t *testing.T) {
 		CallError: os.ErrNotExist,
 	}
 
-	resp, err := mock.Call("test prompt")
+	resp, err := mock.Call("", []types.Message{{Role: "user", Content: "test prompt"}})
 
 	if err == nil {
 		t.Error("MockLLM.Call() expected error")
@@ -230,3 +260,445 @@ func TestMockLLM_WriteToHistoryError(t *testing.T) {
 		t.Error("MockLLM.WriteToHistory() expected error")
 	}
 }
+
+func TestShell_GetPromptTokenBudget(t *testing.T) {
+	fraction := 0.25
+	s := Shell{
+		Flags: ProgramFlags{RepoMapFraction: &fraction},
+		LLM:   &MockLLM{MaxTokenCount: 200000},
+	}
+
+	if got := s.getPromptTokenBudget(); got != 50000 {
+		t.Errorf("getPromptTokenBudget() = %d, want 50000", got)
+	}
+
+	s.Flags.RepoMapFraction = nil
+	if got := s.getPromptTokenBudget(); got != 200000 {
+		t.Errorf("getPromptTokenBudget() without fraction = %d, want 200000", got)
+	}
+}
+
+func TestSetTokenCounter(t *testing.T) {
+	claude := &llm.ClaudeLLM{SelectedModel: "claude-sonnet-4-20250514", Client: llm.NewClient("test-key")}
+
+	if err := setTokenCounter(claude, llm.APPROXIMATE_TOKEN_COUNTER); err != nil {
+		t.Errorf("setTokenCounter(approximate) unexpected error: %v", err)
+	}
+	if err := setTokenCounter(claude, llm.ANTHROPIC_TOKEN_COUNTER); err != nil {
+		t.Errorf("setTokenCounter(anthropic) unexpected error: %v", err)
+	}
+	if _, ok := claude.Estimator.(*llm.AnthropicEstimator); !ok {
+		t.Errorf("setTokenCounter(anthropic) Estimator = %T, want *llm.AnthropicEstimator", claude.Estimator)
+	}
+
+	if err := setTokenCounter(&MockLLM{SelectedModel: "gpt-4o"}, llm.ANTHROPIC_TOKEN_COUNTER); err == nil {
+		t.Error("setTokenCounter(anthropic) expected error for a non Claude model")
+	}
+	if err := setTokenCounter(claude, llm.TokenCounterType("words")); err == nil {
+		t.Error("setTokenCounter() expected error for an unknown counter")
+	}
+}
+
+func TestShell_GetStatusLine(t *testing.T) {
+	fraction := 0.5
+	s := Shell{
+		Flags: ProgramFlags{RepoMapFraction: &fraction},
+		LLM:   &MockLLM{SelectedModel: "test-model", MaxTokenCount: 8000},
+	}
+
+	want := "[gray]test-model | prompt 1200 / 4000 tokens | context 8000[-]"
+	if got := s.getStatusLine(1200); got != want {
+		t.Errorf("getStatusLine() = %q, want %q", got, want)
+	}
+}
+
+func TestShell_BuildPrompt_CountsThePromptOnce(t *testing.T) {
+	s, tmpDir := newEditShell(t)
+	if err := s.Repository.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	mock := &MockLLM{MaxTokenCount: 100000, InputTokenCount: 1200}
+	s.LLM = mock
+	s.Conversation = llm.NewConversation()
+
+	system, _, promptTokens, err := s.buildPrompt("what does main print?", io.Discard)
+	if err != nil {
+		t.Fatalf("buildPrompt() unexpected error: %v", err)
+	}
+	if !strings.Contains(system, tmpDir+"/main.go") || promptTokens != 1200 {
+		t.Errorf("buildPrompt() = %d tokens, system %q, want 1200 and main.go in the map", promptTokens, system)
+	}
+	if len(mock.CountedTexts) != 1 || !strings.HasSuffix(mock.CountedTexts[0], "what does main print?") {
+		t.Errorf("counted %q, want the whole prompt once", mock.CountedTexts)
+	}
+
+	// Over the budget the map is built once more with less room
+	mock.CountedTexts = nil
+	mock.InputTokenCount = 150000
+	if _, _, _, err := s.buildPrompt("what does main print?", io.Discard); err != nil {
+		t.Fatalf("buildPrompt() unexpected error: %v", err)
+	}
+	if len(mock.CountedTexts) != 2 {
+		t.Errorf("counted the prompt %d times over the budget, want 2", len(mock.CountedTexts))
+	}
+}
+
+func TestShell_BuildPrompt_DropsOldestTurnsOverTheContextWindow(t *testing.T) {
+	s, _ := newEditShell(t)
+	if err := s.Repository.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	mock := &MockLLM{MaxTokenCount: 2000, CountFunc: llm.EstimateTokenCount}
+	s.LLM = mock
+	s.Conversation = llm.NewConversation()
+
+	// Four exchanges of about 1000 tokens each, twice the context window
+	for i := 0; i < 4; i++ {
+		s.Conversation.AddExchange(fmt.Sprintf("question %d %s", i, strings.Repeat("why ", 500)), strings.Repeat("because ", 500))
+	}
+
+	var output strings.Builder
+	_, history, promptTokens, err := s.buildPrompt("and now?", &output)
+	if err != nil {
+		t.Fatalf("buildPrompt() unexpected error: %v", err)
+	}
+	if promptTokens > 2000 {
+		t.Errorf("buildPrompt() = %d tokens, want the prompt within the 2000 token window", promptTokens)
+	}
+	if len(history) == 0 || len(history)%2 != 0 || !strings.HasPrefix(history[len(history)-2].Content, "question 3") {
+		t.Fatalf("history = %d turns, want the latest exchanges kept in pairs", len(history))
+	}
+	if s.Conversation.Len() != len(history) {
+		t.Errorf("conversation holds %d turns, want the %d sent ones", s.Conversation.Len(), len(history))
+	}
+	if !strings.Contains(output.String(), fmt.Sprintf("Dropped the %d oldest turns", 8-len(history))) {
+		t.Errorf("output = %q, want the dropped turns reported", output.String())
+	}
+}
+
+func TestShell_HandleCommand_FailedQuestionLeavesOthersInHistory(t *testing.T) {
+	s, _ := newEditShell(t)
+	if err := s.Repository.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	s.Conversation = llm.NewConversation()
+	s.RepoLock = &sync.Mutex{}
+
+	// The second question is asked while the first one is in flight, which fails once the second was answered
+	started, answered := make(chan struct{}), make(chan struct{})
+	s.LLM = &MockLLM{MaxTokenCount: 100000, CallFunc: func(messages []types.Message) (*llm.LLMResponse, error) {
+		if messages[len(messages)-1].Content == "first" {
+			close(started)
+			<-answered
+			return nil, os.ErrDeadlineExceeded
+		}
+		<-started
+		return &llm.LLMResponse{Response: "second answer"}, nil
+	}}
+
+	ch := make(chan CmdChannel, 2)
+	output := tview.NewTextView()
+	go s.handleCommand("first", ch, output)
+	s.handleCommand("second", ch, output)
+	close(answered)
+	<-ch
+	<-ch
+
+	want := []types.Message{{Role: "user", Content: "second"}, {Role: "assistant", Content: "second answer"}}
+	if got := s.Conversation.Messages(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Messages() = %+v, want only the answered exchange %+v", got, want)
+	}
+}
+
+func newEditShell(t *testing.T) (Shell, string) {
+	t.Helper()
+
+	tmpDir := setupTestDir(t)
+	t.Cleanup(func() { cleanupTestDir(t, tmpDir) })
+
+	return Shell{
+		Repository:  repository.NewRepository(tmpDir),
+		Pending:     &PendingChanges{},
+		EditHistory: edit.NewHistory(),
+	}, tmpDir
+}
+
+const testEditAnswer = "main.go\n<<<<<<< SEARCH\n\tprintln(\"hello\")\n=======\n\tprintln(\"bye\")\n>>>>>>> REPLACE\n"
+
+func TestShell_PreviewEdits(t *testing.T) {
+	s, tmpDir := newEditShell(t)
+
+	if preview := s.previewEdits("No changes needed."); preview != "" {
+		t.Errorf("previewEdits() without edits = %q, want empty", preview)
+	}
+
+	preview := s.previewEdits(testEditAnswer)
+	if !strings.Contains(preview, `[red]-	println("hello")`) || !strings.Contains(preview, `[green]+	println("bye")`) {
+		t.Errorf("previewEdits() = %q, want a colored diff", preview)
+	}
+	if !strings.Contains(preview, "(y/n)") {
+		t.Errorf("previewEdits() = %q, want a confirmation prompt", preview)
+	}
+	if !s.Pending.Has() {
+		t.Error("previewEdits() should leave the changes pending")
+	}
+
+	d, _ := os.ReadFile(tmpDir + "/main.go")
+	if strings.Contains(string(d), "bye") {
+		t.Error("previewEdits() should not write the changes")
+	}
+
+	preview = s.previewEdits("main.go\n<<<<<<< SEARCH\nfunc missing() {\n=======\nfunc found() {\n>>>>>>> REPLACE\n")
+	if !strings.Contains(preview, "No edits applied") {
+		t.Errorf("previewEdits() = %q, want the search error", preview)
+	}
+}
+
+func TestShell_ConfirmAndUndoChanges(t *testing.T) {
+	s, tmpDir := newEditShell(t)
+	if err := s.Repository.AddFile(tmpDir + "/main.go"); err != nil {
+		t.Fatalf("AddFile() unexpected error: %v", err)
+	}
+	original, _ := os.ReadFile(tmpDir + "/main.go")
+
+	s.previewEdits(testEditAnswer)
+	summary := s.confirmChanges("y")
+	if !strings.Contains(summary, "Applied edit to "+tmpDir+"/main.go") {
+		t.Errorf("confirmChanges() = %q, want applied main.go", summary)
+	}
+	if s.Pending.Has() {
+		t.Error("confirmChanges() should clear the pending changes")
+	}
+	if !strings.Contains(s.Repository.AddedFiles[tmpDir+"/main.go"], "bye") {
+		t.Error("confirmChanges() should refresh the added file")
+	}
+
+	summary = s.undoChanges()
+	if !strings.Contains(summary, "Undid changes to "+tmpDir+"/main.go") {
+		t.Errorf("undoChanges() = %q, want main.go restored", summary)
+	}
+	d, _ := os.ReadFile(tmpDir + "/main.go")
+	if string(d) != string(original) {
+		t.Errorf("main.go = %q after undo, want %q", string(d), string(original))
+	}
+	if s.Repository.AddedFiles[tmpDir+"/main.go"] != string(original) {
+		t.Error("undoChanges() should refresh the added file")
+	}
+
+	if summary := s.undoChanges(); summary != "Nothing to undo\n" {
+		t.Errorf("undoChanges() with empty history = %q", summary)
+	}
+}
+
+func TestShell_ConfirmChanges_Discard(t *testing.T) {
+	s, tmpDir := newEditShell(t)
+
+	s.previewEdits(testEditAnswer)
+	if summary := s.confirmChanges("n"); summary != "Discarded the changes\n" {
+		t.Errorf("confirmChanges(n) = %q, want discarded", summary)
+	}
+
+	d, _ := os.ReadFile(tmpDir + "/main.go")
+	if strings.Contains(string(d), "bye") {
+		t.Error("confirmChanges(n) should not write the changes")
+	}
+	if s.EditHistory.Len() != 0 {
+		t.Error("confirmChanges(n) should not record a change set")
+	}
+}
+
+func setupGitTestDir(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	tmpDir := setupTestDir(t)
+	t.Cleanup(func() { cleanupTestDir(t, tmpDir) })
+
+	for _, args := range [][]string{{"init", "-q"}, {"add", "."}, {"commit", "-q", "-m", "init"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = tmpDir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v %s", args, err, out)
+		}
+	}
+	return tmpDir
+}
+
+func gitLog(t *testing.T, dir string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", "log", "--format=%s")
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("git log: %v", err)
+	}
+	return string(out)
+}
+
+func TestOpenGitRepo(t *testing.T) {
+	tmpDir := setupGitTestDir(t)
+
+	if err := os.WriteFile(tmpDir+"/wingman.md", []byte("answer"), 0644); err != nil {
+		t.Fatalf("Failed to write wingman.md: %v", err)
+	}
+	repo, err := openGitRepo(tmpDir, true, false)
+	if err != nil || repo == nil {
+		t.Fatalf("openGitRepo() = %v, %v, want the repository when only wingman files changed", repo, err)
+	}
+
+	if err := os.WriteFile(tmpDir+"/main.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write main.go: %v", err)
+	}
+	if _, err := openGitRepo(tmpDir, true, false); err == nil || !strings.Contains(err.Error(), "-allow-dirty") {
+		t.Errorf("openGitRepo() error = %v, want a dirty tree error", err)
+	}
+	if repo, err := openGitRepo(tmpDir, true, true); err != nil || repo == nil {
+		t.Errorf("openGitRepo() with allowDirty = %v, %v, want the repository", repo, err)
+	}
+	if repo, err := openGitRepo(tmpDir, false, false); err != nil || repo == nil {
+		t.Errorf("openGitRepo() without auto commit = %v, %v, want the repository", repo, err)
+	}
+
+	if repo, err := openGitRepo(t.TempDir(), true, false); err != nil || repo != nil {
+		t.Errorf("openGitRepo() outside a repository = %v, %v, want nil", repo, err)
+	}
+}
+
+func TestShell_AutoCommitAndUndo(t *testing.T) {
+	tmpDir := setupGitTestDir(t)
+	repo, err := openGitRepo(tmpDir, true, false)
+	if err != nil {
+		t.Fatalf("openGitRepo() unexpected error: %v", err)
+	}
+
+	autoCommit := true
+	s := Shell{
+		Flags:       ProgramFlags{AutoCommit: &autoCommit},
+		Repository:  repository.NewRepository(tmpDir),
+		LLM:         &MockLLM{CallResponse: "```\nSay bye instead of hello\n```"},
+		Pending:     &PendingChanges{},
+		EditHistory: edit.NewHistory(),
+		Git:         repo,
+	}
+
+	s.previewEdits(testEditAnswer)
+	summary := s.confirmChanges("yes")
+	if !strings.Contains(summary, "Say bye instead of hello") {
+		t.Errorf("confirmChanges() = %q, want the commit subject", summary)
+	}
+	if log := gitLog(t, tmpDir); log != "Say bye instead of hello\ninit\n" {
+		t.Errorf("git log = %q, want the edit committed", log)
+	}
+
+	summary = s.undoChanges()
+	if !strings.Contains(summary, "Undo wingman edit") {
+		t.Errorf("undoChanges() = %q, want the undo committed", summary)
+	}
+	if log := gitLog(t, tmpDir); !strings.HasPrefix(log, "Undo wingman edit") {
+		t.Errorf("git log = %q, want the undo committed", log)
+	}
+}
+
+func TestShell_CommitWorkTree(t *testing.T) {
+	tmpDir := setupGitTestDir(t)
+	repo, _ := openGitRepo(tmpDir, false, false)
+	s := Shell{
+		LLM: &MockLLM{CallError: os.ErrNotExist},
+		Git: repo,
+	}
+
+	if summary := s.commitWorkTree(""); summary != "Nothing to commit\n" {
+		t.Errorf("commitWorkTree() = %q, want nothing to commit", summary)
+	}
+
+	if err := os.WriteFile(tmpDir+"/util.go", []byte("package main\n"), 0644); err != nil {
+		t.Fatalf("Failed to write util.go: %v", err)
+	}
+	if diff := s.showDiff(); !strings.Contains(diff, "util.go") {
+		t.Errorf("showDiff() = %q, want util.go listed", diff)
+	}
+
+	summary := s.commitWorkTree("")
+	if !strings.Contains(summary, "wingman: update util.go") {
+		t.Errorf("commitWorkTree() = %q, want the fallback message when the LLM fails", summary)
+	}
+
+	if err := os.WriteFile(tmpDir+"/util.go", []byte("package util\n"), 0644); err != nil {
+		t.Fatalf("Failed to write util.go: %v", err)
+	}
+	if diff := s.showDiff(); !strings.Contains(diff, "[green]+package util") {
+		t.Errorf("showDiff() = %q, want a colored diff", diff)
+	}
+	s.commitWorkTree("Rename the package")
+	if log := gitLog(t, tmpDir); !strings.HasPrefix(log, "Rename the package\n") {
+		t.Errorf("git log = %q, want the given message", log)
+	}
+}
+
+func TestCleanCommitMessage(t *testing.T) {
+	tests := []struct {
+		response string
+		want     string
+	}{
+		{response: "Fix typo", want: "Fix typo"},
+		{response: "\"Fix typo\"", want: "Fix typo"},
+		{response: "```\nFix typo\n\nThe name was wrong.\n```", want: "Fix typo\n\nThe name was wrong."},
+	}
+
+	for _, tt := range tests {
+		if got := cleanCommitMessage(tt.response); got != tt.want {
+			t.Errorf("cleanCommitMessage(%q) = %q, want %q", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestCheckContextAlgorithm(t *testing.T) {
+	if err := checkContextAlgorithm(types.HITS_CONTEXT_ALGORITHM, nil); err != nil {
+		t.Errorf("checkContextAlgorithm(hits) = %v, want nil", err)
+	}
+	if err := checkContextAlgorithm("katz", nil); err == nil {
+		t.Errorf("checkContextAlgorithm(katz) = nil, want an error")
+	}
+	if err := checkContextAlgorithm(types.RECENCY_CONTEXT_ALGORITHM, nil); err == nil {
+		t.Errorf("checkContextAlgorithm(recency) without git = nil, want an error")
+	}
+}
+
+func TestShell_RefreshNotice(t *testing.T) {
+	s := Shell{ShellDir: "/repo"}
+
+	tests := []struct {
+		name   string
+		result repository.RefreshResult
+		want   string
+	}{
+		{"nothing changed", repository.RefreshResult{}, ""},
+		{"map unchanged", repository.RefreshResult{ChangedFiles: []string{"/repo/main.go"}}, ""},
+		{
+			"map changed",
+			repository.RefreshResult{ChangedFiles: []string{"/repo/a.go", "/repo/b.go"}, MapChanged: true},
+			"[yellow]Repo map updated, 2 file(s) changed[-]\n",
+		},
+		{
+			"added files",
+			repository.RefreshResult{ReloadedFiles: []string{"/repo/docs/NOTES.md", "/elsewhere/x.go"}, DroppedFiles: []string{"/repo/old.go"}},
+			"[yellow]Reloaded docs/NOTES.md, /elsewhere/x.go[-]\n[yellow]Dropped removed old.go[-]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.refreshNotice(tt.result); got != tt.want {
+				t.Errorf("refreshNotice() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,237 @@
+package symbols
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/manosriram/wingman/internal/graph"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+)
+
+/*
+Index holds which identifiers every file of the repository defines and
+which it references, as returned by LangStrategy.GetNodeSymbols.
+*/
+type Index struct {
+	Definitions map[string][]types.Symbol          // File path vs the symbols it defines
+	References  map[string][]types.SymbolReference // File path vs the identifiers it uses
+}
+
+func NewIndex() *Index {
+	return &Index{
+		Definitions: make(map[string][]types.Symbol),
+		References:  make(map[string][]types.SymbolReference),
+	}
+}
+
+func (i *Index) Add(path string, symbols types.NodeSymbols) {
+	i.Definitions[path] = symbols.Definitions
+	i.References[path] = symbols.References
+}
+
+func (i *Index) Remove(path string) {
+	delete(i.Definitions, path)
+	delete(i.References, path)
+}
+
+// Every defined symbol, by file path and then in declaration order
+func (i *Index) Symbols() []types.Symbol {
+	var symbols []types.Symbol
+	for _, path := range sortedKeys(i.Definitions) {
+		symbols = append(symbols, i.Definitions[path]...)
+	}
+	return symbols
+}
+
+/*
+Enclosing returns the graph node a reference at offset of the file at path
+comes from: the innermost definition around it, or the file itself for
+references outside of any definition.
+*/
+func (i *Index) Enclosing(path string, offset uint) string {
+	node := path
+	var size uint
+	for _, d := range i.Definitions[path] {
+		if d.Start <= offset && offset < d.End && (node == path || d.End-d.Start < size) {
+			node = d.ID()
+			size = d.End - d.Start
+		}
+	}
+	return node
+}
+
+/*
+Files whose definitions the file at path can refer to: itself, the files it
+imports and the files of its directory in the same language, which for Go
+and Java make up the same package. byDir holds the indexed files by
+directory.
+*/
+func visibleFiles(path string, imports []types.NodeImport, byDir map[string][]string) map[string]bool {
+	visible := map[string]bool{path: true}
+	for _, imp := range imports {
+		visible[imp.ImportPackage] = true
+	}
+
+	language := utils.GetLanguage(path)
+	for _, other := range byDir[filepath.Dir(path)] {
+		if utils.GetLanguage(other) == language {
+			visible[other] = true
+		}
+	}
+	return visible
+}
+
+/*
+resolve calls link for every reference of the index with each visible
+definition of the name it uses, src being the graph node the reference
+comes from, see Enclosing. A name with several visible definitions is
+ambiguous, the weight 1 of the reference is split between them. Imports are
+given by file path like Repository.NodeImports. References of a definition
+to itself are left out. Only the references of the files in sources are
+resolved, the ones of every file when it is nil.
+*/
+func (i *Index) resolve(imports map[string][]types.NodeImport, sources map[string]bool, link func(src string, srcPath string, target types.Symbol, weight float64)) {
+	byName := make(map[string][]types.Symbol)
+	for _, s := range i.Symbols() {
+		byName[s.Name] = append(byName[s.Name], s)
+	}
+
+	byDir := make(map[string][]string)
+	for path := range i.Definitions {
+		byDir[filepath.Dir(path)] = append(byDir[filepath.Dir(path)], path)
+	}
+
+	for _, path := range sortedKeys(i.References) {
+		if sources != nil && !sources[path] {
+			continue
+		}
+		visible := visibleFiles(path, imports[path], byDir)
+		for _, ref := range i.References[path] {
+			var targets []types.Symbol
+			for _, target := range byName[ref.Name] {
+				if visible[target.FilePath] {
+					targets = append(targets, target)
+				}
+			}
+			if len(targets) == 0 {
+				continue
+			}
+
+			src := i.Enclosing(path, ref.Offset)
+			for _, target := range targets {
+				if target.ID() != src {
+					link(src, path, target, 1/float64(len(targets)))
+				}
+			}
+		}
+	}
+}
+
+/*
+BuildGraph returns the symbol graph of the index. Every symbol is a node,
+keyed by Symbol.ID, and so is every file for the references made outside of
+a definition. A reference adds to the weight of the edge from the definition
+it is made in to the definitions it may use, so edges are weighted by the
+number of references.
+*/
+func (i *Index) BuildGraph(imports map[string][]types.NodeImport) *graph.Graph {
+	g := graph.NewGraph()
+	for _, s := range i.Symbols() {
+		g.AddNode(s.ID())
+	}
+
+	i.resolve(imports, nil, func(src string, srcPath string, target types.Symbol, weight float64) {
+		g.AddWeightedEdge(src, target.ID(), weight)
+	})
+	return g
+}
+
+/*
+Dependents returns the files whose references may resolve to a definition
+of one of paths, see visibleFiles: the files themselves, the files importing
+them and the files of their directories in the same language.
+*/
+func (i *Index) Dependents(paths []string, imports map[string][]types.NodeImport) map[string]bool {
+	dependents := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range paths {
+		dependents[path] = true
+		dirs[filepath.Dir(path)+"\x00"+string(utils.GetLanguage(path))] = true
+	}
+
+	for path, fileImports := range imports {
+		for _, imp := range fileImports {
+			if dependents[imp.ImportPackage] {
+				dependents[path] = true
+			}
+		}
+	}
+	for path := range i.References {
+		if dirs[filepath.Dir(path)+"\x00"+string(utils.GetLanguage(path))] {
+			dependents[path] = true
+		}
+	}
+	return dependents
+}
+
+/*
+PatchGraph updates g, a graph BuildGraph returned, once the symbols of the
+changed files were replaced in the index. previous holds the definitions
+those files had before, dependents the files returned by Dependents for
+them. Only the references of the dependents are resolved again, the graph
+ends up as BuildGraph would build it.
+*/
+func (i *Index) PatchGraph(g *graph.Graph, imports map[string][]types.NodeImport, previous map[string][]types.Symbol, dependents map[string]bool) {
+	var removed []string
+	for path := range dependents {
+		// A file is a node only for the references made outside of its definitions
+		removed = append(removed, path)
+		for _, d := range i.Definitions[path] {
+			g.RemoveOutEdges(d.ID())
+		}
+	}
+	for _, definitions := range previous {
+		for _, d := range definitions {
+			removed = append(removed, d.ID())
+		}
+	}
+	g.RemoveNodes(removed...)
+
+	for _, path := range sortedKeys(previous) {
+		for _, d := range i.Definitions[path] {
+			g.AddNode(d.ID())
+		}
+	}
+	i.resolve(imports, dependents, func(src string, srcPath string, target types.Symbol, weight float64) {
+		g.AddWeightedEdge(src, target.ID(), weight)
+	})
+}
+
+/*
+FileReferences returns, for every file, the weight of its references to the
+definitions of each other file, counted like the edges of BuildGraph. Only
+the files in sources are looked at, every file when it is nil.
+*/
+func (i *Index) FileReferences(imports map[string][]types.NodeImport, sources map[string]bool) map[string]map[string]float64 {
+	references := make(map[string]map[string]float64)
+	i.resolve(imports, sources, func(src string, srcPath string, target types.Symbol, weight float64) {
+		if target.FilePath == srcPath {
+			return
+		}
+		if references[srcPath] == nil {
+			references[srcPath] = make(map[string]float64)
+		}
+		references[srcPath][target.FilePath] += weight
+	})
+	return references
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
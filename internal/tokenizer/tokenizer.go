@@ -0,0 +1,142 @@
+package tokenizer
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+/*
+Estimator counts the tokens a model sees for a piece of text.
+
+Each LLM provider picks its own estimator, prompt assembly, budget checks and
+the shell status all go through it so the numbers agree with each other.
+*/
+type Estimator interface {
+	CountTokens(text string) int
+}
+
+/*
+ApproximateEstimator mimics how byte pair encodings (cl100k and the Claude
+tokenizer behave alike here) split text, without shipping a vocabulary:
+
+ 1. The text is pre-tokenized into runs of letters, digits, punctuation and
+    whitespace, the way the BPE pre-tokenizer regex does.
+ 2. Letter runs are split further on camelCase and snake_case boundaries, each
+    piece costs one token per ~5 characters, short words are a single token.
+ 3. Digits are grouped by three, punctuation merges in pairs and each line
+    break costs a token (indentation after it is merged in).
+ 4. Non ASCII characters are counted one token each.
+
+It errs on the high side for code, which is what budget checks want.
+*/
+type ApproximateEstimator struct{}
+
+func NewApproximateEstimator() *ApproximateEstimator {
+	return &ApproximateEstimator{}
+}
+
+type runKind int
+
+const (
+	letterRun runKind = iota
+	digitRun
+	punctRun
+	spaceRun
+	otherRun
+)
+
+func classify(r rune) runKind {
+	switch {
+	case r >= utf8.RuneSelf:
+		return otherRun
+	case unicode.IsLetter(r) || r == '_':
+		return letterRun
+	case unicode.IsDigit(r):
+		return digitRun
+	case unicode.IsSpace(r):
+		return spaceRun
+	default:
+		return punctRun
+	}
+}
+
+func (a *ApproximateEstimator) CountTokens(text string) int {
+	tokens := 0
+
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		kind := classify(runes[i])
+		j := i + 1
+		for j < len(runes) && classify(runes[j]) == kind && kind != otherRun {
+			j++
+		}
+		run := runes[i:j]
+
+		switch kind {
+		case letterRun:
+			tokens += countWordTokens(run)
+		case digitRun:
+			tokens += ceilDiv(len(run), 3)
+		case punctRun:
+			tokens += ceilDiv(len(run), 2)
+		case spaceRun:
+			tokens += countSpaceTokens(run)
+		case otherRun:
+			tokens++
+		}
+		i = j
+	}
+
+	return tokens
+}
+
+// Identifiers like parseHTTPRequest or max_token_count are split into their words
+func countWordTokens(word []rune) int {
+	tokens := 0
+	start := 0
+	for i := 1; i <= len(word); i++ {
+		boundary := i == len(word) ||
+			word[i] == '_' ||
+			word[i-1] == '_' ||
+			(unicode.IsUpper(word[i]) && unicode.IsLower(word[i-1])) ||
+			(i+1 < len(word) && unicode.IsUpper(word[i-1]) && unicode.IsUpper(word[i]) && unicode.IsLower(word[i+1]))
+		if !boundary {
+			continue
+		}
+
+		piece := word[start:i]
+		if len(piece) == 1 && piece[0] == '_' {
+			// Underscores merge into the neighbouring piece
+		} else if len(piece) <= 6 {
+			tokens++
+		} else {
+			tokens += ceilDiv(len(piece), 5)
+		}
+		start = i
+	}
+	if tokens == 0 {
+		return 1
+	}
+	return tokens
+}
+
+// A single space is merged into the next word, line breaks swallow the indentation after them
+func countSpaceTokens(space []rune) int {
+	newlines := 0
+	for _, r := range space {
+		if r == '\n' {
+			newlines++
+		}
+	}
+	if newlines > 0 {
+		return newlines
+	}
+	if len(space) == 1 {
+		return 0
+	}
+	return ceilDiv(len(space), 4)
+}
+
+func ceilDiv(a, b int) int {
+	return (a + b - 1) / b
+}
@@ -3,6 +3,11 @@ package types
 const (
 	APIBaseURL = "https://api.anthropic.com/v1/messages"
 	APIVersion = "2023-06-01"
+
+	OpenAIAPIBaseURL = "https://api.openai.com/v1"
+
+	OllamaDefaultHost   = "http://localhost:11434"
+	LlamaCppDefaultHost = "http://localhost:8080"
 )
 
 type ContentBlock struct {
@@ -28,12 +33,51 @@ type ErrorResponse struct {
 	} `json:"error"`
 }
 
+type CountTokensResponse struct {
+	InputTokens int `json:"input_tokens"`
+}
+
 type Request struct {
 	Model       string    `json:"model"`
-	MaxTokens   int       `json:"max_tokens"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
 	Messages    []Message `json:"messages"`
 	Temperature float64   `json:"temperature,omitempty"`
 	TopP        float64   `json:"top_p,omitempty"`
 	TopK        int       `json:"top_k,omitempty"`
 	System      string    `json:"system,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type OpenAIRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens,omitempty"`
+	Temperature float64   `json:"temperature,omitempty"`
+	TopP        float64   `json:"top_p,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+type OpenAIErrorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Code    any    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+type OllamaChatRequest struct {
+	Model    string         `json:"model"`
+	Messages []Message      `json:"messages"`
+	Stream   bool           `json:"stream"`
+	Options  map[string]any `json:"options,omitempty"`
+}
+
+type OllamaShowRequest struct {
+	Model string `json:"model"`
 }
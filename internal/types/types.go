@@ -1,5 +1,7 @@
 package types
 
+import "strconv"
+
 type Language string
 type ContextAlgorithmType string
 
@@ -7,7 +9,14 @@ const (
 	GOLANG     Language = "golang"
 	PYTHON     Language = "python"
 	JAVASCRIPT Language = "javascript"
+	TYPESCRIPT Language = "typescript"
+	RUST       Language = "rust"
+	JAVA       Language = "java"
+	KOTLIN     Language = "kotlin"
 	UNKNOWN    Language = "unknown"
+
+	// Parser of .tsx files, which are otherwise handled as TYPESCRIPT ones
+	TSX Language = "tsx"
 )
 
 type NodeImport struct {
@@ -15,6 +24,36 @@ type NodeImport struct {
 	FilePath      string
 }
 
+/*
+Symbol is a definition found in a node: a function, method or type.
+Signature is how the repo map shows it, Start and End delimit the source of
+the whole definition in bytes so references inside it are attributed to it.
+*/
+type Symbol struct {
+	Name      string
+	Signature string
+	FilePath  string
+	Line      int // 1-based line of the definition
+	Start     uint
+	End       uint
+}
+
+// Key of the symbol in the symbol graph, names alone are not unique
+func (s Symbol) ID() string {
+	return s.FilePath + ":" + strconv.Itoa(s.Line) + ":" + s.Name
+}
+
+// An identifier used by a node, Offset is its position in bytes
+type SymbolReference struct {
+	Name   string
+	Offset uint
+}
+
+type NodeSymbols struct {
+	Definitions []Symbol // In the order they are declared
+	References  []SymbolReference
+}
+
 // Prompting types
 const (
 	BASE_LLM_PROMPT = `
@@ -25,12 +64,32 @@ const (
 	For example:
 	/add /a/b/c.go /b/c/d.go
 
+	When you want to change code, reply with search/replace blocks. Put the path of the file on its own line, followed by the block:
+
+	/a/b/c.go
+	<<<<<<< SEARCH
+	the exact lines to replace, copied from the file including indentation
+	=======
+	the new lines
+	>>>>>>> REPLACE
+
+	Keep every SEARCH section short and unique within the file, use one block per change. To create a new file, leave the SEARCH section empty. Only edit files that were added to the chat.
+
 	`
+
+	COMMIT_MESSAGE_PROMPT = `Write a git commit message for the diff below.
+The first line is a summary in the imperative mood of at most 72 characters, optionally followed by a blank line and a short body explaining why.
+Reply with the commit message only, without quotes or code fences.
+
+`
 )
 
 // Context Algorithm types
 const (
 	PAGERANK_CONTEXT_ALGORITHM ContextAlgorithmType = "pagerank"
+	HITS_CONTEXT_ALGORITHM     ContextAlgorithmType = "hits"
+	INDEGREE_CONTEXT_ALGORITHM ContextAlgorithmType = "indegree"
+	RECENCY_CONTEXT_ALGORITHM  ContextAlgorithmType = "recency"
 
 	DEFAULT_CONTEXT_ALGORITHM ContextAlgorithmType = PAGERANK_CONTEXT_ALGORITHM
 )
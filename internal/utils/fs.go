@@ -19,8 +19,16 @@ func GetLanguage(path string) types.Language {
 		return types.GOLANG
 	case ".py":
 		return types.PYTHON
-	case ".js":
+	case ".js", ".mjs", ".cjs", ".jsx":
 		return types.JAVASCRIPT
+	case ".ts", ".tsx", ".mts", ".cts":
+		return types.TYPESCRIPT
+	case ".rs":
+		return types.RUST
+	case ".java":
+		return types.JAVA
+	case ".kt", ".kts":
+		return types.KOTLIN
 	default:
 		return types.UNKNOWN
 	}
@@ -51,7 +59,3 @@ func FindGoModPath(startFilePath string) (string, error) {
 
 	return "", errors.New("go.mod not found in any parent directory")
 }
-
-func ReadGoModFile(path string) ([]byte, error) {
-	return os.ReadFile(path)
-}
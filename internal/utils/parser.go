@@ -4,8 +4,11 @@ import (
 	"github.com/manosriram/wingman/internal/types"
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_go "github.com/tree-sitter/tree-sitter-go/bindings/go"
+	tree_sitter_java "github.com/tree-sitter/tree-sitter-java/bindings/go"
 	tree_sitter_javascript "github.com/tree-sitter/tree-sitter-javascript/bindings/go"
 	tree_sitter_python "github.com/tree-sitter/tree-sitter-python/bindings/go"
+	tree_sitter_rust "github.com/tree-sitter/tree-sitter-rust/bindings/go"
+	tree_sitter_typescript "github.com/tree-sitter/tree-sitter-typescript/bindings/go"
 )
 
 type TreeSitterParserType struct {
@@ -19,24 +22,45 @@ func NewTreeSitterParserType() TreeSitterParserType {
 	goParser := tree_sitter.NewParser()
 	pythonParser := tree_sitter.NewParser()
 	javascriptParser := tree_sitter.NewParser()
+	javaParser := tree_sitter.NewParser()
+	typescriptParser := tree_sitter.NewParser()
+	tsxParser := tree_sitter.NewParser()
+	rustParser := tree_sitter.NewParser()
 
 	golangLanguage := tree_sitter.NewLanguage(tree_sitter_go.Language())
 	javascriptLanguage := tree_sitter.NewLanguage(tree_sitter_javascript.Language())
 	pythonLanguage := tree_sitter.NewLanguage(tree_sitter_python.Language())
+	javaLanguage := tree_sitter.NewLanguage(tree_sitter_java.Language())
+	typescriptLanguage := tree_sitter.NewLanguage(tree_sitter_typescript.LanguageTypescript())
+	tsxLanguage := tree_sitter.NewLanguage(tree_sitter_typescript.LanguageTSX())
+	rustLanguage := tree_sitter.NewLanguage(tree_sitter_rust.Language())
 
 	javascriptParser.SetLanguage(javascriptLanguage)
 	goParser.SetLanguage(golangLanguage)
 	pythonParser.SetLanguage(pythonLanguage)
+	javaParser.SetLanguage(javaLanguage)
+	typescriptParser.SetLanguage(typescriptLanguage)
+	tsxParser.SetLanguage(tsxLanguage)
+	rustParser.SetLanguage(rustLanguage)
 
 	parsers[types.GOLANG] = goParser
 	parsers[types.JAVASCRIPT] = javascriptParser
 	parsers[types.PYTHON] = pythonParser
+	parsers[types.JAVA] = javaParser
+	parsers[types.TYPESCRIPT] = typescriptParser
+	parsers[types.TSX] = tsxParser
+	parsers[types.RUST] = rustParser
 
 	return TreeSitterParserType{
 		Parsers: parsers,
 	}
 }
 
+/*
+GetLanguageParser returns the parser of a language, nil for languages whose
+strategy scans the source lexically (kotlin) or that are unknown. .tsx
+files need the TSX parser, the typescript one does not parse JSX.
+*/
 func (p TreeSitterParserType) GetLanguageParser(language types.Language) *tree_sitter.Parser {
 	switch language {
 	case types.GOLANG:
@@ -45,8 +69,21 @@ func (p TreeSitterParserType) GetLanguageParser(language types.Language) *tree_s
 		return p.Parsers[types.JAVASCRIPT]
 	case types.PYTHON:
 		return p.Parsers[types.PYTHON]
+	case types.JAVA:
+		return p.Parsers[types.JAVA]
+	case types.TYPESCRIPT:
+		return p.Parsers[types.TYPESCRIPT]
+	case types.TSX:
+		return p.Parsers[types.TSX]
+	case types.RUST:
+		return p.Parsers[types.RUST]
 	}
 	return nil
 }
 
-func (p TreeSitterParserType) Close() {}
+// Close frees the parsers, they must not be used afterwards
+func (p TreeSitterParserType) Close() {
+	for _, parser := range p.Parsers {
+		parser.Close()
+	}
+}
@@ -3,9 +3,11 @@ package test
 import (
 	"math"
 	"testing"
+	"time"
 
 	"github.com/manosriram/wingman/internal/algorithm"
 	"github.com/manosriram/wingman/internal/graph"
+	"github.com/manosriram/wingman/internal/types"
 )
 
 func almostEqual(a, b, eps float64) bool {
@@ -105,3 +107,290 @@ func TestPageRankAlgorithm_DanglingNode_StillSumsToOne(t *testing.T) {
 		t.Fatalf("expected scores to sum to ~1.0, got %.10f (%#v)", total, pr.NodeScores)
 	}
 }
+
+func TestPageRankAlgorithm_Personalization_BiasesTowardWeightedNodes(t *testing.T) {
+	g := graph.NewGraph()
+
+	// Two separate pairs: A -> B and C -> D
+	g.G["A"] = []graph.GraphNode{graph.NewGraphNode("B")}
+	g.G["B"] = []graph.GraphNode{}
+	g.G["C"] = []graph.GraphNode{graph.NewGraphNode("D")}
+	g.G["D"] = []graph.GraphNode{}
+
+	uniform := algorithm.NewPageRankAlgorithm()
+	uniform.CalculateScore(g)
+	if !almostEqual(uniform.NodeScores["B"], uniform.NodeScores["D"], 1e-12) {
+		t.Fatalf("expected B and D to tie without personalization, got B=%.6f D=%.6f", uniform.NodeScores["B"], uniform.NodeScores["D"])
+	}
+
+	pr := algorithm.NewPersonalizedPageRankAlgorithm(map[string]float64{"A": 1, "missing": 5})
+	pr.CalculateScore(g)
+
+	if !almostEqual(sumScores(pr.NodeScores), 1.0, 1e-6) {
+		t.Fatalf("expected scores to sum to ~1.0, got %.10f", sumScores(pr.NodeScores))
+	}
+	if !(pr.NodeScores["B"] > pr.NodeScores["D"] && pr.NodeScores["A"] > pr.NodeScores["C"]) {
+		t.Fatalf("expected the A -> B pair to outrank C -> D, got %#v", pr.NodeScores)
+	}
+}
+
+func TestPageRankAlgorithm_Personalization_IgnoredWhenNoNodeMatches(t *testing.T) {
+	g := graph.NewGraph()
+	g.G["A"] = []graph.GraphNode{graph.NewGraphNode("B")}
+	g.G["B"] = []graph.GraphNode{}
+
+	uniform := algorithm.NewPageRankAlgorithm()
+	uniform.CalculateScore(g)
+
+	pr := algorithm.NewPersonalizedPageRankAlgorithm(map[string]float64{"missing": 1})
+	pr.CalculateScore(g)
+
+	for node, score := range uniform.NodeScores {
+		if !almostEqual(pr.NodeScores[node], score, 1e-12) {
+			t.Fatalf("expected the uniform score for %s, got %.10f want %.10f", node, pr.NodeScores[node], score)
+		}
+	}
+}
+
+/*
+Reference scores below are the exact solutions of the PageRank linear system
+(I - d*M) x = (1-d)*t, with the mass of dangling nodes redistributed along t,
+solved with rational arithmetic.
+*/
+
+func assertScores(t *testing.T, pr *algorithm.PageRankAlgorithm, want map[string]float64) {
+	t.Helper()
+
+	for node, score := range want {
+		if !almostEqual(pr.NodeScores[node], score, 1e-8) {
+			t.Errorf("score of %s = %.10f, want %.10f", node, pr.NodeScores[node], score)
+		}
+	}
+}
+
+func weightedReferenceGraph() *graph.Graph {
+	g := graph.NewGraph()
+	g.AddEdge("A", "B")
+	g.AddWeightedEdge("A", "C", 3)
+	g.AddEdge("B", "C")
+	g.AddEdge("C", "A")
+	g.AddEdge("D", "C")
+	return g
+}
+
+func TestPageRankAlgorithm_ReferenceGraphs(t *testing.T) {
+	star := graph.NewGraph()
+	for _, leaf := range []string{"L1", "L2", "L3"} {
+		star.AddEdge(leaf, "H")
+		star.AddEdge("H", leaf)
+	}
+
+	dangling := graph.NewGraph()
+	dangling.AddEdge("A", "B")
+	dangling.AddEdge("A", "C")
+	dangling.AddEdge("B", "C")
+
+	tests := []struct {
+		name string
+		g    *graph.Graph
+		want map[string]float64
+	}{
+		{"star", star, map[string]float64{"H": 0.4797297297297297, "L1": 0.17342342342342343, "L2": 0.17342342342342343, "L3": 0.17342342342342343}},
+		{"dangling", dangling, map[string]float64{"A": 0.1975796492961225, "B": 0.28155100024697455, "C": 0.520869350456903}},
+		{"weighted", weightedReferenceGraph(), map[string]float64{"A": 0.4056632810095414, "B": 0.12370344721452754, "C": 0.43313327177593103, "D": 0.0375}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pr := algorithm.NewPageRankAlgorithm()
+			pr.Tolerance = 1e-12
+			pr.MaxIterations = 1000 // The star is periodic, it converges at the rate of the damping
+			diagnostics := pr.CalculateScoreWithDiagnostics(tt.g)
+
+			if !diagnostics.Converged || diagnostics.Delta >= 1e-12 {
+				t.Fatalf("expected convergence, got %+v", diagnostics)
+			}
+			assertScores(t, pr, tt.want)
+		})
+	}
+}
+
+func TestPageRankAlgorithm_DuplicateEdgesAddWeight(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("A", "B")
+	for range 3 {
+		g.AddEdge("A", "C")
+	}
+	g.AddEdge("B", "C")
+	g.AddEdge("C", "A")
+	g.AddEdge("D", "C")
+
+	if outs := g.GetOutNodesOfNode("A"); len(outs) != 2 || outs[1].Weight != 3 {
+		t.Fatalf("expected A -> C to be one edge weighing 3, got %v", outs)
+	}
+
+	pr := algorithm.NewPageRankAlgorithm()
+	pr.Tolerance = 1e-12
+	pr.CalculateScore(g)
+
+	reference := algorithm.NewPageRankAlgorithm()
+	reference.Tolerance = 1e-12
+	reference.CalculateScore(weightedReferenceGraph())
+
+	assertScores(t, pr, reference.NodeScores)
+}
+
+func TestPageRankAlgorithm_ConfigurableDamping(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("A", "B")
+	g.AddEdge("B", "C")
+	g.AddNode("C")
+
+	pr := algorithm.NewPageRankAlgorithm()
+	pr.Damping = 0.5
+	pr.Tolerance = 1e-12
+	pr.CalculateScore(g)
+
+	assertScores(t, pr, map[string]float64{"A": 0.23529411764705882, "B": 0.35294117647058826, "C": 0.4117647058823529})
+}
+
+func TestPageRankAlgorithm_PersonalizedReferenceGraph(t *testing.T) {
+	pr := algorithm.NewPersonalizedPageRankAlgorithm(map[string]float64{"D": 1})
+	pr.Tolerance = 1e-12
+	pr.CalculateScore(weightedReferenceGraph())
+
+	assertScores(t, pr, map[string]float64{"A": 0.35580178516466604, "B": 0.07560787934749154, "C": 0.4185903354878424, "D": 0.15})
+}
+
+func TestPageRankAlgorithm_IterationCap(t *testing.T) {
+	pr := algorithm.NewPageRankAlgorithm()
+	pr.Tolerance = 1e-15
+	pr.MaxIterations = 2
+	diagnostics := pr.CalculateScoreWithDiagnostics(weightedReferenceGraph())
+
+	if diagnostics.Converged || diagnostics.Iterations != 2 || diagnostics.Delta <= 1e-15 {
+		t.Fatalf("expected to stop at the cap without converging, got %+v", diagnostics)
+	}
+	if pr.Diagnostics != diagnostics {
+		t.Fatalf("expected Diagnostics to hold the last run, got %+v", pr.Diagnostics)
+	}
+	if !almostEqual(sumScores(pr.NodeScores), 1.0, 1e-9) {
+		t.Fatalf("expected scores to sum to ~1.0 even without converging, got %.10f", sumScores(pr.NodeScores))
+	}
+}
+
+func TestPageRankAlgorithm_DefaultsConvergeWithinCap(t *testing.T) {
+	pr := algorithm.NewPageRankAlgorithm()
+	diagnostics := pr.CalculateScoreWithDiagnostics(weightedReferenceGraph())
+
+	if !diagnostics.Converged || diagnostics.Iterations >= algorithm.DEFAULT_MAX_ITERATIONS || diagnostics.Delta >= algorithm.DEFAULT_TOLERANCE {
+		t.Fatalf("expected the defaults to converge, got %+v", diagnostics)
+	}
+}
+
+func TestHITSAlgorithm_ReferenceGraph(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("A", "C")
+	g.AddEdge("B", "C")
+	g.AddEdge("B", "D")
+
+	h := algorithm.NewHITSAlgorithm()
+	h.Tolerance = 1e-12
+	h.CalculateScore(g)
+
+	if !h.Diagnostics.Converged {
+		t.Fatalf("expected convergence, got %+v", h.Diagnostics)
+	}
+
+	// The principal eigenvectors of the graph, in golden ratio proportions
+	phi := (1 + math.Sqrt(5)) / 2
+	wantAuthorities := map[string]float64{"A": 0, "B": 0, "C": 1 / phi, "D": 1 / (phi * phi)}
+	wantHubs := map[string]float64{"A": 1 / (phi * phi), "B": 1 / phi, "C": 0, "D": 0}
+	for node := range wantAuthorities {
+		if !almostEqual(h.GetScoreForNode(node), wantAuthorities[node], 1e-8) {
+			t.Errorf("authority of %s = %.10f, want %.10f", node, h.GetScoreForNode(node), wantAuthorities[node])
+		}
+		if !almostEqual(h.HubScores[node], wantHubs[node], 1e-8) {
+			t.Errorf("hub of %s = %.10f, want %.10f", node, h.HubScores[node], wantHubs[node])
+		}
+	}
+}
+
+func TestHITSAlgorithm_NoEdges_ScoresZero(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddNode("A")
+	g.AddNode("B")
+
+	h := algorithm.NewHITSAlgorithm()
+	h.CalculateScore(g)
+
+	if len(h.GetNodeScores()) != 2 || h.GetScoreForNode("A") != 0 || h.GetScoreForNode("B") != 0 {
+		t.Fatalf("expected zero authorities, got %#v", h.GetNodeScores())
+	}
+}
+
+func TestInDegreeAlgorithm_WeightedInDegree(t *testing.T) {
+	g := graph.NewGraph()
+	g.AddEdge("A", "C")
+	g.AddWeightedEdge("B", "C", 2)
+	g.AddEdge("C", "A")
+
+	in := algorithm.NewInDegreeAlgorithm()
+	in.CalculateScore(g)
+
+	assertNodeScores(t, in, map[string]float64{"A": 0.5, "B": 0, "C": 1.5})
+}
+
+func TestRecencyAlgorithm_DecaysWithAge(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	g := graph.NewGraph()
+	g.AddEdge("old.go", "new.go")
+	g.AddNode("never.go")
+
+	r := algorithm.NewRecencyAlgorithm(map[string][]time.Time{
+		"new.go":  {now, now.Add(-algorithm.DEFAULT_RECENCY_HALF_LIFE)},
+		"old.go":  {now.Add(-2 * algorithm.DEFAULT_RECENCY_HALF_LIFE)},
+		"gone.go": {now},
+	})
+	r.Now = now
+	r.CalculateScore(g)
+
+	assertNodeScores(t, r, map[string]float64{"new.go": 1.5, "old.go": 0.25, "never.go": 0})
+	if _, ok := r.GetNodeScores()["gone.go"]; ok {
+		t.Errorf("expected files outside of the graph to be left out")
+	}
+}
+
+func assertNodeScores(t *testing.T, a algorithm.ContextAlgorithm, want map[string]float64) {
+	t.Helper()
+
+	if len(a.GetNodeScores()) != len(want) {
+		t.Fatalf("%s scores = %#v, want %#v", a.GetAlgorithmType(), a.GetNodeScores(), want)
+	}
+	for node, score := range want {
+		if !almostEqual(a.GetScoreForNode(node), score, 1e-12) {
+			t.Errorf("%s score of %s = %v, want %v", a.GetAlgorithmType(), node, a.GetScoreForNode(node), score)
+		}
+	}
+}
+
+func TestGetContextAlgorithm(t *testing.T) {
+	for _, algorithmType := range algorithm.ContextAlgorithmTypes {
+		a, err := algorithm.GetContextAlgorithm(algorithm.ContextAlgorithmArgs{AlgorithmType: algorithmType})
+		if err != nil {
+			t.Fatalf("GetContextAlgorithm(%s): %v", algorithmType, err)
+		}
+		if a.GetAlgorithmType() != algorithmType {
+			t.Errorf("GetContextAlgorithm(%s) returned %s", algorithmType, a.GetAlgorithmType())
+		}
+	}
+
+	pr, err := algorithm.GetContextAlgorithm(algorithm.ContextAlgorithmArgs{Personalization: map[string]float64{"A": 1}})
+	if err != nil || pr.GetAlgorithmType() != types.DEFAULT_CONTEXT_ALGORITHM || pr.(*algorithm.PageRankAlgorithm).Personalization["A"] != 1 {
+		t.Errorf("expected a personalized PageRank by default, got %#v, %v", pr, err)
+	}
+
+	if _, err := algorithm.GetContextAlgorithm(algorithm.ContextAlgorithmArgs{AlgorithmType: "katz"}); err == nil {
+		t.Errorf("expected an error for an unknown algorithm")
+	}
+}
@@ -101,7 +101,7 @@ func main() {
 }
 
 func Test_GetNodeImportsShouldReturnNonEmpty(t *testing.T) {
-	testFilePath, cleanup := setupTestGoFile(t)
+	testFilePath, tmpDir, cleanup := setupTestGoFileWithImports(t)
 	defer cleanup()
 
 	treeSitterLanguageParser := utils.NewTreeSitterParserType()
@@ -114,15 +114,28 @@ func Test_GetNodeImportsShouldReturnNonEmpty(t *testing.T) {
 	d := graph.NewGraph()
 	d.BuildGraphFromImports(imports)
 
-	// The test file imports "fmt" and "os", so it should have outgoing edges
+	// The test file imports "testmodule/helper", so it should have an outgoing edge to helper.go
 	outNodes := d.GetOutNodesOfNode(testFilePath)
-	assert.NotEmpty(t, outNodes, "should have outgoing edges for imports")
+	assert.Equal(t, []graph.GraphNode{graph.NewGraphNode(filepath.Join(tmpDir, "helper", "helper.go"))}, outNodes, "should have outgoing edges for imports")
 
 	// The test file is not imported by anything, so no incoming edges
 	inNodes := d.GetInNodesOfNode(testFilePath)
 	assert.Empty(t, inNodes, "should have no incoming edges")
 }
 
+func Test_GetNodeImportsIgnoresStandardLibrary(t *testing.T) {
+	testFilePath, cleanup := setupTestGoFile(t)
+	defer cleanup()
+
+	treeSitterLanguageParser := utils.NewTreeSitterParserType()
+	a := ast.NewAST(testFilePath, nil, treeSitterLanguageParser)
+	imports, err := a.GetNodeImports()
+
+	assert.NoError(t, err)
+	// "fmt" and "os" are not part of the module
+	assert.Empty(t, imports)
+}
+
 func Test_GetNodeImportsEmptyFile(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "ast-test-*")
 	require.NoError(t, err)
@@ -155,7 +168,7 @@ func main() {
 }
 
 func Test_CalculateASTNodesScore(t *testing.T) {
-	testFilePath, cleanup := setupTestGoFile(t)
+	testFilePath, _, cleanup := setupTestGoFileWithImports(t)
 	defer cleanup()
 
 	treeSitterLanguageParser := utils.NewTreeSitterParserType()
@@ -176,8 +189,8 @@ func Test_CalculateASTNodesScore(t *testing.T) {
 
 	// Verify that scores were calculated
 	hasScores := false
-	for k := range a.Algorithm.NodeScores {
-		if a.Algorithm.NodeScores[k] > 0.0 {
+	for k := range a.Algorithm.GetNodeScores() {
+		if a.Algorithm.GetNodeScores()[k] > 0.0 {
 			hasScores = true
 			break
 		}
@@ -202,11 +215,11 @@ func Test_CalculateASTNodesScoreEmptyGraph(t *testing.T) {
 	assert.NoError(t, err)
 
 	// With empty graph, no scores should be calculated
-	assert.Empty(t, a.Algorithm.NodeScores)
+	assert.Empty(t, a.Algorithm.GetNodeScores())
 }
 
 func Test_GraphBuildFromImports(t *testing.T) {
-	testFilePath, cleanup := setupTestGoFile(t)
+	testFilePath, _, cleanup := setupTestGoFileWithImports(t)
 	defer cleanup()
 
 	treeSitterLanguageParser := utils.NewTreeSitterParserType()
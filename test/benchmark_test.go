@@ -0,0 +1,98 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/algorithm"
+	"github.com/manosriram/wingman/internal/repository"
+)
+
+/*
+writeSyntheticRepo lays out a Go module of packages files in packages of
+five files, every file importing the next two packages and calling into
+them, so the graph grows with the number of files like a real repository.
+*/
+func writeSyntheticRepo(b *testing.B, files int) string {
+	b.Helper()
+
+	dir := b.TempDir()
+	write := func(path, contents string) {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	write(filepath.Join(dir, "go.mod"), "module example.com/bench\n\ngo 1.22\n")
+
+	packages := max(files/5, 1)
+	for i := range files {
+		pkg := i % packages
+		next, after := (pkg+1)%packages, (pkg+2)%packages
+		write(filepath.Join(dir, fmt.Sprintf("p%d", pkg), fmt.Sprintf("f%d.go", i)), fmt.Sprintf(`package p%d
+
+import (
+	"example.com/bench/p%d"
+	"example.com/bench/p%d"
+)
+
+func F%d() {
+	p%d.F%d()
+	p%d.F%d()
+}
+`, pkg, next, after, i, next, (i+1)%files, after, (i+2)%files))
+	}
+	return dir
+}
+
+// Run by size of the repository, indexing the files one at a time and with eight workers
+func BenchmarkRepository_Run(b *testing.B) {
+	for _, files := range []int{100, 400, 1600} {
+		dir := writeSyntheticRepo(b, files)
+		for _, workers := range []int{1, 8} {
+			b.Run(fmt.Sprintf("files=%d/workers=%d", files, workers), func(b *testing.B) {
+				for range b.N {
+					r := repository.NewRepository(dir)
+					r.Workers = workers
+					if err := r.Run(); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+/*
+BenchmarkScoring compares scoring the file graph from every AST, as Run did
+when each AST owned its algorithm, with the single repository-level pass.
+*/
+func BenchmarkScoring(b *testing.B) {
+	for _, files := range []int{100, 400} {
+		dir := writeSyntheticRepo(b, files)
+		r := repository.NewRepository(dir)
+		if err := r.Run(); err != nil {
+			b.Fatal(err)
+		}
+
+		b.Run(fmt.Sprintf("per-file/files=%d", files), func(b *testing.B) {
+			for range b.N {
+				for _, a := range r.RepositoryNodesAST {
+					a.Algorithm = algorithm.NewPageRankAlgorithm()
+					if err := a.CalculateASTNodesScore(r.Graph); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("repository/files=%d", files), func(b *testing.B) {
+			for range b.N {
+				algorithm.NewPageRankAlgorithm().CalculateScore(r.Graph)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/cache"
+	"github.com/manosriram/wingman/internal/repository"
+	"github.com/manosriram/wingman/internal/types"
+)
+
+func runRepository(t *testing.T, dir string, cacheDir string) *repository.Repository {
+	t.Helper()
+
+	r := repository.NewRepository(dir)
+	r.CacheDir = cacheDir
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	return r
+}
+
+// The cache must not change what Run finds, compared to a run without it
+func assertSameIndex(t *testing.T, got, want *repository.Repository) {
+	t.Helper()
+
+	for name, values := range map[string][2]any{
+		"PkgPaths":      {got.PkgPaths, want.PkgPaths},
+		"NodeImports":   {got.NodeImports, want.NodeImports},
+		"Signatures":    {got.Signatures, want.Signatures},
+		"Symbols":       {got.Symbols, want.Symbols},
+		"RankedFiles":   {got.RankedFiles, want.RankedFiles},
+		"RankedSymbols": {got.RankedSymbols, want.RankedSymbols},
+	} {
+		if !reflect.DeepEqual(values[0], values[1]) {
+			t.Errorf("%s = %v, want %v", name, values[0], values[1])
+		}
+	}
+}
+
+func TestRepository_Run_ReparsesChangedFilesOnly(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "store", "store.go"), "package store\n\nfunc Get() string { return \"\" }\n")
+	writeFileRepo(t, filepath.Join(tmp, "store", "put.go"), "package store\n\nfunc Put() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nimport \"example.com/app/store\"\n\nfunc main() { store.Get() }\n")
+	cacheDir := filepath.Join(tmp, cache.CACHE_DIR)
+
+	if r := runRepository(t, tmp, cacheDir); r.CachedFiles != 0 {
+		t.Fatalf("CachedFiles = %d on the first run, want 0", r.CachedFiles)
+	}
+	if _, err := os.Stat(filepath.Join(cacheDir, cache.INDEX_FILE)); err != nil {
+		t.Fatalf("index cache not written: %v", err)
+	}
+	if data, err := os.ReadFile(filepath.Join(cacheDir, ".gitignore")); err != nil || string(data) != "*\n" {
+		t.Errorf(".gitignore = %q, %v, want the cache ignored", data, err)
+	}
+
+	r := runRepository(t, tmp, cacheDir)
+	if r.CachedFiles != 3 {
+		t.Errorf("CachedFiles = %d, want every file from the cache", r.CachedFiles)
+	}
+	assertSameIndex(t, r, runRepository(t, tmp, ""))
+
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nimport \"example.com/app/store\"\n\nfunc main() { store.Put() }\n")
+	r = runRepository(t, tmp, cacheDir)
+	if r.CachedFiles != 2 {
+		t.Errorf("CachedFiles = %d after editing main.go, want the two others", r.CachedFiles)
+	}
+	assertSameIndex(t, r, runRepository(t, tmp, ""))
+	if got := r.Signatures[filepath.Join(tmp, "store", "put.go")]; len(got) != 1 || got[0] != "Put()" {
+		t.Errorf("signatures of put.go = %v, want Put()", got)
+	}
+}
+
+func TestRepository_Run_InvalidatesCacheOnStructureChanges(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "util", "util.go"), "package util\n\nfunc Help() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nimport \"example.com/app/util\"\n\nfunc main() { util.Help() }\n")
+	writeFileRepo(t, filepath.Join(tmp, "app", "models.py"), "class User:\n    pass\n")
+	writeFileRepo(t, filepath.Join(tmp, "views.py"), "from app.models import User\n")
+	cacheDir := filepath.Join(tmp, cache.CACHE_DIR)
+	runRepository(t, tmp, cacheDir)
+
+	// app becomes a package, which changes the package of models.py
+	writeFileRepo(t, filepath.Join(tmp, "app", "__init__.py"), "")
+	r := runRepository(t, tmp, cacheDir)
+	assertSameIndex(t, r, runRepository(t, tmp, ""))
+
+	// Renaming the module changes the imports of main.go, though no go file changed
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/renamed\n\ngo 1.22\n")
+	r = runRepository(t, tmp, cacheDir)
+	if r.CachedFiles != 0 {
+		t.Errorf("CachedFiles = %d after go.mod changed, want none", r.CachedFiles)
+	}
+	assertSameIndex(t, r, runRepository(t, tmp, ""))
+	if imports := r.NodeImports[filepath.Join(tmp, "main.go")]; len(imports) != 0 {
+		t.Errorf("imports of main.go = %v, want none once the module is renamed", imports)
+	}
+}
+
+func TestRepository_Run_InvalidatesCacheOnModuleFilesAboveTargetDir(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmp := t.TempDir()
+	svc := filepath.Join(tmp, "svc")
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(svc, "store", "store.go"), "package store\n\nfunc Get() {}\n")
+	writeFileRepo(t, filepath.Join(svc, "main.go"), "package main\n\nimport \"example.com/app/svc/store\"\n\nfunc main() { store.Get() }\n")
+	cacheDir := filepath.Join(svc, cache.CACHE_DIR)
+	runRepository(t, svc, cacheDir)
+
+	// The module is renamed in the go.mod above the directory wingman runs in
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/shop\n\ngo 1.22\n")
+	r := runRepository(t, svc, cacheDir)
+	if r.CachedFiles != 0 {
+		t.Errorf("CachedFiles = %d after editing the parent go.mod, want 0", r.CachedFiles)
+	}
+	assertSameIndex(t, r, runRepository(t, svc, ""))
+}
+
+func TestCache_Load_DropsStaleIndexes(t *testing.T) {
+	dir := t.TempDir()
+	index := cache.NewIndex("/repo", "manifests")
+	index.Files["/repo/main.go"] = cache.Entry{
+		Hash:    cache.Hash([]byte("package main")),
+		Imports: []types.NodeImport{{ImportPackage: "/repo/util/util.go", FilePath: "/repo/main.go"}},
+	}
+	if err := index.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if got := cache.Load(dir, "/repo", "manifests"); !reflect.DeepEqual(got, index) {
+		t.Fatalf("Load = %#v, want %#v", got, index)
+	}
+	if got := cache.Load(dir, "/elsewhere", "manifests"); len(got.Files) != 0 {
+		t.Errorf("index of another root kept: %v", got.Files)
+	}
+	if got := cache.Load(dir, "/repo", "other manifests"); len(got.Files) != 0 {
+		t.Errorf("index for other manifests kept: %v", got.Files)
+	}
+
+	index.Version = "0 older extraction"
+	if err := index.Save(dir); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if got := cache.Load(dir, "/repo", "manifests"); len(got.Files) != 0 || got.Version != cache.Version() {
+		t.Errorf("index of another version kept: %#v", got)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, cache.INDEX_FILE), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := cache.Load(dir, "/repo", "manifests"); len(got.Files) != 0 {
+		t.Errorf("corrupt index read as %v", got.Files)
+	}
+}
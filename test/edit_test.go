@@ -0,0 +1,262 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/edit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testEditResponse = "Rename the function and add a helper.\n\n" +
+	"main.go\n" +
+	"```go\n" +
+	"<<<<<<< SEARCH\n" +
+	"func hello() string {\n" +
+	"=======\n" +
+	"func greet() string {\n" +
+	">>>>>>> REPLACE\n" +
+	"```\n\n" +
+	"```go\n" +
+	"<<<<<<< SEARCH\n" +
+	"\treturn \"hello\"\n" +
+	"=======\n" +
+	"\treturn \"hi\"\n" +
+	">>>>>>> REPLACE\n" +
+	"```\n\n" +
+	"util/strings.go\n" +
+	"<<<<<<< SEARCH\n" +
+	"=======\n" +
+	"package util\n" +
+	">>>>>>> REPLACE\n"
+
+func TestParseEdits(t *testing.T) {
+	edits, err := edit.ParseEdits(testEditResponse)
+	require.NoError(t, err)
+	require.Len(t, edits, 3)
+
+	assert.Equal(t, edit.Edit{Path: "main.go", Search: "func hello() string {\n", Replace: "func greet() string {\n"}, edits[0])
+	assert.Equal(t, "main.go", edits[1].Path, "a block without a path belongs to the previous file")
+	assert.Equal(t, "\treturn \"hi\"\n", edits[1].Replace)
+	assert.Equal(t, edit.Edit{Path: "util/strings.go", Search: "", Replace: "package util\n"}, edits[2])
+}
+
+func TestParseEdits_NoEdits(t *testing.T) {
+	edits, err := edit.ParseEdits("The function is called from main.")
+	require.NoError(t, err)
+	assert.Empty(t, edits)
+}
+
+func TestParseEdits_UnterminatedBlock(t *testing.T) {
+	_, err := edit.ParseEdits("main.go\n<<<<<<< SEARCH\nfoo\n=======\nbar\n")
+	assert.ErrorContains(t, err, ">>>>>>> REPLACE")
+}
+
+func TestParseEdits_MissingPath(t *testing.T) {
+	_, err := edit.ParseEdits("<<<<<<< SEARCH\nfoo\n=======\nbar\n>>>>>>> REPLACE\n")
+	assert.ErrorContains(t, err, "without a file path")
+}
+
+func setupEditDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n\nfunc hello() string {\n\treturn \"hello\"\n}\n")
+	return dir
+}
+
+func TestApplier_Apply(t *testing.T) {
+	dir := setupEditDir(t)
+	edits, err := edit.ParseEdits(testEditResponse)
+	require.NoError(t, err)
+
+	changes, err := edit.NewApplier(dir).Apply(edits)
+	require.NoError(t, err)
+	require.Len(t, changes, 2)
+
+	assert.True(t, changes[0].Existed)
+	assert.Equal(t, filepath.Join(dir, "main.go"), changes[0].Path)
+	assert.False(t, changes[1].Existed)
+
+	main, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, "package main\n\nfunc greet() string {\n\treturn \"hi\"\n}\n", string(main))
+	util, _ := os.ReadFile(filepath.Join(dir, "util", "strings.go"))
+	assert.Equal(t, "package util\n", string(util))
+}
+
+func TestApplier_Apply_IgnoresTrailingWhitespace(t *testing.T) {
+	dir := setupEditDir(t)
+
+	_, err := edit.NewApplier(dir).Apply([]edit.Edit{
+		{Path: "main.go", Search: "func hello() string {   \n", Replace: "func greet() string {\n"},
+	})
+	require.NoError(t, err)
+
+	main, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Contains(t, string(main), "func greet() string {\n\treturn")
+}
+
+func TestApplier_Apply_SearchNotFound(t *testing.T) {
+	dir := setupEditDir(t)
+
+	_, err := edit.NewApplier(dir).Apply([]edit.Edit{
+		{Path: "new.go", Search: "", Replace: "package main\n"},
+		{Path: "main.go", Search: "func missing() {\n", Replace: "func found() {\n"},
+	})
+	assert.ErrorIs(t, err, edit.ErrSearchNotFound)
+	assert.ErrorContains(t, err, "func missing()")
+
+	_, statErr := os.Stat(filepath.Join(dir, "new.go"))
+	assert.True(t, os.IsNotExist(statErr), "nothing is written when an edit fails")
+}
+
+func TestApplier_ResolvePath(t *testing.T) {
+	dir := t.TempDir()
+	applier := edit.NewApplier(dir)
+
+	path, err := applier.ResolvePath("a/b.go")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a", "b.go"), path)
+
+	path, err = applier.ResolvePath(filepath.Join(dir, "c.go"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "c.go"), path)
+
+	_, err = applier.ResolvePath("../outside.go")
+	assert.Error(t, err)
+	_, err = applier.ResolvePath("/etc/passwd")
+	assert.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	old := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	new := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\n"
+
+	diff := edit.Diff(edit.FileChange{Path: "/x.txt", OldContent: old, NewContent: new, Existed: true})
+	want := "--- a/x.txt\n+++ b/x.txt\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -8,3 +8,4 @@\n h\n i\n j\n+k\n"
+	assert.Equal(t, want, diff)
+}
+
+func TestDiff_NewFile(t *testing.T) {
+	diff := edit.Diff(edit.FileChange{Path: "/x.txt", NewContent: "a\nb\n"})
+	assert.Equal(t, "--- /dev/null\n+++ b/x.txt\n@@ -0,0 +1,2 @@\n+a\n+b\n", diff)
+}
+
+func TestDiff_LargeRewrite(t *testing.T) {
+	var old, new strings.Builder
+	old.WriteString("package main\n")
+	new.WriteString("package main\n")
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&old, "var a%d = %d\n", i, i)
+		fmt.Fprintf(&new, "var b%d = %d\n", i, i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	diff := edit.Diff(edit.FileChange{Path: "/x.go", OldContent: old.String(), NewContent: new.String(), Existed: true})
+	runtime.ReadMemStats(&after)
+
+	// An LCS table of 3001 by 3001 lines would take about 72MB
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(16<<20), "diff allocations")
+
+	// Past the size limit the whole block is removed, then added
+	assert.True(t, strings.HasPrefix(diff, "--- a/x.go\n+++ b/x.go\n@@ -1,3001 +1,3001 @@\n package main\n-var a0 = 0\n"), "diff starts with %q", diff[:min(len(diff), 80)])
+	assert.Contains(t, diff, "-var a2999 = 2999\n+var b0 = 0\n")
+	assert.True(t, strings.HasSuffix(diff, "+var b2999 = 2999\n"))
+}
+
+func TestDiff_NoChange(t *testing.T) {
+	assert.Empty(t, edit.Diff(edit.FileChange{Path: "/x.txt", OldContent: "a\n", NewContent: "a\n", Existed: true}))
+}
+
+func TestApplier_Revert(t *testing.T) {
+	dir := setupEditDir(t)
+	applier := edit.NewApplier(dir)
+	original, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+
+	changes, err := applier.Apply([]edit.Edit{
+		{Path: "main.go", Search: "\treturn \"hello\"\n", Replace: "\treturn \"hi\"\n"},
+		{Path: "extra.go", Search: "", Replace: "package main\n"},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, applier.Revert(changes))
+
+	main, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, string(original), string(main))
+	_, statErr := os.Stat(filepath.Join(dir, "extra.go"))
+	assert.True(t, os.IsNotExist(statErr), "revert removes created files")
+}
+
+func TestApplier_Revert_FileModified(t *testing.T) {
+	dir := setupEditDir(t)
+	applier := edit.NewApplier(dir)
+
+	changes, err := applier.Apply([]edit.Edit{
+		{Path: "main.go", Search: "\treturn \"hello\"\n", Replace: "\treturn \"hi\"\n"},
+	})
+	require.NoError(t, err)
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	assert.ErrorIs(t, applier.Revert(changes), edit.ErrFileModified)
+	main, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, "package main\n", string(main), "later changes are kept")
+}
+
+func TestApplier_Write_FileModifiedAfterPrepare(t *testing.T) {
+	dir := setupEditDir(t)
+	applier := edit.NewApplier(dir)
+
+	changes, err := applier.Prepare([]edit.Edit{
+		{Path: "main.go", Search: "\treturn \"hello\"\n", Replace: "\treturn \"hi\"\n"},
+	})
+	require.NoError(t, err)
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n")
+
+	assert.ErrorIs(t, applier.Write(changes), edit.ErrFileModified)
+}
+
+func TestApplier_Write_RestoresWrittenFilesOnFailure(t *testing.T) {
+	dir := setupEditDir(t)
+	applier := edit.NewApplier(dir)
+	original, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+
+	// Writing through a link into a missing directory fails after the other files were written
+	link := filepath.Join(dir, "link.go")
+	require.NoError(t, os.Symlink(filepath.Join(dir, "missing", "target.go"), link))
+
+	changes := []edit.FileChange{
+		{Path: filepath.Join(dir, "main.go"), OldContent: string(original), NewContent: "package main\n", Existed: true},
+		{Path: filepath.Join(dir, "created.go"), NewContent: "package main\n"},
+		{Path: link, NewContent: "package main\n"},
+	}
+	assert.Error(t, applier.Write(changes))
+
+	main, _ := os.ReadFile(filepath.Join(dir, "main.go"))
+	assert.Equal(t, string(original), string(main), "written files are restored")
+	_, statErr := os.Stat(filepath.Join(dir, "created.go"))
+	assert.True(t, os.IsNotExist(statErr), "created files are removed")
+}
+
+func TestHistory(t *testing.T) {
+	history := edit.NewHistory()
+
+	_, ok := history.Pop()
+	assert.False(t, ok)
+
+	history.Push(edit.ChangeSet{Changes: []edit.FileChange{{Path: "/a"}}})
+	history.Push(edit.ChangeSet{Changes: []edit.FileChange{{Path: "/b"}}})
+	assert.Equal(t, 2, history.Len())
+
+	set, ok := history.Pop()
+	require.True(t, ok)
+	assert.Equal(t, "/b", set.Changes[0].Path)
+	assert.Equal(t, 1, history.Len())
+}
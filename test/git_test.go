@@ -0,0 +1,151 @@
+package test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/manosriram/wingman/internal/git"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupGitRepo(t *testing.T) string {
+	t.Helper()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	t.Setenv("GIT_AUTHOR_NAME", "test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n")
+	runGit(t, dir, "add", "main.go")
+	runGit(t, dir, "commit", "-q", "-m", "init")
+
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+	return string(out)
+}
+
+func TestGitOpen(t *testing.T) {
+	dir := setupGitRepo(t)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sub"), 0o755))
+
+	repo, err := git.Open(filepath.Join(dir, "sub"))
+	require.NoError(t, err)
+	resolved, _ := filepath.EvalSymlinks(dir)
+	assert.Equal(t, resolved, repo.Dir)
+	assert.True(t, repo.HasCommits())
+
+	_, err = git.Open(t.TempDir())
+	assert.ErrorIs(t, err, git.ErrNotARepository)
+}
+
+func TestGitChangedFiles(t *testing.T) {
+	dir := setupGitRepo(t)
+	repo, err := git.Open(dir)
+	require.NoError(t, err)
+
+	dirty, err := repo.IsDirty()
+	require.NoError(t, err)
+	assert.False(t, dirty)
+
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {}\n")
+	writeFileRepo(t, filepath.Join(dir, "pkg", "util.go"), "package pkg\n")
+	writeFileRepo(t, filepath.Join(dir, "wingman.md"), "answer\n")
+	writeFileRepo(t, filepath.Join(dir, "pkg", "wingman.md"), "answer\n")
+
+	files, err := repo.ChangedFiles("wingman.md")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"main.go", "pkg/util.go"}, files)
+
+	runGit(t, dir, "checkout", "--", "main.go")
+	require.NoError(t, os.Remove(filepath.Join(dir, "pkg", "util.go")))
+	dirty, err = repo.IsDirty("wingman.md")
+	require.NoError(t, err)
+	assert.False(t, dirty, "only excluded files are changed")
+}
+
+func TestGitCommit(t *testing.T) {
+	dir := setupGitRepo(t)
+	repo, err := git.Open(dir)
+	require.NoError(t, err)
+
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {}\n")
+	writeFileRepo(t, filepath.Join(dir, "new.go"), "package main\n")
+	writeFileRepo(t, filepath.Join(dir, "other.go"), "package main\n")
+
+	diff, err := repo.Diff("main.go")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+func main() {}")
+
+	hash, err := repo.Commit("Add main", filepath.Join(dir, "main.go"), "new.go")
+	require.NoError(t, err)
+	assert.NotEmpty(t, hash)
+
+	log := runGit(t, dir, "log", "-1", "--name-only", "--format=%s")
+	assert.Equal(t, "Add main\n\nmain.go\nnew.go\n", log)
+
+	files, err := repo.ChangedFiles()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other.go"}, files, "paths not given stay uncommitted")
+
+	require.NoError(t, os.Remove(filepath.Join(dir, "new.go")))
+	_, err = repo.Commit("Remove new.go", "new.go")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(runGit(t, dir, "log", "-1", "--format=%s"), "Remove new.go"))
+}
+
+func TestGitStagedDiff(t *testing.T) {
+	dir := setupGitRepo(t)
+	repo, err := git.Open(dir)
+	require.NoError(t, err)
+
+	writeFileRepo(t, filepath.Join(dir, "new.go"), "package main\n")
+	require.NoError(t, repo.Stage("new.go"))
+
+	diff, err := repo.StagedDiff("new.go")
+	require.NoError(t, err)
+	assert.Contains(t, diff, "+package main")
+}
+
+func TestGitFileChangeTimes(t *testing.T) {
+	dir := setupGitRepo(t)
+	repo, err := git.Open(dir)
+	require.NoError(t, err)
+
+	t.Setenv("GIT_COMMITTER_DATE", "2024-01-02T00:00:00Z")
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n\nfunc main() {}\n")
+	writeFileRepo(t, filepath.Join(dir, "pkg", "lib.go"), "package pkg\n")
+	_, err = repo.Commit("Add lib")
+	require.NoError(t, err)
+
+	times, err := repo.FileChangeTimes(10)
+	require.NoError(t, err)
+
+	mainTimes := times[filepath.Join(repo.Dir, "main.go")]
+	require.Len(t, mainTimes, 2, "main.go was changed by both commits")
+	assert.True(t, mainTimes[0].Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)), "most recent first, got %v", mainTimes)
+	assert.Len(t, times[filepath.Join(repo.Dir, "pkg", "lib.go")], 1)
+
+	limited, err := repo.FileChangeTimes(1)
+	require.NoError(t, err)
+	assert.Len(t, limited[filepath.Join(repo.Dir, "main.go")], 1)
+}
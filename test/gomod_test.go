@@ -0,0 +1,200 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/manosriram/wingman/internal/gomod"
+	"github.com/manosriram/wingman/internal/repository"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseModFile(t *testing.T) {
+	data := []byte(`// Module of the shop services
+// Deprecated: use example.com/shop/v2
+module "example.com/shop" // quoted module paths are valid
+
+go 1.22
+
+require (
+	github.com/stretchr/testify v1.11.1
+	example.com/billing v0.3.0 // indirect
+)
+
+require example.com/fork v1.0.0
+
+replace (
+	example.com/billing => ../billing
+	example.com/fork v1.0.0 => github.com/someone/fork v1.0.1
+)
+`)
+
+	mod, err := gomod.ParseModFile("/repo/go.mod", data)
+	require.NoError(t, err)
+
+	assert.Equal(t, "example.com/shop", mod.Module)
+	assert.Equal(t, "1.22", mod.Go)
+	assert.Equal(t, []gomod.Require{
+		{Path: "github.com/stretchr/testify", Version: "v1.11.1"},
+		{Path: "example.com/billing", Version: "v0.3.0"},
+		{Path: "example.com/fork", Version: "v1.0.0"},
+	}, mod.Require)
+	assert.Equal(t, []gomod.Replace{
+		{Old: "example.com/billing", New: "../billing"},
+		{Old: "example.com/fork", OldVersion: "v1.0.0", New: "github.com/someone/fork", NewVersion: "v1.0.1"},
+	}, mod.Replace)
+	assert.True(t, mod.Replace[0].IsLocal())
+	assert.False(t, mod.Replace[1].IsLocal())
+}
+
+func TestParseModFile_Errors(t *testing.T) {
+	_, err := gomod.ParseModFile("go.mod", []byte("go 1.22\n"))
+	assert.ErrorIs(t, err, gomod.ErrNoModule)
+
+	_, err = gomod.ParseModFile("go.mod", []byte("module a\n\nrequire (\n\tb v1.0.0\n"))
+	assert.Error(t, err)
+}
+
+func TestParseWorkFile(t *testing.T) {
+	work, err := gomod.ParseWorkFile("/repo/go.work", []byte(`go 1.22
+
+use (
+	./services/api
+	./libs/shared // shared code
+)
+use /opt/tools
+
+replace example.com/shared => ./libs/shared-fork
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		filepath.FromSlash("/repo/services/api"),
+		filepath.FromSlash("/repo/libs/shared"),
+		filepath.FromSlash("/opt/tools"),
+	}, work.Use)
+	assert.Equal(t, []gomod.Replace{{Old: "example.com/shared", New: "./libs/shared-fork"}}, work.Replace)
+}
+
+// A go.work workspace with two modules, one of them replacing a dependency by a vendored fork
+func setupGoWorkspace(t *testing.T) string {
+	t.Helper()
+
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.work"), "go 1.22\n\nuse (\n\t./api\n\t./shared\n)\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", "go.mod"), "// API service\nmodule example.com/api\n\ngo 1.22\n\nrequire example.com/shared v0.0.0\n\nreplace example.com/retry => ./third_party/retry\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", "main.go"), `package main
+
+import (
+	"example.com/api/handlers"
+	"example.com/retry"
+	"example.com/shared/models"
+)
+
+func main() {}
+`)
+	writeFileRepo(t, filepath.Join(tmp, "api", "handlers", "orders.go"), "package handlers\n\nimport \"example.com/shared/models\"\n\nfunc Orders() []models.Order { return nil }\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", "third_party", "retry", "go.mod"), "module github.com/someone/retry\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", "third_party", "retry", "retry.go"), "package retry\n\nfunc Do() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "shared", "go.mod"), "module example.com/shared\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "shared", "models", "order.go"), "package models\n\ntype Order struct{}\n")
+	return tmp
+}
+
+func TestResolver_WorkspaceAndReplace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmp := setupGoWorkspace(t)
+
+	r, err := gomod.NewResolver(filepath.Join(tmp, "api", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, gomod.Module{Path: "example.com/api", Dir: filepath.Join(tmp, "api")}, r.Main)
+
+	tests := []struct {
+		importPath string
+		dir        string
+		ok         bool
+	}{
+		{"example.com/api/handlers", filepath.Join(tmp, "api", "handlers"), true},
+		{"example.com/shared/models", filepath.Join(tmp, "shared", "models"), true},
+		{"example.com/retry", filepath.Join(tmp, "api", "third_party", "retry"), true},
+		{"example.com/sharedutil", "", false},
+		{"fmt", "", false},
+	}
+	for _, tt := range tests {
+		dir, ok := r.Resolve(tt.importPath)
+		assert.Equal(t, tt.ok, ok, tt.importPath)
+		assert.Equal(t, tt.dir, dir, tt.importPath)
+	}
+
+	importPath, err := gomod.ImportPath(filepath.Join(tmp, "api", "third_party", "retry"))
+	require.NoError(t, err)
+	assert.Equal(t, "github.com/someone/retry", importPath)
+}
+
+func TestResolver_GoworkOff(t *testing.T) {
+	t.Setenv("GOWORK", "off")
+	tmp := setupGoWorkspace(t)
+
+	r, err := gomod.NewResolver(filepath.Join(tmp, "api", "main.go"))
+	require.NoError(t, err)
+
+	_, ok := r.Resolve("example.com/shared/models")
+	assert.False(t, ok, "modules of the workspace should not resolve with GOWORK=off")
+	_, ok = r.Resolve("example.com/retry")
+	assert.True(t, ok, "replace directives of go.mod still apply")
+}
+
+func TestNewResolver_SharedPerModuleUntilItsFilesChange(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmp := setupGoWorkspace(t)
+
+	r, err := gomod.NewResolver(filepath.Join(tmp, "api", "main.go"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(tmp, "api", "go.mod"),
+		filepath.Join(tmp, "go.work"),
+		filepath.Join(tmp, "shared", "go.mod"),
+	}, r.Files)
+
+	same, err := gomod.NewResolver(filepath.Join(tmp, "api", "handlers", "orders.go"))
+	require.NoError(t, err)
+	assert.Same(t, r, same, "files of a module should share its resolver")
+
+	// Dropping shared from the workspace is seen by the next file
+	writeFileRepo(t, filepath.Join(tmp, "go.work"), "go 1.22\n\nuse ./api\n")
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(tmp, "go.work"), later, later))
+	next, err := gomod.NewResolver(filepath.Join(tmp, "api", "main.go"))
+	require.NoError(t, err)
+	_, ok := next.Resolve("example.com/shared/models")
+	assert.False(t, ok, "the resolver should be rebuilt once go.work changes")
+}
+
+func TestRepository_Run_GoWorkspace(t *testing.T) {
+	t.Setenv("GOWORK", "")
+	tmp := setupGoWorkspace(t)
+
+	r := repository.NewRepository(tmp)
+	require.NoError(t, r.Run())
+
+	mainPath := filepath.Join(tmp, "api", "main.go")
+	orderPath := filepath.Join(tmp, "shared", "models", "order.go")
+	assert.ElementsMatch(t, []string{
+		filepath.Join(tmp, "api", "handlers", "orders.go"),
+		filepath.Join(tmp, "api", "third_party", "retry", "retry.go"),
+		orderPath,
+	}, importPackages(r.NodeImports[mainPath]))
+	assert.Equal(t, orderPath, r.RankedFiles[0], "the shared model is imported across modules")
+}
+
+func importPackages(imports []types.NodeImport) []string {
+	var packages []string
+	for _, imp := range imports {
+		packages = append(packages, imp.ImportPackage)
+	}
+	return packages
+}
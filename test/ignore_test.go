@@ -0,0 +1,159 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/ignore"
+	"github.com/manosriram/wingman/internal/repository"
+)
+
+func TestMatcher_GitignoreSemantics(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, ".gitignore"), `# build output
+/bin
+build/
+*.log
+!keep.log
+**/gen/*.pb.go
+docs/**/draft.md
+vendor/**
+\#literal
+trailing.txt   
+file[0-9].txt
+`)
+
+	m := ignore.NewMatcher(tmp)
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"bin", true, true},
+		{"cmd/bin", true, false}, // Anchored to the root
+		{"build", true, true},
+		{"src/build", true, true},
+		{"build", false, false}, // Directories only
+		{"debug.log", false, true},
+		{"logs/app/debug.log", false, true},
+		{"keep.log", false, false},
+		{"api/gen/user.pb.go", false, true},
+		{"gen/user.pb.go", false, true},
+		{"api/gen/user.go", false, false},
+		{"docs/draft.md", false, true},
+		{"docs/a/b/draft.md", false, true},
+		{"draft.md", false, false},
+		{"vendor/lib/lib.go", false, true},
+		{"#literal", false, true},
+		{"trailing.txt", false, true},
+		{"file7.txt", false, true},
+		{"fileA.txt", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(filepath.Join(tmp, tt.path), tt.isDir); got != tt.want {
+			t.Errorf("Ignored(%s, dir=%v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+		}
+	}
+
+	if m.Ignored(filepath.Join(filepath.Dir(tmp), "debug.log"), false) {
+		t.Errorf("a path outside of the root is ignored")
+	}
+}
+
+func TestMatcher_NestedFilesAndNegation(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, ".gitignore"), "*.gen.go\ntmp/\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", ".gitignore"), "!schema.gen.go\n/local.go\n")
+	writeFileRepo(t, filepath.Join(tmp, ".wingmanignore"), "fixtures/\n")
+	writeFileRepo(t, filepath.Join(tmp, ".git", "info", "exclude"), "secret.go\n")
+
+	m := ignore.NewMatcher(tmp)
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"types.gen.go", true},
+		{"api/types.gen.go", true},
+		{"api/schema.gen.go", false}, // The deeper file includes it again
+		{"schema.gen.go", true},
+		{"api/local.go", true},
+		{"api/v1/local.go", false}, // Anchored to api
+		{"local.go", false},
+		{"testdata/fixtures/data.go", true},
+		{"secret.go", true},
+		{"tmp/x/schema.gen.go", true}, // Nothing inside an ignored directory is included again
+	}
+	for _, tt := range tests {
+		if got := m.Ignored(filepath.Join(tmp, tt.path), false); got != tt.want {
+			t.Errorf("Ignored(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRepository_Run_AppliesIgnoreFilesAboveTargetDir(t *testing.T) {
+	tmp := t.TempDir()
+	svc := filepath.Join(tmp, "services", "api")
+	writeFileRepo(t, filepath.Join(tmp, ".git", "info", "exclude"), "secret.go\n")
+	writeFileRepo(t, filepath.Join(tmp, ".gitignore"), "*.pb.go\n")
+	writeFileRepo(t, filepath.Join(tmp, "services", ".gitignore"), "/api/tmp/\n")
+	writeFileRepo(t, filepath.Join(svc, "go.mod"), "module example.com/api\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(svc, "main.go"), "package main\n\nfunc main() {}\n")
+	writeFileRepo(t, filepath.Join(svc, "user.pb.go"), "package main\n\nfunc User() {}\n")
+	writeFileRepo(t, filepath.Join(svc, "secret.go"), "package main\n\nfunc Secret() {}\n")
+	writeFileRepo(t, filepath.Join(svc, "tmp", "scratch.go"), "package tmp\n")
+
+	if root := ignore.WorkTreeRoot(svc); root != tmp {
+		t.Errorf("WorkTreeRoot = %s, want %s", root, tmp)
+	}
+
+	r := repository.NewRepository(svc)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if _, ok := r.NodeImports[filepath.Join(svc, "main.go")]; !ok || len(r.NodeImports) != 1 {
+		t.Errorf("indexed %v, want main.go alone", r.RankedFiles)
+	}
+	if err := r.AddFile(filepath.Join(svc, "user.pb.go")); err == nil {
+		t.Errorf("AddFile of a file ignored above the target directory succeeded")
+	}
+}
+
+func TestRepository_Run_SkipsIgnoredFiles(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, ".gitignore"), "vendor/\n*.pb.go\n")
+	writeFileRepo(t, filepath.Join(tmp, ".wingmanignore"), "scripts/\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nfunc main() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", "user.pb.go"), "package api\n\nfunc User() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "api", "api.go"), "package api\n\nfunc Serve() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "vendor", "lib", "lib.go"), "package lib\n\nfunc Lib() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "scripts", "gen.py"), "def gen():\n    pass\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	want := map[string]bool{filepath.Join(tmp, "main.go"): true, filepath.Join(tmp, "api", "api.go"): true}
+	if len(r.NodeImports) != len(want) {
+		t.Errorf("indexed %d files, want %d", len(r.NodeImports), len(want))
+	}
+	for path := range r.NodeImports {
+		if !want[path] {
+			t.Errorf("%s is ignored but was indexed", path)
+		}
+	}
+	for _, s := range r.RankedSymbols {
+		if s.Name == "User" || s.Name == "Lib" || s.Name == "gen" {
+			t.Errorf("symbol %s of an ignored file ranked", s.Name)
+		}
+	}
+
+	if err := r.AddFile(filepath.Join(tmp, "vendor", "lib", "lib.go")); err == nil {
+		t.Errorf("AddFile of an ignored file succeeded")
+	}
+	if err := r.AddFile(filepath.Join(tmp, "api", "api.go")); err != nil {
+		t.Errorf("AddFile: %v", err)
+	}
+}
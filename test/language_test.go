@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"testing"
 
 	"github.com/manosriram/wingman/internal/language"
@@ -68,6 +69,11 @@ func TestGolangStrategy_GetNodeImportList_FindsInternalImports(t *testing.T) {
 	// The strategy should only return imports that start with the module name.
 	mainPath := filepath.Join(tmp, "cmd", "app", "main.go")
 	writeFile(t, mainPath, fmt.Sprintf(EXAMPLE_GO_FILE_CONTENT, modName, modName))
+	fooPath := filepath.Join(tmp, "internal", "foo", "foo.go")
+	writeFile(t, fooPath, "package foo\n")
+	writeFile(t, filepath.Join(tmp, "internal", "foo", "foo_test.go"), "package foo\n")
+	barPath := filepath.Join(tmp, "pkg", "bar", "bar.go")
+	writeFile(t, barPath, "package bar\n")
 
 	data, err := os.ReadFile(mainPath)
 	if err != nil {
@@ -88,13 +94,10 @@ func TestGolangStrategy_GetNodeImportList_FindsInternalImports(t *testing.T) {
 		t.Fatalf("expected nil error, got %v", err)
 	}
 
-	// NOTE: The current implementation extracts the second path segment after the module name:
-	// e.g. example.com/myrepo/internal/foo -> "foo"
-	//      example.com/myrepo/pkg/bar      -> "bar"
-	// This test asserts the current behavior.
+	// Without PkgPaths the package directories are read from disk, test files excluded
 	want := []types.NodeImport{
-		{ImportPackage: "bar", FilePath: mainPath},
-		{ImportPackage: "foo", FilePath: mainPath},
+		{ImportPackage: barPath, FilePath: mainPath},
+		{ImportPackage: fooPath, FilePath: mainPath},
 	}
 
 	sortImports(imps)
@@ -111,6 +114,84 @@ func TestGolangStrategy_GetNodeImportList_FindsInternalImports(t *testing.T) {
 	}
 }
 
+func TestGolangStrategy_GetNodeImportList_ResolvesImportSpecs(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "go.mod"), "module example.com/shop\n\ngo 1.22\n")
+
+	// Two packages named utils must not collide
+	writeFile(t, filepath.Join(tmp, "internal", "utils", "strings.go"), "package utils\n")
+	writeFile(t, filepath.Join(tmp, "pkg", "utils", "math.go"), "package utils\n")
+	writeFile(t, filepath.Join(tmp, "pkg", "utils", "math_test.go"), "package utils\n")
+	writeFile(t, filepath.Join(tmp, "models", "order.go"), "package models\n")
+	writeFile(t, filepath.Join(tmp, "models", "line.go"), "package models\n")
+	writeFile(t, filepath.Join(tmp, "store", "store.go"), "package store\n")
+	writeFile(t, filepath.Join(tmp, "drivers", "sqlite", "sqlite.go"), "package sqlite\n")
+	writeFile(t, filepath.Join(tmp, "dsl", "dsl.go"), "package dsl\n")
+	writeFile(t, filepath.Join(tmp, "version.go"), "package shop\n")
+
+	servicePath := filepath.Join(tmp, "service", "service.go")
+	writeFile(t, servicePath, `package service
+
+import (
+	"context"
+
+	shop "example.com/shop"
+	u "example.com/shop/pkg/utils"
+	"example.com/shop/internal/utils"
+	"example.com/shop/models"
+	. "example.com/shop/dsl"
+	_ "example.com/shop/drivers/sqlite"
+	"example.com/shop/store"
+	"example.com/other/models"
+)
+
+// models and store are only used as types
+type Service struct {
+	store.Store
+	orders []models.Order
+}
+`)
+
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := strategyPkgPaths(t, tmp, types.GOLANG, parser)
+	if _, ok := pkgPaths["example.com/shop/pkg/utils"]; !ok {
+		t.Fatalf("expected packages to be keyed by import path, got %v", pkgPaths)
+	}
+
+	data, _ := os.ReadFile(servicePath)
+	s := language.GetStrategy(language.StrategyArgs{
+		NodeData:         data,
+		NodePath:         servicePath,
+		Parser:           parser,
+		PkgPaths:         pkgPaths,
+		StrategyLanguage: types.GOLANG,
+	})
+
+	imps, err := s.GetNodeImportList()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	var want []types.NodeImport
+	for _, path := range []string{
+		"version.go",
+		"pkg/utils/math.go",
+		"internal/utils/strings.go",
+		"models/order.go",
+		"models/line.go",
+		"dsl/dsl.go",
+		"drivers/sqlite/sqlite.go",
+		"store/store.go",
+	} {
+		want = append(want, types.NodeImport{ImportPackage: filepath.Join(tmp, filepath.FromSlash(path)), FilePath: servicePath})
+	}
+	sortImports(imps)
+	sortImports(want)
+	if fmt.Sprint(imps) != fmt.Sprint(want) {
+		t.Errorf("imports of service.go = %v, want %v", imps, want)
+	}
+}
+
 func TestGetStrategy_ReturnsGolangStrategy(t *testing.T) {
 	parser := utils.NewTreeSitterParserType()
 	s := language.GetStrategy(language.StrategyArgs{
@@ -138,3 +219,696 @@ func TestGetStrategy_ReturnsDefaultStrategyForUnknown(t *testing.T) {
 		t.Fatalf("expected *language.DefaultStrategy, got %T", s)
 	}
 }
+
+func setupPythonRepo(t *testing.T) string {
+	t.Helper()
+
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "app", "__init__.py"), "")
+	writeFile(t, filepath.Join(tmp, "app", "models.py"), "class User(Base):\n    def name(self):\n        return 'x'\n")
+	writeFile(t, filepath.Join(tmp, "app", "services", "__init__.py"), "")
+	writeFile(t, filepath.Join(tmp, "app", "services", "billing.py"), "def charge(user, amount: int) -> bool:\n    return True\n")
+	writeFile(t, filepath.Join(tmp, "app", "utils.py"), "def slug(s):\n    return s\n")
+	writeFile(t, filepath.Join(tmp, "app", "main.py"), `import os
+import app.models
+from app.services import billing
+from . import utils as u
+from .services.billing import charge
+from ..outside import nothing
+import requests
+`)
+	writeFile(t, filepath.Join(tmp, "src", "lib", "__init__.py"), "")
+	writeFile(t, filepath.Join(tmp, "src", "lib", "core.py"), "def run():\n    pass\n")
+	writeFile(t, filepath.Join(tmp, "scripts", "job.py"), "from lib.core import run\nfrom lib import *\n")
+
+	return tmp
+}
+
+func pythonPkgPaths(t *testing.T, root string, parser utils.TreeSitterParserType) map[string][]string {
+	t.Helper()
+
+	pkgPaths := make(map[string][]string)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, _ := os.ReadFile(path)
+		s := language.NewPythonStrategy(language.StrategyArgs{NodeData: data, NodePath: path, RootDir: root, Parser: parser})
+		for _, pkg := range s.GetNodePackages() {
+			pkgPaths[pkg] = append(pkgPaths[pkg], path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", root, err)
+	}
+	return pkgPaths
+}
+
+func TestPythonStrategy_GetNodePackages(t *testing.T) {
+	tmp := setupPythonRepo(t)
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := pythonPkgPaths(t, tmp, parser)
+
+	tests := map[string]string{
+		"python:app":                  filepath.Join(tmp, "app", "__init__.py"),
+		"python:app.services.billing": filepath.Join(tmp, "app", "services", "billing.py"),
+		"python:src.lib.core":         filepath.Join(tmp, "src", "lib", "core.py"),
+		"python:lib.core":             filepath.Join(tmp, "src", "lib", "core.py"),
+		"python:scripts.job":          filepath.Join(tmp, "scripts", "job.py"),
+	}
+	for key, want := range tests {
+		if len(pkgPaths[key]) != 1 || pkgPaths[key][0] != want {
+			t.Errorf("PkgPaths[%s] = %v, want [%s]", key, pkgPaths[key], want)
+		}
+	}
+}
+
+func TestPythonStrategy_GetNodeImportList(t *testing.T) {
+	tmp := setupPythonRepo(t)
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := pythonPkgPaths(t, tmp, parser)
+
+	importsOf := func(path string) []types.NodeImport {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		s := language.GetStrategy(language.StrategyArgs{
+			NodeData:         data,
+			NodePath:         path,
+			RootDir:          tmp,
+			Parser:           parser,
+			PkgPaths:         pkgPaths,
+			StrategyLanguage: types.PYTHON,
+		})
+		imps, err := s.GetNodeImportList()
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+		sortImports(imps)
+		return imps
+	}
+
+	mainPath := filepath.Join(tmp, "app", "main.py")
+	want := []types.NodeImport{
+		{ImportPackage: filepath.Join(tmp, "app", "models.py"), FilePath: mainPath},
+		{ImportPackage: filepath.Join(tmp, "app", "services", "billing.py"), FilePath: mainPath},
+		{ImportPackage: filepath.Join(tmp, "app", "utils.py"), FilePath: mainPath},
+	}
+	sortImports(want)
+	if got := importsOf(mainPath); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("imports of main.py = %v, want %v", got, want)
+	}
+
+	jobPath := filepath.Join(tmp, "scripts", "job.py")
+	want = []types.NodeImport{
+		{ImportPackage: filepath.Join(tmp, "src", "lib", "__init__.py"), FilePath: jobPath},
+		{ImportPackage: filepath.Join(tmp, "src", "lib", "core.py"), FilePath: jobPath},
+	}
+	sortImports(want)
+	if got := importsOf(jobPath); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("imports of job.py = %v, want %v", got, want)
+	}
+}
+
+func TestPythonStrategy_GetNodeSignatures(t *testing.T) {
+	s := language.NewPythonStrategy(language.StrategyArgs{
+		NodeData: []byte("@cached\ndef charge(user, amount: int) -> bool:\n    pass\n\nclass User(Base):\n    def name(self):\n        pass\n"),
+		NodePath: "billing.py",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	want := []string{"charge(user, amount: int)", "class User(Base)", "name(self)"}
+	if got := s.GetNodeSignatures(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetNodeSignatures() = %v, want %v", got, want)
+	}
+}
+
+func TestGetStrategy_ReturnsPythonStrategy(t *testing.T) {
+	s := language.GetStrategy(language.StrategyArgs{
+		NodePath:         "main.py",
+		Parser:           utils.NewTreeSitterParserType(),
+		StrategyLanguage: types.PYTHON,
+	})
+
+	if _, ok := s.(*language.PythonStrategy); !ok {
+		t.Fatalf("expected *language.PythonStrategy, got %T", s)
+	}
+}
+
+func strategyPkgPaths(t *testing.T, root string, lang types.Language, parser utils.TreeSitterParserType) map[string][]string {
+	t.Helper()
+
+	pkgPaths := make(map[string][]string)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || utils.GetLanguage(path) != lang {
+			return err
+		}
+		data, _ := os.ReadFile(path)
+		s := language.GetStrategy(language.StrategyArgs{NodeData: data, NodePath: path, RootDir: root, Parser: parser, StrategyLanguage: lang})
+		for _, pkg := range s.GetNodePackages() {
+			pkgPaths[pkg] = append(pkgPaths[pkg], path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk %s: %v", root, err)
+	}
+	return pkgPaths
+}
+
+func TestJavaScriptStrategy_GetNodeImportList(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "src", "db.js"), "module.exports = {}\n")
+	writeFile(t, filepath.Join(tmp, "src", "user.service.js"), "export class UserService {}\n")
+	writeFile(t, filepath.Join(tmp, "src", "routes", "index.js"), "export default []\n")
+	writeFile(t, filepath.Join(tmp, "src", "lazy.mjs"), "export const x = 1\n")
+	writeFile(t, filepath.Join(tmp, "src", "config.cjs"), "module.exports = {}\n")
+	writeFile(t, filepath.Join(tmp, "src", "shared.js"), "export const y = 1\n")
+	appPath := filepath.Join(tmp, "src", "app.js")
+	writeFile(t, appPath, `import express from 'express';
+import { UserService } from './user.service';
+import routes from "./routes";
+export * from './shared.js';
+const db = require('./db');
+const config = require("./config.cjs");
+async function load() {
+	return import('./lazy');
+}
+const missing = require('./missing');
+const dynamic = require(name);
+`)
+
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := strategyPkgPaths(t, tmp, types.JAVASCRIPT, parser)
+
+	data, _ := os.ReadFile(appPath)
+	s := language.GetStrategy(language.StrategyArgs{
+		NodeData:         data,
+		NodePath:         appPath,
+		Parser:           parser,
+		PkgPaths:         pkgPaths,
+		StrategyLanguage: types.JAVASCRIPT,
+	})
+	if _, ok := s.(*language.JavaScriptStrategy); !ok {
+		t.Fatalf("expected *language.JavaScriptStrategy, got %T", s)
+	}
+
+	imps, err := s.GetNodeImportList()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	var want []types.NodeImport
+	for _, name := range []string{"user.service.js", "routes/index.js", "shared.js", "db.js", "config.cjs", "lazy.mjs"} {
+		want = append(want, types.NodeImport{ImportPackage: filepath.Join(tmp, "src", name), FilePath: appPath})
+	}
+	sortImports(imps)
+	sortImports(want)
+	if fmt.Sprint(imps) != fmt.Sprint(want) {
+		t.Errorf("imports of app.js = %v, want %v", imps, want)
+	}
+}
+
+func TestJavaScriptStrategy_GetNodeSignatures(t *testing.T) {
+	s := language.NewJavaScriptStrategy(language.StrategyArgs{
+		NodeData: []byte("export function handle(req, res) {}\nclass Cart extends Base {\n  add(item) {}\n}\nconst total = (items) => 0;\nconst one = x => x;\nconst n = 1;\n"),
+		NodePath: "cart.js",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	want := []string{"handle(req, res)", "class Cart", "add(item)", "total(items)", "one(x)"}
+	if got := s.GetNodeSignatures(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetNodeSignatures() = %v, want %v", got, want)
+	}
+}
+
+func TestTypeScriptStrategy_GetNodeImportList(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "tsconfig.base.json"), `{
+	// shared options
+	"compilerOptions": {
+		"baseUrl": "./src",
+		"paths": {
+			"@/*": ["*"],
+			"@lib/*": ["lib/*"],
+		},
+	},
+}
+`)
+	writeFile(t, filepath.Join(tmp, "tsconfig.json"), `{ "extends": "./tsconfig.base.json", "compilerOptions": { "strict": true } }`)
+	writeFile(t, filepath.Join(tmp, "src", "lib", "api.ts"), "export const get = () => 1\n")
+	writeFile(t, filepath.Join(tmp, "src", "lib", "format.ts"), "export const f = 1\n")
+	writeFile(t, filepath.Join(tmp, "src", "models", "index.ts"), "export interface User {}\n")
+	writeFile(t, filepath.Join(tmp, "src", "types.d.ts"), "declare module 'x'\n")
+	writeFile(t, filepath.Join(tmp, "src", "legacy.js"), "module.exports = {}\n")
+	writeFile(t, filepath.Join(tmp, "src", "store.ts"), "export const s = 1\n")
+	writeFile(t, filepath.Join(tmp, "src", "Button.tsx"), "export const Button = () => null\n")
+	appPath := filepath.Join(tmp, "src", "app.tsx")
+	writeFile(t, appPath, `import React from 'react';
+import { get } from '@/lib/api';
+import type { User } from './models';
+import { f } from '@lib/format';
+import './types';
+import legacy = require('./legacy');
+export { s } from "./store.js";
+// import { gone } from './gone';
+const Button = await import('./Button');
+`)
+
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := strategyPkgPaths(t, tmp, types.TYPESCRIPT, parser)
+	for k, v := range strategyPkgPaths(t, tmp, types.JAVASCRIPT, parser) {
+		pkgPaths[k] = append(pkgPaths[k], v...)
+	}
+
+	data, _ := os.ReadFile(appPath)
+	s := language.GetStrategy(language.StrategyArgs{
+		NodeData:         data,
+		NodePath:         appPath,
+		Parser:           parser,
+		PkgPaths:         pkgPaths,
+		StrategyLanguage: types.TYPESCRIPT,
+	})
+	if _, ok := s.(*language.TypeScriptStrategy); !ok {
+		t.Fatalf("expected *language.TypeScriptStrategy, got %T", s)
+	}
+
+	imps, err := s.GetNodeImportList()
+	if err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+
+	var want []types.NodeImport
+	for _, name := range []string{"lib/api.ts", "models/index.ts", "lib/format.ts", "types.d.ts", "legacy.js", "store.ts", "Button.tsx"} {
+		want = append(want, types.NodeImport{ImportPackage: filepath.Join(tmp, "src", name), FilePath: appPath})
+	}
+	sortImports(imps)
+	sortImports(want)
+	if fmt.Sprint(imps) != fmt.Sprint(want) {
+		t.Errorf("imports of app.tsx = %v, want %v", imps, want)
+	}
+}
+
+func TestTSConfig_PathsWithoutBaseURL(t *testing.T) {
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "web", "tsconfig.json"), `{"compilerOptions": {"paths": {"~/*": ["./app/*"], "config": ["./app/config.ts"]}}}`)
+
+	config, err := language.LoadTSConfig(filepath.Join(tmp, "web", "tsconfig.json"))
+	if err != nil {
+		t.Fatalf("LoadTSConfig() error: %v", err)
+	}
+
+	if got, want := config.Candidates("~/ui/button"), []string{filepath.Join(tmp, "web", "app", "ui", "button")}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Candidates(~/ui/button) = %v, want %v", got, want)
+	}
+	if got, want := config.Candidates("config"), []string{filepath.Join(tmp, "web", "app", "config.ts")}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Candidates(config) = %v, want %v", got, want)
+	}
+	if got := config.Candidates("react"); len(got) != 0 {
+		t.Errorf("Candidates(react) = %v, want none", got)
+	}
+}
+
+func TestTypeScriptStrategy_GetNodeSignatures(t *testing.T) {
+	s := language.NewTypeScriptStrategy(language.StrategyArgs{
+		NodeData: []byte(`export interface User {
+  id: string;
+}
+export type Id = string | number;
+export enum Role { Admin, Guest }
+// function commented(a) {}
+export async function fetchUser(id: Id, opts?: { cache: boolean }): Promise<User> {
+  if (id) {
+    return load(id);
+  }
+}
+export abstract class Repo<T> extends Base {
+  constructor(private db: Db) {
+    super(db);
+  }
+  public async find(id: string): Promise<T> {
+    return this.db.get(id);
+  }
+}
+export const useUser = (id: string): User => ({ id });
+const label = "x";
+`),
+		NodePath: "user.ts",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	want := []string{
+		"interface User",
+		"type Id",
+		"enum Role",
+		"fetchUser(id: Id, opts?: { cache: boolean })",
+		"class Repo",
+		"constructor(private db: Db)",
+		"find(id: string)",
+		"useUser(id: string)",
+	}
+	if got := s.GetNodeSignatures(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetNodeSignatures() = %v, want %v", got, want)
+	}
+}
+
+func setupRustWorkspace(t *testing.T) string {
+	t.Helper()
+
+	tmp := t.TempDir()
+	writeFile(t, filepath.Join(tmp, "Cargo.toml"), "[workspace]\nmembers = [\n    \"crates/*\",\n    \"cli\",\n]\n")
+	writeFile(t, filepath.Join(tmp, "crates", "core", "Cargo.toml"), "[package]\nname = \"my-core\"\nversion = \"0.1.0\"\n")
+	writeFile(t, filepath.Join(tmp, "crates", "core", "src", "lib.rs"), "pub mod net;\nmod util;\npub use crate::net::tcp::Listener;\n")
+	writeFile(t, filepath.Join(tmp, "crates", "core", "src", "net", "mod.rs"), "pub mod tcp;\nuse super::util::{self, helpers::*};\npub fn connect() {}\n")
+	writeFile(t, filepath.Join(tmp, "crates", "core", "src", "net", "tcp.rs"), "use crate::util::retry as r;\npub struct Listener;\n")
+	writeFile(t, filepath.Join(tmp, "crates", "core", "src", "util.rs"), `pub fn retry() {}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::net::connect;
+}
+`)
+	writeFile(t, filepath.Join(tmp, "cli", "Cargo.toml"), "[package]\nname = \"cli\"\n\n[[bin]]\nname = \"wm\"\npath = \"src/main.rs\"\n")
+	writeFile(t, filepath.Join(tmp, "cli", "src", "main.rs"), `use my_core::net::tcp::Listener;
+use std::io;
+// use my_core::util;
+mod commands;
+#[path = "platform/unix.rs"]
+mod platform;
+
+fn main() {
+    my_core::net::connect();
+}
+`)
+	writeFile(t, filepath.Join(tmp, "cli", "src", "commands.rs"), "use super::*;\n")
+	writeFile(t, filepath.Join(tmp, "cli", "src", "platform", "unix.rs"), "pub fn init() {}\n")
+	return tmp
+}
+
+func TestRustStrategy_GetNodeImportList(t *testing.T) {
+	tmp := setupRustWorkspace(t)
+	core := filepath.Join(tmp, "crates", "core", "src")
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := strategyPkgPaths(t, tmp, types.RUST, parser)
+
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{filepath.Join(core, "lib.rs"), []string{filepath.Join(core, "net", "mod.rs"), filepath.Join(core, "util.rs"), filepath.Join(core, "net", "tcp.rs")}},
+		{filepath.Join(core, "net", "mod.rs"), []string{filepath.Join(core, "net", "tcp.rs"), filepath.Join(core, "util.rs")}},
+		{filepath.Join(core, "net", "tcp.rs"), []string{filepath.Join(core, "util.rs")}},
+		// super of the tests module is util, not the crate root
+		{filepath.Join(core, "util.rs"), []string{filepath.Join(core, "net", "mod.rs")}},
+		{filepath.Join(tmp, "cli", "src", "main.rs"), []string{filepath.Join(tmp, "cli", "src", "commands.rs"), filepath.Join(tmp, "cli", "src", "platform", "unix.rs"), filepath.Join(core, "net", "tcp.rs"), filepath.Join(core, "net", "mod.rs")}},
+		{filepath.Join(tmp, "cli", "src", "commands.rs"), []string{filepath.Join(tmp, "cli", "src", "main.rs")}},
+	}
+
+	for _, tt := range tests {
+		data, _ := os.ReadFile(tt.path)
+		s := language.GetStrategy(language.StrategyArgs{
+			NodeData:         data,
+			NodePath:         tt.path,
+			Parser:           parser,
+			PkgPaths:         pkgPaths,
+			StrategyLanguage: types.RUST,
+		})
+		if _, ok := s.(*language.RustStrategy); !ok {
+			t.Fatalf("expected *language.RustStrategy, got %T", s)
+		}
+
+		imps, err := s.GetNodeImportList()
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+
+		var want []types.NodeImport
+		for _, path := range tt.want {
+			want = append(want, types.NodeImport{ImportPackage: path, FilePath: tt.path})
+		}
+		sortImports(imps)
+		sortImports(want)
+		if fmt.Sprint(imps) != fmt.Sprint(want) {
+			t.Errorf("imports of %s = %v, want %v", tt.path, imps, want)
+		}
+	}
+}
+
+func TestParseCargoManifest(t *testing.T) {
+	tmp := t.TempDir()
+	path := filepath.Join(tmp, "tools", "Cargo.toml")
+	writeFile(t, path, `[package]
+name = "wingman-tools" # the tools
+
+[dependencies]
+serde = "1"
+shared = { path = "../shared", package = "shared-lib" }
+config.workspace = true
+
+[dependencies.storage-engine]
+path = "../storage"
+features = ["fast"]
+
+[[bin]]
+name = "tool"
+`)
+
+	manifest, err := language.ParseCargoManifest(path)
+	if err != nil {
+		t.Fatalf("ParseCargoManifest() error: %v", err)
+	}
+	if manifest.PackageName != "wingman_tools" {
+		t.Errorf("PackageName = %q, want wingman_tools", manifest.PackageName)
+	}
+	want := map[string]string{
+		"shared":         filepath.Join(tmp, "shared"),
+		"storage_engine": filepath.Join(tmp, "storage"),
+	}
+	if fmt.Sprint(manifest.Dependencies) != fmt.Sprint(want) {
+		t.Errorf("Dependencies = %v, want %v", manifest.Dependencies, want)
+	}
+}
+
+func TestRustStrategy_GetNodeSignatures(t *testing.T) {
+	s := language.NewRustStrategy(language.StrategyArgs{
+		NodeData: []byte(`pub struct Listener<T> {
+    addr: String,
+}
+pub(crate) enum State { Open, Closed }
+pub trait Handler: Send {
+    fn handle(&self, req: Request) -> Response;
+}
+// fn commented() {}
+impl<T> Listener<T>
+where
+    T: Handler,
+{
+    pub async fn accept<'a>(&'a mut self, timeout: Duration) -> io::Result<()> {
+        Ok(())
+    }
+}
+impl Handler for Echo {}
+fn main() {}
+`),
+		NodePath: "listener.rs",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	want := []string{
+		"struct Listener",
+		"enum State",
+		"trait Handler",
+		"handle(&self, req: Request)",
+		"impl<T> Listener<T>",
+		"accept(&'a mut self, timeout: Duration)",
+		"impl Handler for Echo",
+		"main()",
+	}
+	if got := s.GetNodeSignatures(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("GetNodeSignatures() = %v, want %v", got, want)
+	}
+}
+
+func TestJVMStrategy_GetNodeSignatures_KotlinStrings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name:   "raw string",
+			source: "val query = \"\"\"\n    select \"name\" from users\n    where note = '/*' // not a comment\n\"\"\"\nfun afterRawString(id: Long) {}\n",
+		},
+		{
+			name:   "char literal",
+			source: "val quote = '\"' + \"/*\"\nval escaped = '\\''\nfun afterCharLiteral(id: Long) {}\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := language.NewJVMStrategy(language.StrategyArgs{
+				NodeData:         []byte("package com.acme\n\n" + tt.source + "/* a comment */\nclass Last\n"),
+				NodePath:         "Query.kt",
+				Parser:           utils.NewTreeSitterParserType(),
+				StrategyLanguage: types.KOTLIN,
+			})
+
+			got := s.GetNodeSignatures()
+			if len(got) != 2 || !strings.HasSuffix(got[0], "(id: Long)") || got[1] != "class Last" {
+				t.Errorf("GetNodeSignatures() = %v, want the function and class after the string", got)
+			}
+		})
+	}
+}
+
+func setupJVMRepo(t *testing.T) string {
+	t.Helper()
+
+	tmp := t.TempDir()
+	java := filepath.Join(tmp, "orders", "src", "main", "java", "com", "acme")
+	writeFile(t, filepath.Join(java, "orders", "Order.java"), "package com.acme.orders;\n\npublic class Order {\n    public static class Line {}\n}\n")
+	writeFile(t, filepath.Join(java, "orders", "OrderService.java"), `package com.acme.orders;
+
+import java.util.List;
+import com.acme.billing.Invoice;
+import com.acme.shared.*;
+import static com.acme.util.Strings.join;
+
+public class OrderService {
+    public List<Order> find(String id) {
+        return Invoice.of(id);
+    }
+}
+`)
+	writeFile(t, filepath.Join(java, "billing", "Invoice.java"), "package com.acme.billing;\n\nimport com.acme.orders.Order.Line;\n\npublic class Invoice {}\n")
+	writeFile(t, filepath.Join(java, "shared", "Money.java"), "package com.acme.shared;\n\npublic record Money(long cents) {}\n")
+	writeFile(t, filepath.Join(java, "shared", "Currency.java"), "package com.acme.shared;\n\npublic enum Currency { EUR }\n")
+	writeFile(t, filepath.Join(java, "util", "Strings.java"), "public final class Strings {}\n")
+
+	kotlin := filepath.Join(tmp, "orders", "src", "main", "kotlin", "com", "acme")
+	writeFile(t, filepath.Join(kotlin, "util", "Dates.kt"), "package com.acme.util\n\nfun formatDate(epoch: Long): String = \"\"\n")
+	writeFile(t, filepath.Join(kotlin, "orders", "OrderController.kt"), `package com.acme.orders
+
+import com.acme.billing.Invoice as Bill
+import com.acme.util.formatDate
+// import com.acme.shared.Money
+
+class OrderController(private val service: OrderService) {
+    fun show(id: String) = formatDate(0)
+}
+`)
+	return tmp
+}
+
+func TestJVMStrategy_GetNodeImportList(t *testing.T) {
+	tmp := setupJVMRepo(t)
+	java := filepath.Join(tmp, "orders", "src", "main", "java", "com", "acme")
+	kotlin := filepath.Join(tmp, "orders", "src", "main", "kotlin", "com", "acme")
+
+	parser := utils.NewTreeSitterParserType()
+	pkgPaths := strategyPkgPaths(t, tmp, types.JAVA, parser)
+	for k, v := range strategyPkgPaths(t, tmp, types.KOTLIN, parser) {
+		pkgPaths[k] = append(pkgPaths[k], v...)
+	}
+
+	tests := []struct {
+		path     string
+		language types.Language
+		want     []string
+	}{
+		{
+			filepath.Join(java, "orders", "OrderService.java"), types.JAVA,
+			[]string{
+				filepath.Join(java, "billing", "Invoice.java"),
+				filepath.Join(java, "shared", "Money.java"),
+				filepath.Join(java, "shared", "Currency.java"),
+				filepath.Join(java, "util", "Strings.java"),
+				filepath.Join(java, "orders", "Order.java"),
+			},
+		},
+		{filepath.Join(java, "billing", "Invoice.java"), types.JAVA, []string{filepath.Join(java, "orders", "Order.java")}},
+		{
+			filepath.Join(kotlin, "orders", "OrderController.kt"), types.KOTLIN,
+			[]string{
+				filepath.Join(java, "billing", "Invoice.java"),
+				filepath.Join(kotlin, "util", "Dates.kt"),
+				filepath.Join(java, "orders", "OrderService.java"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		data, _ := os.ReadFile(tt.path)
+		s := language.GetStrategy(language.StrategyArgs{
+			NodeData:         data,
+			NodePath:         tt.path,
+			Parser:           parser,
+			PkgPaths:         pkgPaths,
+			StrategyLanguage: tt.language,
+		})
+		if _, ok := s.(*language.JVMStrategy); !ok {
+			t.Fatalf("expected *language.JVMStrategy, got %T", s)
+		}
+
+		imps, err := s.GetNodeImportList()
+		if err != nil {
+			t.Fatalf("expected nil error, got %v", err)
+		}
+
+		var want []types.NodeImport
+		for _, path := range tt.want {
+			want = append(want, types.NodeImport{ImportPackage: path, FilePath: tt.path})
+		}
+		sortImports(imps)
+		sortImports(want)
+		if fmt.Sprint(imps) != fmt.Sprint(want) {
+			t.Errorf("imports of %s = %v, want %v", filepath.Base(tt.path), imps, want)
+		}
+	}
+}
+
+func TestJVMStrategy_GetNodeSignatures(t *testing.T) {
+	parser := utils.NewTreeSitterParserType()
+
+	java := language.NewJVMStrategy(language.StrategyArgs{
+		NodeData: []byte(`package com.acme;
+
+public class Cart implements Api {
+    public Cart(Store store) {}
+    public long total(List<Item> items, boolean tax) { return 0; }
+    interface Listener { void changed(Cart cart); }
+    record Line(String sku, int qty) {}
+}
+`),
+		NodePath:         "Cart.java",
+		Parser:           parser,
+		StrategyLanguage: types.JAVA,
+	})
+	want := []string{"class Cart", "Cart(Store store)", "total(List<Item> items, boolean tax)", "interface Listener", "changed(Cart cart)", "record Line(String sku, int qty)"}
+	if got := java.GetNodeSignatures(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("java GetNodeSignatures() = %v, want %v", got, want)
+	}
+
+	kotlin := language.NewJVMStrategy(language.StrategyArgs{
+		NodeData: []byte(`package com.acme
+
+data class Cart(val items: List<Item>) {
+    suspend fun total(tax: Boolean): Long = 0
+    companion object Factory {}
+}
+// fun commented() {}
+sealed interface Event
+fun String.toSku(prefix: String = "x"): Sku = Sku(this)
+`),
+		NodePath:         "Cart.kt",
+		Parser:           parser,
+		StrategyLanguage: types.KOTLIN,
+	})
+	want = []string{"class Cart", "total(tax: Boolean)", "object Factory", "interface Event", "toSku(prefix: String = \"x\")"}
+	if got := kotlin.GetNodeSignatures(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("kotlin GetNodeSignatures() = %v, want %v", got, want)
+	}
+}
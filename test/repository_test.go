@@ -1,11 +1,14 @@
 package test
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 
 	"github.com/manosriram/wingman/internal/repository"
+	"github.com/manosriram/wingman/internal/types"
 )
 
 func writeFileRepo(t *testing.T, path, contents string) {
@@ -28,7 +31,6 @@ func TestRepository_Run_BuildsGraphAndASTs(t *testing.T) {
 	writeFileRepo(t, goModFilePath, "module "+modName+"\n\ngo 1.22\n")
 
 	// A small repo with internal imports.
-	// Note: repository.populateRepositoryNodeImports uses the first line "package X" as the key.
 	aFilePath := filepath.Join(tmp, "a.go")
 	writeFileRepo(t, aFilePath, `package a
 
@@ -50,8 +52,14 @@ import (
 func B() {}
 `)
 
+	fooFilePath := filepath.Join(tmp, "internal", "foo", "foo.go")
+	writeFileRepo(t, fooFilePath, "package foo\n\nfunc Foo() {}\n")
+	barFilePath := filepath.Join(tmp, "internal", "bar", "bar.go")
+	writeFileRepo(t, barFilePath, "package bar\n\nfunc Bar() {}\n")
+
 	// Add a file under .git that should be skipped.
-	writeFileRepo(t, filepath.Join(tmp, ".git", "ignored.go"), `package ignored
+	ignoredFilePath := filepath.Join(tmp, ".git", "ignored.go")
+	writeFileRepo(t, ignoredFilePath, `package ignored
 
 func Ignored() {}
 `)
@@ -61,30 +69,30 @@ func Ignored() {}
 		t.Fatalf("Run() error: %v", err)
 	}
 
-	// Basic sanity: ASTs created for packages a and b.
-	if _, ok := r.RepositoryNodesAST["a"]; !ok {
-		t.Fatalf("expected RepositoryNodesAST to contain key %q", "a")
+	// Basic sanity: ASTs created for a.go and b.go, keyed by their path.
+	if _, ok := r.RepositoryNodesAST[aFilePath]; !ok {
+		t.Fatalf("expected RepositoryNodesAST to contain key %q", aFilePath)
 	}
-	if _, ok := r.RepositoryNodesAST["b"]; !ok {
-		t.Fatalf("expected RepositoryNodesAST to contain key %q", "b")
+	if _, ok := r.RepositoryNodesAST[bFilePath]; !ok {
+		t.Fatalf("expected RepositoryNodesAST to contain key %q", bFilePath)
 	}
 
 	// Ensure .git was skipped.
-	if _, ok := r.RepositoryNodesAST["ignored"]; ok {
-		t.Fatalf("did not expect RepositoryNodesAST to contain package from .git directory")
+	if _, ok := r.RepositoryNodesAST[ignoredFilePath]; ok {
+		t.Fatalf("did not expect RepositoryNodesAST to contain a file from the .git directory")
 	}
 
 	// NodeImports should be populated for a and b.
-	if imps, ok := r.NodeImports["a"]; !ok {
-		t.Fatalf("expected NodeImports to contain key %q", "a")
-	} else if len(imps) == 0 {
-		t.Fatalf("expected NodeImports[%q] to be non-empty", "a")
+	if imps, ok := r.NodeImports[aFilePath]; !ok {
+		t.Fatalf("expected NodeImports to contain key %q", aFilePath)
+	} else if len(imps) != 1 || imps[0].ImportPackage != fooFilePath {
+		t.Fatalf("expected NodeImports[%q] to be foo.go, got %v", aFilePath, imps)
 	}
 
-	if imps, ok := r.NodeImports["b"]; !ok {
-		t.Fatalf("expected NodeImports to contain key %q", "b")
-	} else if len(imps) == 0 {
-		t.Fatalf("expected NodeImports[%q] to be non-empty", "b")
+	if imps, ok := r.NodeImports[bFilePath]; !ok {
+		t.Fatalf("expected NodeImports to contain key %q", bFilePath)
+	} else if len(imps) != 2 {
+		t.Fatalf("expected NodeImports[%q] to be foo.go and bar.go, got %v", bFilePath, imps)
 	}
 
 	// Graph should have nodes/edges after BuildGraphFromImports.
@@ -96,16 +104,17 @@ func Ignored() {}
 	}
 
 	// After Run(), PageRank should have produced scores for graph nodes.
-	// Repository.Run prints scores using NodeScores[k] where k is a package key ("a", "b").
-	// We assert those keys exist in the score map.
-	if r.RepositoryNodesAST["a"].Algorithm == nil {
-		t.Fatalf("expected Algorithm to be initialized for AST %q", "a")
+	if r.RepositoryNodesAST[aFilePath].Algorithm == nil {
+		t.Fatalf("expected Algorithm to be initialized for AST %q", aFilePath)
+	}
+	if _, ok := r.RepositoryNodesAST[aFilePath].Algorithm.GetNodeScores()[aFilePath]; !ok {
+		t.Fatalf("expected NodeScores to contain key %q", aFilePath)
 	}
-	if _, ok := r.RepositoryNodesAST["a"].Algorithm.NodeScores[aFilePath]; !ok {
-		t.Fatalf("expected NodeScores to contain key %q", "a")
+	if _, ok := r.RepositoryNodesAST[bFilePath].Algorithm.GetNodeScores()[bFilePath]; !ok {
+		t.Fatalf("expected NodeScores to contain key %q", bFilePath)
 	}
-	if _, ok := r.RepositoryNodesAST["b"].Algorithm.NodeScores[bFilePath]; !ok {
-		t.Fatalf("expected NodeScores to contain key %q", "b")
+	if len(r.RankedFiles) == 0 || r.RankedFiles[0] != fooFilePath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, fooFilePath)
 	}
 }
 
@@ -132,3 +141,404 @@ func TestRepository_Run_EmptyDir_NoError(t *testing.T) {
 		t.Fatalf("expected Graph.G to be empty, got %d", len(r.Graph.G))
 	}
 }
+
+func TestRepository_Run_SkipsUnreadableEntries(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nfunc main() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "locked", "locked.go"), "package locked\n")
+
+	// A file which cannot be read, and a directory which cannot be listed
+	// unless the tests run as root
+	if err := os.Symlink(filepath.Join(tmp, "missing.go"), filepath.Join(tmp, "dangling.go")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(tmp, "locked"), 0o000); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(tmp, "locked"), 0o755) })
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error with unreadable entries: %v", err)
+	}
+
+	if _, ok := r.RepositoryNodesAST[filepath.Join(tmp, "main.go")]; !ok {
+		t.Fatalf("expected main.go to be indexed, got %v", r.RankedFiles)
+	}
+	if _, ok := r.RepositoryNodesAST[filepath.Join(tmp, "dangling.go")]; ok {
+		t.Fatalf("expected dangling.go to be left out")
+	}
+}
+
+func TestRepository_Run_PythonRepository(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "shop", "__init__.py"), "")
+	writeFileRepo(t, filepath.Join(tmp, "shop", "models.py"), "class Order:\n    def total(self):\n        return 0\n")
+	writeFileRepo(t, filepath.Join(tmp, "shop", "cart.py"), "from .models import Order\n")
+	writeFileRepo(t, filepath.Join(tmp, "shop", "checkout.py"), "from shop.models import Order\nfrom shop import cart\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	modelsPath := filepath.Join(tmp, "shop", "models.py")
+	if len(r.RankedFiles) == 0 || r.RankedFiles[0] != modelsPath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, modelsPath)
+	}
+	if got := r.Signatures[modelsPath]; len(got) != 2 || got[0] != "class Order" || got[1] != "total(self)" {
+		t.Errorf("Signatures[models.py] = %v, want the class and its method", got)
+	}
+}
+
+func TestRepository_Run_JavaScriptRepository(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "lib", "store.js"), "export function get(key) {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "lib", "index.js"), "export * from './store';\n")
+	writeFileRepo(t, filepath.Join(tmp, "server.js"), "const lib = require('./lib');\nconst store = require('./lib/store');\n")
+	writeFileRepo(t, filepath.Join(tmp, "node_modules", "dep", "index.js"), "module.exports = {}\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if _, ok := r.NodeImports[filepath.Join(tmp, "node_modules", "dep", "index.js")]; ok {
+		t.Fatal("node_modules should be skipped")
+	}
+	storePath := filepath.Join(tmp, "lib", "store.js")
+	if len(r.RankedFiles) != 3 || r.RankedFiles[0] != storePath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, storePath)
+	}
+}
+
+func countChars(s string) int {
+	return len(s)
+}
+
+func newRankedRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+
+	r := repository.NewRepository(t.TempDir())
+	r.RankedFiles = []string{"/repo/core.go", "/repo/util.go", "/repo/cmd.go"}
+	r.Signatures = map[string][]string{
+		"/repo/core.go": {"Core()", "Run(ctx)"},
+		"/repo/util.go": {"Helper(x)"},
+		"/repo/cmd.go":  {"main()"},
+	}
+	return r
+}
+
+func TestRepository_BuildRepoMap_NoLimitIncludesEverythingInOrder(t *testing.T) {
+	r := newRankedRepository(t)
+
+	repoMap := r.BuildRepoMap(repository.RepoMapOptions{CountTokens: countChars})
+
+	if repoMap.IsTruncated() {
+		t.Fatalf("expected no truncation, got %v", repoMap.TruncatedFiles)
+	}
+	if len(repoMap.Entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(repoMap.Entries))
+	}
+	for i, path := range r.RankedFiles {
+		if repoMap.Entries[i].Path != path {
+			t.Fatalf("entry %d = %s, want %s", i, repoMap.Entries[i].Path, path)
+		}
+	}
+}
+
+func TestRepository_BuildRepoMap_StopsAtBudget(t *testing.T) {
+	r := newRankedRepository(t)
+
+	unlimited := r.BuildRepoMap(repository.RepoMapOptions{CountTokens: countChars})
+	firstEntry := len("/repo/core.go: \nCore()\nRun(ctx)\n\n")
+
+	// Room for the first file only
+	budget := unlimited.ReservedTokens + firstEntry + 1
+	repoMap := r.BuildRepoMap(repository.RepoMapOptions{MaxTokens: budget, CountTokens: countChars})
+
+	if len(repoMap.Entries) != 1 || repoMap.Entries[0].Path != "/repo/core.go" {
+		t.Fatalf("expected only the top ranked file, got %#v", repoMap.Entries)
+	}
+	if repoMap.TokenCount != firstEntry {
+		t.Fatalf("expected TokenCount %d, got %d", firstEntry, repoMap.TokenCount)
+	}
+	if len(repoMap.TruncatedFiles) != 2 || repoMap.TruncatedFiles[0] != "/repo/util.go" || repoMap.TruncatedFiles[1] != "/repo/cmd.go" {
+		t.Fatalf("expected util.go and cmd.go to be truncated in rank order, got %v", repoMap.TruncatedFiles)
+	}
+	if repoMap.ReservedTokens+repoMap.TokenCount > budget {
+		t.Fatalf("repo map exceeds budget: %d > %d", repoMap.ReservedTokens+repoMap.TokenCount, budget)
+	}
+}
+
+func TestRepository_BuildRepoMap_ReservesInputHistoryAndAddedFiles(t *testing.T) {
+	r := newRankedRepository(t)
+
+	base := r.BuildRepoMap(repository.RepoMapOptions{CountTokens: countChars})
+
+	r.AddedFiles["/repo/core.go"] = "package repo"
+	withContext := r.BuildRepoMap(repository.RepoMapOptions{
+		Input:       "why is Core slow?",
+		History:     []types.Message{{Role: "user", Content: "hi"}, {Role: "assistant", Content: "hello"}},
+		CountTokens: countChars,
+	})
+
+	want := base.ReservedTokens +
+		len("/repo/core.go : package repo\n") +
+		len("why is Core slow?") +
+		len("hi") + len("hello")
+	if withContext.ReservedTokens != want {
+		t.Fatalf("expected %d reserved tokens, got %d", want, withContext.ReservedTokens)
+	}
+
+	// A budget smaller than the reserved tokens leaves no room for the map
+	repoMap := r.BuildRepoMap(repository.RepoMapOptions{
+		MaxTokens:   withContext.ReservedTokens,
+		Input:       "why is Core slow?",
+		CountTokens: countChars,
+	})
+	if len(repoMap.Entries) != 0 || len(repoMap.TruncatedFiles) != 3 {
+		t.Fatalf("expected every file to be truncated, got entries=%v truncated=%v", repoMap.Entries, repoMap.TruncatedFiles)
+	}
+}
+
+func TestRepository_Run_TypeScriptRepository(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "tsconfig.json"), `{"compilerOptions": {"baseUrl": ".", "paths": {"@/*": ["src/*"]}}}`)
+	writeFileRepo(t, filepath.Join(tmp, "src", "api.ts"), "export interface Client {}\nexport function request(path: string) {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "src", "hooks.ts"), "import { request } from './api';\n")
+	writeFileRepo(t, filepath.Join(tmp, "src", "App.tsx"), "import { request } from '@/api';\nimport './hooks';\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	apiPath := filepath.Join(tmp, "src", "api.ts")
+	if len(r.RankedFiles) != 3 || r.RankedFiles[0] != apiPath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, apiPath)
+	}
+	if got := r.Signatures[apiPath]; len(got) != 2 || got[0] != "interface Client" || got[1] != "request(path: string)" {
+		t.Errorf("Signatures[api.ts] = %v, want the interface and the function", got)
+	}
+}
+
+func TestRepository_Run_RustRepository(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "Cargo.toml"), "[package]\nname = \"server\"\n")
+	writeFileRepo(t, filepath.Join(tmp, "src", "main.rs"), "mod config;\nmod routes;\n\nfn main() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "src", "config.rs"), "pub struct Config;\n")
+	writeFileRepo(t, filepath.Join(tmp, "src", "routes.rs"), "use crate::config::Config;\n")
+	writeFileRepo(t, filepath.Join(tmp, "target", "debug", "build", "out.rs"), "pub const GENERATED: u8 = 0;\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	if _, ok := r.NodeImports[filepath.Join(tmp, "target", "debug", "build", "out.rs")]; ok {
+		t.Fatal("target should be skipped")
+	}
+	configPath := filepath.Join(tmp, "src", "config.rs")
+	if len(r.RankedFiles) != 3 || r.RankedFiles[0] != configPath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, configPath)
+	}
+	if got := r.Signatures[configPath]; len(got) != 1 || got[0] != "struct Config" {
+		t.Errorf("Signatures[config.rs] = %v, want the struct", got)
+	}
+}
+
+func TestRepository_Run_KeepsTargetPackagesOutsideCargo(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/deploy\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nimport \"example.com/deploy/target\"\n\nfunc main() { target.Resolve() }\n")
+	writeFileRepo(t, filepath.Join(tmp, "target", "target.go"), "package target\n\nfunc Resolve() {}\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	targetPath := filepath.Join(tmp, "target", "target.go")
+	if got := r.NodeImports[filepath.Join(tmp, "main.go")]; len(got) != 1 || got[0].ImportPackage != targetPath {
+		t.Errorf("imports of main.go = %v, want %s", got, targetPath)
+	}
+}
+
+func TestRepository_Run_JavaRepository(t *testing.T) {
+	tmp := t.TempDir()
+	src := filepath.Join(tmp, "src", "main", "java", "com", "acme")
+	writeFileRepo(t, filepath.Join(src, "domain", "Account.java"), "package com.acme.domain;\n\npublic class Account {\n    public long balance() { return 0; }\n}\n")
+	writeFileRepo(t, filepath.Join(src, "domain", "Transfer.java"), "package com.acme.domain;\n\npublic class Transfer {\n    Account from;\n}\n")
+	writeFileRepo(t, filepath.Join(src, "api", "AccountController.java"), "package com.acme.api;\n\nimport com.acme.domain.*;\n\npublic class AccountController {}\n")
+	writeFileRepo(t, filepath.Join(src, "jobs", "Statements.java"), "package com.acme.jobs;\n\nimport com.acme.domain.Account;\n\npublic class Statements {}\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run() error: %v", err)
+	}
+
+	accountPath := filepath.Join(src, "domain", "Account.java")
+	if len(r.RankedFiles) != 4 || r.RankedFiles[0] != accountPath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, accountPath)
+	}
+	if got := r.Signatures[accountPath]; len(got) != 2 || got[0] != "class Account" || got[1] != "balance()" {
+		t.Errorf("Signatures[Account.java] = %v, want the class and its method", got)
+	}
+}
+
+func TestRepository_Run_WeightsImportEdgesByReferences(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+
+	// Both files of the package are imported, only z.go is used
+	unusedPath := filepath.Join(tmp, "store", "a.go")
+	usedPath := filepath.Join(tmp, "store", "z.go")
+	writeFileRepo(t, unusedPath, "package store\n\nfunc Unused() {}\n")
+	writeFileRepo(t, usedPath, "package store\n\nfunc Lookup() {}\n")
+
+	mainPath := filepath.Join(tmp, "main.go")
+	writeFileRepo(t, mainPath, "package main\n\nimport \"example.com/app/store\"\n\nfunc main() {\n\tstore.Lookup()\n\tstore.Lookup()\n}\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	for _, n := range r.Graph.GetOutNodesOfNode(mainPath) {
+		want := 1.0
+		if n.NodeValue == usedPath {
+			want = 3 // The import and two references
+		}
+		if n.Weight != want {
+			t.Errorf("edge main.go -> %s weighs %v, want %v", n.NodeValue, n.Weight, want)
+		}
+	}
+	if len(r.RankedFiles) != 3 || r.RankedFiles[0] != usedPath {
+		t.Fatalf("RankedFiles = %v, want %s first", r.RankedFiles, usedPath)
+	}
+}
+
+func TestRepository_Run_UsesSelectedContextAlgorithm(t *testing.T) {
+	dir := setupGitRepo(t)
+	writeFileRepo(t, filepath.Join(dir, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(dir, "lib", "lib.go"), "package lib\n\nfunc Lib() {}\n")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "lib")
+
+	// main.go imports lib.go, which PageRank favors, but was changed the most
+	writeFileRepo(t, filepath.Join(dir, "main.go"), "package main\n\nimport \"example.com/app/lib\"\n\nfunc main() {\n\tlib.Lib()\n}\n")
+	runGit(t, dir, "add", "-A")
+	runGit(t, dir, "commit", "-q", "-m", "main")
+
+	r := repository.NewRepository(dir)
+	r.ContextAlgorithm = types.RECENCY_CONTEXT_ALGORITHM
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	mainPath := filepath.Join(dir, "main.go")
+	if r.RankedFiles[0] != mainPath || r.RankedSymbols[0].FilePath != mainPath {
+		t.Fatalf("RankedFiles = %v RankedSymbols = %v, want main.go first", r.RankedFiles, r.RankedSymbols)
+	}
+	if got := r.RepositoryNodesAST[mainPath].Algorithm.GetAlgorithmType(); got != types.RECENCY_CONTEXT_ALGORITHM {
+		t.Fatalf("AST algorithm = %s, want recency", got)
+	}
+
+	pagerank := repository.NewRepository(dir)
+	if err := pagerank.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if pagerank.RankedFiles[0] != filepath.Join(dir, "lib", "lib.go") {
+		t.Fatalf("RankedFiles = %v, want lib.go first with PageRank", pagerank.RankedFiles)
+	}
+}
+
+func TestRepository_Run_UnknownContextAlgorithm(t *testing.T) {
+	r := repository.NewRepository(t.TempDir())
+	r.ContextAlgorithm = "katz"
+	if err := r.Run(); err == nil {
+		t.Fatalf("expected an error for an unknown context algorithm")
+	}
+}
+
+func TestRepository_Run_ScoresOnceForAllASTs(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "lib", "lib.go"), "package lib\n\nfunc Lib() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nimport \"example.com/app/lib\"\n\nfunc main() {\n\tlib.Lib()\n}\n")
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if r.Algorithm == nil || len(r.Algorithm.GetNodeScores()) != 2 {
+		t.Fatalf("expected the repository algorithm to score both files, got %v", r.Algorithm)
+	}
+	for path, a := range r.RepositoryNodesAST {
+		if a.Algorithm != r.Algorithm {
+			t.Errorf("AST of %s has its own algorithm, want the repository one", path)
+		}
+	}
+}
+
+func TestRepository_Run_IndexesConcurrentlyAndDeterministically(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "tsconfig.json"), `{"compilerOptions": {"baseUrl": "src"}}`)
+	writeFileRepo(t, filepath.Join(tmp, "Cargo.toml"), "[package]\nname = \"app\"\n")
+	for i := range 20 {
+		pkg := fmt.Sprintf("p%d", i%4)
+		writeFileRepo(t, filepath.Join(tmp, pkg, fmt.Sprintf("f%d.go", i)), fmt.Sprintf(
+			"package %s\n\nimport \"example.com/app/p%d\"\n\nfunc F%d() { p%d.F%d() }\n", pkg, (i+1)%4, i, (i+1)%4, (i+1)%20))
+		writeFileRepo(t, filepath.Join(tmp, "src", fmt.Sprintf("m%d.ts", i)), fmt.Sprintf(
+			"import { m%d } from \"./m%d\";\n\nexport function m%d() { return m%d(); }\n", (i+1)%20, (i+1)%20, i, (i+1)%20))
+		writeFileRepo(t, filepath.Join(tmp, "py", fmt.Sprintf("mod%d.py", i)), fmt.Sprintf(
+			"from py.mod%d import run%d\n\ndef run%d():\n    return run%d()\n", (i+1)%20, (i+1)%20, i, (i+1)%20))
+	}
+	writeFileRepo(t, filepath.Join(tmp, "src", "main.rs"), "mod util;\n\nfn main() { util::help(); }\n")
+	writeFileRepo(t, filepath.Join(tmp, "src", "util.rs"), "pub fn help() {}\n")
+
+	run := func(workers int) *repository.Repository {
+		r := repository.NewRepository(tmp)
+		r.Workers = workers
+		if err := r.Run(); err != nil {
+			t.Fatalf("Run with %d workers: %v", workers, err)
+		}
+		return r
+	}
+
+	sequential := run(1)
+	for range 3 {
+		concurrent := run(8)
+
+		for name, got := range map[string][2]any{
+			"PkgPaths":      {sequential.PkgPaths, concurrent.PkgPaths},
+			"NodeImports":   {sequential.NodeImports, concurrent.NodeImports},
+			"Signatures":    {sequential.Signatures, concurrent.Signatures},
+			"Symbols":       {sequential.Symbols, concurrent.Symbols},
+			"RankedFiles":   {sequential.RankedFiles, concurrent.RankedFiles},
+			"RankedSymbols": {sequential.RankedSymbols, concurrent.RankedSymbols},
+		} {
+			if !reflect.DeepEqual(got[0], got[1]) {
+				t.Errorf("%s with 8 workers = %v, want %v as with one", name, got[1], got[0])
+			}
+		}
+		if len(concurrent.RepositoryNodesAST) != len(sequential.RepositoryNodesAST) {
+			t.Errorf("ASTs = %d, want %d", len(concurrent.RepositoryNodesAST), len(sequential.RepositoryNodesAST))
+		}
+	}
+
+	// Every scanned file gets an AST using the repository's parsers
+	if len(sequential.RepositoryNodesAST) != 62 {
+		t.Fatalf("ASTs = %d, want one per source file", len(sequential.RepositoryNodesAST))
+	}
+	for path, a := range sequential.RepositoryNodesAST {
+		imports, err := a.GetNodeImports()
+		if err != nil || !reflect.DeepEqual(imports, sequential.NodeImports[path]) {
+			t.Errorf("imports of %s from its AST = %v, %v, want %v", path, imports, err, sequential.NodeImports[path])
+		}
+	}
+}
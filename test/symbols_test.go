@@ -0,0 +1,303 @@
+package test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/manosriram/wingman/internal/language"
+	"github.com/manosriram/wingman/internal/repository"
+	"github.com/manosriram/wingman/internal/symbols"
+	"github.com/manosriram/wingman/internal/types"
+	"github.com/manosriram/wingman/internal/utils"
+)
+
+func referenceNames(refs []types.SymbolReference) map[string]int {
+	names := make(map[string]int)
+	for _, ref := range refs {
+		names[ref.Name]++
+	}
+	return names
+}
+
+func TestGolangStrategy_GetNodeSymbols(t *testing.T) {
+	source := "package store\n\ntype Store struct{}\n\nfunc (s *Store) Get(key string) string {\n\treturn lookup(key)\n}\n"
+	s := language.NewGolangStrategy(language.StrategyArgs{
+		NodeData: []byte(source),
+		NodePath: "/repo/store.go",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	got := s.GetNodeSymbols()
+
+	var defs []string
+	for _, d := range got.Definitions {
+		defs = append(defs, fmt.Sprintf("%s@%d:%s", d.Name, d.Line, d.Signature))
+	}
+	want := []string{"Store@3:type Store", "Get@5:Get(key string)"}
+	if fmt.Sprint(defs) != fmt.Sprint(want) {
+		t.Fatalf("definitions = %v, want %v", defs, want)
+	}
+
+	get := got.Definitions[1]
+	if source[get.Start:get.End] != "func (s *Store) Get(key string) string {\n\treturn lookup(key)\n}" {
+		t.Errorf("Get spans %q, want the whole method", source[get.Start:get.End])
+	}
+
+	refs := referenceNames(got.References)
+	if refs["lookup"] != 1 || refs["Store"] != 2 || refs["key"] != 2 {
+		t.Errorf("references = %v, want lookup once, Store and key twice", refs)
+	}
+}
+
+func TestTypeScriptStrategy_GetNodeSymbols_TSX(t *testing.T) {
+	source := "interface Props {\n  label: string;\n  onClick(id: number): void;\n}\n\nexport const Button = (props: Props) => {\n  return <button onClick={() => track(props.label)}>{props.label}</button>;\n};\n"
+	s := language.NewTypeScriptStrategy(language.StrategyArgs{
+		NodeData: []byte(source),
+		NodePath: "/repo/src/Button.tsx",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	got := s.GetNodeSymbols()
+
+	var defs []string
+	for _, d := range got.Definitions {
+		defs = append(defs, fmt.Sprintf("%s@%d:%s", d.Name, d.Line, d.Signature))
+	}
+	want := []string{"Props@1:interface Props", "onClick@3:onClick(id: number)", "Button@6:Button(props: Props)"}
+	if fmt.Sprint(defs) != fmt.Sprint(want) {
+		t.Fatalf("definitions = %v, want %v", defs, want)
+	}
+
+	button := got.Definitions[2]
+	if source[button.Start:button.End] != "Button = (props: Props) => {\n  return <button onClick={() => track(props.label)}>{props.label}</button>;\n}" {
+		t.Errorf("Button spans %q, want the whole arrow function", source[button.Start:button.End])
+	}
+
+	refs := referenceNames(got.References)
+	if refs["track"] != 1 || refs["Props"] != 2 {
+		t.Errorf("references = %v, want track once and Props twice", refs)
+	}
+}
+
+func TestRustStrategy_GetNodeSymbols(t *testing.T) {
+	source := "struct Config {}\n\nimpl Display for Config {\n}\n\nfn load() -> Config {\n    parse()\n}\n\n// fn trailing() {}\n"
+	s := language.NewRustStrategy(language.StrategyArgs{
+		NodeData: []byte(source),
+		NodePath: "/repo/src/config.rs",
+		Parser:   utils.NewTreeSitterParserType(),
+	})
+
+	got := s.GetNodeSymbols()
+
+	var defs []string
+	for _, d := range got.Definitions {
+		defs = append(defs, fmt.Sprintf("%s@%d:%s", d.Name, d.Line, d.Signature))
+	}
+	want := []string{"Config@1:struct Config", "Config@3:impl Display for Config", "load@6:load()"}
+	if fmt.Sprint(defs) != fmt.Sprint(want) {
+		t.Fatalf("definitions = %v, want %v", defs, want)
+	}
+
+	impl, load := got.Definitions[1], got.Definitions[2]
+	if source[impl.Start:impl.End] != "impl Display for Config {\n}" {
+		t.Errorf("impl spans %q, want the impl block", source[impl.Start:impl.End])
+	}
+	if source[load.Start:load.End] != "fn load() -> Config {\n    parse()\n}" {
+		t.Errorf("load spans %q, want the function without what follows it", source[load.Start:load.End])
+	}
+
+	refs := referenceNames(got.References)
+	if refs["parse"] != 1 || refs["Config"] != 3 || refs["trailing"] != 0 {
+		t.Errorf("references = %v, want parse once, Config three times and nothing from comments", refs)
+	}
+}
+
+func TestIndex_BuildGraph_LinksVisibleDefinitions(t *testing.T) {
+	index := symbols.NewIndex()
+
+	helper := types.Symbol{Name: "Helper", FilePath: "/repo/util/util.go", Line: 3, Start: 10, End: 40}
+	index.Add("/repo/util/util.go", types.NodeSymbols{Definitions: []types.Symbol{helper}})
+
+	// Same name, but in a file nobody imports
+	other := types.Symbol{Name: "Helper", FilePath: "/repo/other/other.go", Line: 1, Start: 0, End: 20}
+	index.Add("/repo/other/other.go", types.NodeSymbols{Definitions: []types.Symbol{other}})
+
+	run := types.Symbol{Name: "Run", FilePath: "/repo/cmd/main.go", Line: 5, Start: 30, End: 90}
+	index.Add("/repo/cmd/main.go", types.NodeSymbols{
+		Definitions: []types.Symbol{run},
+		References: []types.SymbolReference{
+			{Name: "Run", Offset: 35},    // Its own name
+			{Name: "Helper", Offset: 50}, // Inside Run
+			{Name: "Helper", Offset: 60},
+			{Name: "Helper", Offset: 100}, // Outside of any definition
+		},
+	})
+
+	imports := map[string][]types.NodeImport{
+		"/repo/cmd/main.go": {{ImportPackage: "/repo/util/util.go", FilePath: "/repo/cmd/main.go"}},
+	}
+	g := index.BuildGraph(imports)
+
+	if outs := g.GetOutNodesOfNode(run.ID()); len(outs) != 1 || outs[0].NodeValue != helper.ID() || outs[0].Weight != 2 {
+		t.Errorf("edges of Run = %v, want one to util.Helper weighing 2", outs)
+	}
+	if outs := g.GetOutNodesOfNode("/repo/cmd/main.go"); len(outs) != 1 || outs[0].NodeValue != helper.ID() {
+		t.Errorf("edges of main.go = %v, want one to util.Helper", outs)
+	}
+	if _, ok := g.G[other.ID()]; !ok || len(g.GetInNodesOfNode(other.ID())) != 0 {
+		t.Errorf("other.Helper should be a node nothing refers to")
+	}
+}
+
+func TestRepository_Run_RanksSymbols(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+
+	// A large file where only Lookup is used
+	writeFileRepo(t, filepath.Join(tmp, "store", "store.go"), `package store
+
+func Lookup(key string) string { return key }
+
+func Unused1() {}
+
+func Unused2() {}
+
+func Unused3() {}
+`)
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), `package main
+
+import "example.com/app/store"
+
+func main() {
+	store.Lookup("a")
+	store.Lookup("b")
+}
+
+func other() {
+	store.Lookup("c")
+}
+`)
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(r.RankedSymbols) != 6 || r.RankedSymbols[0].Name != "Lookup" {
+		t.Fatalf("RankedSymbols = %v, want Lookup first", r.RankedSymbols)
+	}
+
+	// Room for Lookup only, not the rest of store.go
+	lookupEntry := len(filepath.Join(tmp, "store", "store.go") + ": \nLookup(key string)\n\n")
+	unlimited := r.BuildRepoMap(repository.RepoMapOptions{CountTokens: countChars})
+	repoMap := r.BuildRepoMap(repository.RepoMapOptions{
+		MaxTokens:   unlimited.ReservedTokens + lookupEntry,
+		CountTokens: countChars,
+	})
+
+	if len(repoMap.Entries) != 1 || fmt.Sprint(repoMap.Entries[0].Signatures) != "[Lookup(key string)]" {
+		t.Fatalf("entries = %#v, want Lookup alone", repoMap.Entries)
+	}
+	if repoMap.TruncatedSymbols != 5 || len(repoMap.TruncatedFiles) != 1 || !repoMap.IsTruncated() {
+		t.Errorf("truncated symbols = %d files = %v, want 5 symbols and main.go", repoMap.TruncatedSymbols, repoMap.TruncatedFiles)
+	}
+}
+
+func TestRepository_BuildRepoMap_GroupsSymbolsInDeclarationOrder(t *testing.T) {
+	r := repository.NewRepository(t.TempDir())
+	r.RankedFiles = []string{"/repo/a.go", "/repo/b.go"}
+	r.RankedSymbols = []types.Symbol{
+		{Name: "Late", Signature: "Late()", FilePath: "/repo/a.go", Line: 20},
+		{Name: "B", Signature: "B()", FilePath: "/repo/b.go", Line: 1},
+		{Name: "Early", Signature: "Early()", FilePath: "/repo/a.go", Line: 2},
+	}
+
+	repoMap := r.BuildRepoMap(repository.RepoMapOptions{CountTokens: countChars})
+
+	if repoMap.IsTruncated() || len(repoMap.Entries) != 2 {
+		t.Fatalf("entries = %#v, want both files", repoMap.Entries)
+	}
+	if repoMap.Entries[0].Path != "/repo/a.go" || fmt.Sprint(repoMap.Entries[0].Signatures) != "[Early() Late()]" {
+		t.Errorf("first entry = %#v, want a.go with its symbols as declared", repoMap.Entries[0])
+	}
+
+	want := len("/repo/a.go: \nEarly()\nLate()\n\n") + len("/repo/b.go: \nB()\n\n")
+	if repoMap.TokenCount != want {
+		t.Errorf("TokenCount = %d, want %d", repoMap.TokenCount, want)
+	}
+}
+
+func TestRepository_BuildRepoMap_CountsEachSignatureOnce(t *testing.T) {
+	r := repository.NewRepository(t.TempDir())
+	r.RankedFiles = []string{"/repo/a.go"}
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("F%d", i)
+		r.RankedSymbols = append(r.RankedSymbols, types.Symbol{Name: name, Signature: name + "()", FilePath: "/repo/a.go", Line: i})
+	}
+
+	counted := make(map[string]int)
+	r.BuildRepoMap(repository.RepoMapOptions{Input: "question", CountTokens: func(s string) int {
+		counted[s]++
+		return countChars(s)
+	}})
+
+	for text, n := range counted {
+		if n > 1 {
+			t.Errorf("counted %q %d times, want once", text, n)
+		}
+	}
+	// The reserved texts, the entry header and one line per signature
+	if len(counted) > 4+1+len(r.RankedSymbols) {
+		t.Errorf("counted %d texts, want one per signature", len(counted))
+	}
+}
+
+func TestRepository_RankForInput_BiasesTowardMentionedSymbolsAndAddedFiles(t *testing.T) {
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "billing", "billing.go"), "package billing\n\nfunc Charge() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "auth", "auth.go"), "package auth\n\nfunc Login() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), `package main
+
+import (
+	"example.com/app/auth"
+	"example.com/app/billing"
+)
+
+func main() {
+	auth.Login()
+	billing.Charge()
+}
+`)
+
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// Without personalization auth.go and billing.go tie, auth.go sorts first
+	r.RankForInput("what does this do?")
+	if r.RankedSymbols[0].Name != "Login" {
+		t.Fatalf("RankedSymbols = %v, want Login first", r.RankedSymbols)
+	}
+
+	r.RankForInput("why does Charge fail?")
+	if r.RankedSymbols[0].Name != "Charge" {
+		t.Fatalf("RankedSymbols = %v, want the mentioned symbol first", r.RankedSymbols)
+	}
+
+	billingPath := filepath.Join(tmp, "billing", "billing.go")
+	r.RankForInput("see billing/billing.go")
+	if r.RankedSymbols[0].Name != "Charge" || r.RankedFiles[0] != billingPath {
+		t.Fatalf("RankedSymbols = %v RankedFiles = %v, want billing first", r.RankedSymbols, r.RankedFiles)
+	}
+
+	if err := r.AddFile(billingPath); err != nil {
+		t.Fatalf("AddFile: %v", err)
+	}
+	r.RankForInput("what does this do?")
+	if r.RankedSymbols[0].Name != "Charge" {
+		t.Fatalf("RankedSymbols = %v, want the symbol of the added file first", r.RankedSymbols)
+	}
+}
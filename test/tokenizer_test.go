@@ -0,0 +1,41 @@
+package test
+
+import (
+	"testing"
+
+	"github.com/manosriram/wingman/internal/tokenizer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApproximateEstimator_CountTokens(t *testing.T) {
+	estimator := tokenizer.NewApproximateEstimator()
+
+	tests := []struct {
+		name string
+		text string
+		want int
+	}{
+		{name: "empty", text: "", want: 0},
+		{name: "words", text: "hello world", want: 2},
+		{name: "camel case", text: "getUserName", want: 3},
+		{name: "snake case", text: "get_user_name", want: 3},
+		{name: "long word", text: "internationalization", want: 4},
+		{name: "digits", text: "1234567", want: 3},
+		{name: "punctuation", text: "():=", want: 2},
+		{name: "newlines", text: "a\n\nb", want: 4},
+		{name: "non ascii", text: "héllo", want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, estimator.CountTokens(tt.text))
+		})
+	}
+}
+
+func TestApproximateEstimator_CodeCountsMoreThanWords(t *testing.T) {
+	estimator := tokenizer.NewApproximateEstimator()
+
+	code := "func (r *Repository) BuildRepoMap(opts RepoMapOptions) RepoMap {"
+	assert.Greater(t, estimator.CountTokens(code), 6)
+}
@@ -0,0 +1,255 @@
+package test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/manosriram/wingman/internal/repository"
+)
+
+func writeWatchedRepo(t *testing.T) string {
+	t.Helper()
+
+	tmp := t.TempDir()
+	writeFileRepo(t, filepath.Join(tmp, "go.mod"), "module example.com/app\n\ngo 1.22\n")
+	writeFileRepo(t, filepath.Join(tmp, "store", "store.go"), "package store\n\nfunc Get() string { return \"\" }\n")
+	writeFileRepo(t, filepath.Join(tmp, "auth", "auth.go"), "package auth\n\nfunc Login() {}\n")
+	writeFileRepo(t, filepath.Join(tmp, "main.go"), "package main\n\nimport \"example.com/app/store\"\n\nfunc main() { store.Get() }\n")
+	return tmp
+}
+
+func TestRepository_Refresh_ReparsesChangedFilesAndRescores(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	result, err := r.Refresh()
+	if err != nil || result.HasChanges() || result.MapChanged {
+		t.Fatalf("Refresh of an unchanged repository = %+v, %v, want no changes", result, err)
+	}
+
+	mainPath := filepath.Join(tmp, "main.go")
+	authPath := filepath.Join(tmp, "auth", "auth.go")
+	writeFileRepo(t, mainPath, "package main\n\nimport \"example.com/app/auth\"\n\nfunc main() { auth.Login() }\n")
+
+	result, err = r.Refresh()
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if fmt.Sprint(result.ChangedFiles) != fmt.Sprint([]string{mainPath}) || !result.MapChanged {
+		t.Errorf("Refresh = %+v, want main.go changed and a new map", result)
+	}
+	if r.CachedFiles != 2 {
+		t.Errorf("CachedFiles = %d, want all but main.go from the last run", r.CachedFiles)
+	}
+
+	if outs := r.Graph.GetOutNodesOfNode(mainPath); len(outs) != 1 || outs[0].NodeValue != authPath {
+		t.Errorf("edges of main.go = %v, want one to auth.go", outs)
+	}
+	if r.RankedFiles[0] != authPath {
+		t.Errorf("RankedFiles = %v, want auth.go first now main.go uses it", r.RankedFiles)
+	}
+
+	fresh := repository.NewRepository(tmp)
+	fresh.CacheDir = ""
+	if err := fresh.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	assertSameIndex(t, r, fresh)
+}
+
+func TestRepository_Refresh_ReloadsAddedFiles(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	notes := filepath.Join(tmp, "NOTES.md")
+	authPath := filepath.Join(tmp, "auth", "auth.go")
+	writeFileRepo(t, notes, "todo\n")
+	if err := r.AddFiles([]string{notes, authPath}); err != nil {
+		t.Fatalf("AddFiles: %v", err)
+	}
+
+	writeFileRepo(t, notes, "done\n")
+	if err := os.Remove(authPath); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Refresh()
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if fmt.Sprint(result.ReloadedFiles) != fmt.Sprint([]string{notes}) || fmt.Sprint(result.DroppedFiles) != fmt.Sprint([]string{authPath}) {
+		t.Errorf("Refresh = %+v, want NOTES.md reloaded and auth.go dropped", result)
+	}
+	if r.AddedFiles[notes] != "done\n" || len(r.AddedFiles) != 1 {
+		t.Errorf("AddedFiles = %v, want the new NOTES.md only", r.AddedFiles)
+	}
+	if _, ok := r.NodeImports[authPath]; ok {
+		t.Errorf("removed auth.go is still indexed")
+	}
+}
+
+func TestWatcher_Poll_RefreshesOnChange(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	w := repository.NewWatcher(r, time.Second, &sync.Mutex{})
+
+	// The first poll records the files, the second finds nothing new
+	for range 2 {
+		if result, err := w.Poll(); err != nil || result.HasChanges() {
+			t.Fatalf("Poll = %+v, %v, want no changes", result, err)
+		}
+	}
+
+	storePath := filepath.Join(tmp, "store", "store.go")
+	writeFileRepo(t, storePath, "package store\n\nfunc Get() string { return \"\" }\n\nfunc Put() {}\n")
+	result, err := w.Poll()
+	if err != nil || fmt.Sprint(result.ChangedFiles) != fmt.Sprint([]string{storePath}) {
+		t.Fatalf("Poll = %+v, %v, want store.go changed", result, err)
+	}
+	if got := r.Signatures[storePath]; fmt.Sprint(got) != "[Get() Put()]" {
+		t.Errorf("signatures of store.go = %v, want Put added", got)
+	}
+}
+
+func TestWatcher_Poll_PatchesChangedFilesLikeARun(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	w := repository.NewWatcher(r, time.Second, &sync.Mutex{})
+	if _, err := w.Poll(); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+
+	mainPath := filepath.Join(tmp, "main.go")
+	storePath := filepath.Join(tmp, "store", "store.go")
+	authAST := r.RepositoryNodesAST[filepath.Join(tmp, "auth", "auth.go")]
+	edits := []map[string]string{
+		// A definition main.go uses is renamed
+		{storePath: "package store\n\nfunc Fetch() string { return \"\" }\n\nfunc Put() {}\n"},
+		// main.go imports another package
+		{mainPath: "package main\n\nimport (\n\t\"example.com/app/auth\"\n\t\"example.com/app/store\"\n)\n\nfunc main() { auth.Login(); store.Put() }\n"},
+	}
+	for _, edit := range edits {
+		var want []string
+		for path, contents := range edit {
+			writeFileRepo(t, path, contents)
+			want = append(want, path)
+		}
+
+		result, err := w.Poll()
+		if err != nil || fmt.Sprint(result.ChangedFiles) != fmt.Sprint(want) {
+			t.Fatalf("Poll = %+v, %v, want %v changed", result, err, want)
+		}
+
+		fresh := repository.NewRepository(tmp)
+		fresh.CacheDir = ""
+		if err := fresh.Run(); err != nil {
+			t.Fatalf("Run: %v", err)
+		}
+		assertSameIndex(t, r, fresh)
+		if !reflect.DeepEqual(r.Graph.G, fresh.Graph.G) {
+			t.Errorf("Graph = %v, want %v", r.Graph.G, fresh.Graph.G)
+		}
+		if !reflect.DeepEqual(r.SymbolGraph.G, fresh.SymbolGraph.G) {
+			t.Errorf("SymbolGraph = %v, want %v", r.SymbolGraph.G, fresh.SymbolGraph.G)
+		}
+	}
+
+	if r.RepositoryNodesAST[filepath.Join(tmp, "auth", "auth.go")] != authAST {
+		t.Errorf("auth.go was parsed again, only the changed files should be")
+	}
+}
+
+func TestRepository_PrepareRefresh_LeavesRepositoryUntilApplied(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	storePath := filepath.Join(tmp, "store", "store.go")
+	writeFileRepo(t, storePath, "package store\n\nfunc Get() string { return \"\" }\n\nfunc Put() {}\n")
+	update, err := r.PrepareRefresh([]string{storePath})
+	if err != nil {
+		t.Fatalf("PrepareRefresh: %v", err)
+	}
+	if got := r.Signatures[storePath]; fmt.Sprint(got) != "[Get()]" {
+		t.Errorf("signatures of store.go = %v before ApplyRefresh, want them unchanged", got)
+	}
+
+	result := r.ApplyRefresh(update)
+	if got := r.Signatures[storePath]; fmt.Sprint(got) != "[Get() Put()]" || !result.MapChanged {
+		t.Errorf("signatures of store.go = %v, result %+v, want Put added", got, result)
+	}
+}
+
+func TestWatcher_Poll_RefreshesOnIgnoreFileChange(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	w := repository.NewWatcher(r, time.Second, &sync.Mutex{})
+	if _, err := w.Poll(); err != nil {
+		t.Fatalf("Poll: %v", err)
+	}
+
+	authPath := filepath.Join(tmp, "auth", "auth.go")
+	writeFileRepo(t, filepath.Join(tmp, ".wingmanignore"), "auth/\n")
+	result, err := w.Poll()
+	if err != nil || fmt.Sprint(result.ChangedFiles) != fmt.Sprint([]string{authPath}) {
+		t.Fatalf("Poll = %+v, %v, want auth.go dropped", result, err)
+	}
+	if _, ok := r.NodeImports[authPath]; ok {
+		t.Errorf("auth.go is still indexed after ignoring it")
+	}
+}
+
+func TestWatcher_Start_CallsOnRefresh(t *testing.T) {
+	tmp := writeWatchedRepo(t)
+	r := repository.NewRepository(tmp)
+	if err := r.Run(); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	refreshed := make(chan repository.RefreshResult, 1)
+	w := repository.NewWatcher(r, 10*time.Millisecond, &sync.Mutex{})
+	w.OnRefresh = func(result repository.RefreshResult) {
+		select {
+		case refreshed <- result:
+		default:
+		}
+	}
+	if err := w.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	defer w.Stop()
+
+	newPath := filepath.Join(tmp, "billing", "billing.go")
+	writeFileRepo(t, newPath, "package billing\n\nfunc Charge() {}\n")
+
+	select {
+	case result := <-refreshed:
+		if fmt.Sprint(result.ChangedFiles) != fmt.Sprint([]string{newPath}) || !result.MapChanged {
+			t.Errorf("OnRefresh got %+v, want billing.go added to the map", result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnRefresh not called after a file was created")
+	}
+}